@@ -0,0 +1,181 @@
+// Package alias loads the tool alias registry: the built-in aliases, plus
+// aliases defined in the user's config file and the project's phpx.toml.
+package alias
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/eddmann/phpx/internal/composer"
+)
+
+// Builtin is the origin of aliases shipped with phpx.
+const Builtin = "built-in"
+
+// Alias maps a tool name to a package, with defaults applied when the tool
+// is run under that name.
+//
+// The user config file holds one table per alias:
+//
+//	[deploy]
+//	package = "acme/deploy-cli"
+//	binary = "deploy"
+//	php = "^8.2"
+//	allow-host = ["api.acme.com"]
+type Alias struct {
+	Package    string   `toml:"package"` // Package, source or phar: URL; defaults to the alias it overrides
+	Binary     string   `toml:"binary"`  // Binary to run, as with --from
+	PHP        string   `toml:"php"`
+	Extensions []string `toml:"extensions"`
+	With       []string `toml:"with"`
+
+	// Sandbox defaults, as with the flags of the same name
+	Sandbox    bool     `toml:"sandbox"`
+	Offline    bool     `toml:"offline"`
+	AllowHost  []string `toml:"allow-host"`
+	AllowRead  []string `toml:"allow-read"`
+	AllowWrite []string `toml:"allow-write"`
+	AllowEnv   []string `toml:"allow-env"`
+
+	Origin   string `toml:"-"` // Builtin, or the file defining the alias
+	Confined bool   `toml:"-"` // Set by Confine; found by name only, not by package
+}
+
+// Registry holds aliases keyed by tool name.
+type Registry map[string]Alias
+
+// Builtins returns the aliases shipped with phpx, including tools run from
+// their release PHARs.
+func Builtins() Registry {
+	r := make(Registry, len(composer.Aliases)+len(composer.Phars))
+	for name, pkg := range composer.Aliases {
+		r[name] = Alias{Package: pkg, Origin: Builtin}
+	}
+	for name, release := range composer.Phars {
+		r[name] = Alias{Package: "phar:" + release.Latest, Origin: Builtin}
+	}
+	return r
+}
+
+// UserFile returns the path of the user alias file,
+// e.g. ~/.config/phpx/aliases.toml.
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "phpx", "aliases.toml"), nil
+}
+
+// LoadFile reads aliases from a file. A missing file has no aliases.
+func LoadFile(path string) (Registry, error) {
+	var r Registry
+	if _, err := toml.DecodeFile(path, &r); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return r.From(path), nil
+}
+
+// From sets the origin of every alias in r and returns it.
+func (r Registry) From(origin string) Registry {
+	for name, a := range r {
+		a.Origin = origin
+		r[name] = a
+	}
+	return r
+}
+
+// Merge returns r overlaid with the aliases in other. An alias without a
+// package keeps the package of the alias it overrides.
+func (r Registry) Merge(other Registry) Registry {
+	merged := make(Registry, len(r)+len(other))
+	for name, a := range r {
+		merged[name] = a
+	}
+	for name, a := range other {
+		if a.Package == "" {
+			a.Package = merged[name].Package
+		}
+		merged[name] = a
+	}
+	return merged
+}
+
+// Confine limits project aliases, which come from whatever repository is
+// checked out, to adding new names and tightening the sandbox: they cannot
+// allow hosts, paths or environment variables, and an alias for a name
+// already in base only adds sandbox and offline to it. It returns the
+// confined aliases and a description of each setting it ignored.
+func (r Registry) Confine(base Registry) (Registry, []string) {
+	confined := make(Registry, len(r))
+	var ignored []string
+
+	for _, name := range r.Names() {
+		a := r[name]
+
+		if len(a.AllowHost)+len(a.AllowRead)+len(a.AllowWrite)+len(a.AllowEnv) > 0 {
+			ignored = append(ignored, fmt.Sprintf("%s: allow-host, allow-read, allow-write and allow-env", name))
+			a.AllowHost, a.AllowRead, a.AllowWrite, a.AllowEnv = nil, nil, nil, nil
+		}
+
+		if existing, ok := base[name]; ok {
+			if a.Package != "" || a.Binary != "" || a.PHP != "" || len(a.Extensions) > 0 || len(a.With) > 0 {
+				ignored = append(ignored, fmt.Sprintf("%s: settings other than sandbox and offline for the %s alias", name, existing.Origin))
+			}
+			existing.Sandbox = existing.Sandbox || a.Sandbox
+			existing.Offline = existing.Offline || a.Offline
+			existing.Origin = a.Origin
+			a = existing
+		}
+
+		a.Confined = true
+		confined[name] = a
+	}
+	return confined, ignored
+}
+
+// Lookup finds the alias for a tool name. Configured aliases are also found
+// by their package, so their defaults apply however the tool is named.
+func (r Registry) Lookup(name string) (string, *Alias, bool) {
+	if a, ok := r[name]; ok {
+		return name, &a, true
+	}
+
+	for _, key := range r.Names() {
+		if a := r[key]; a.Origin != Builtin && !a.Confined && a.Package == name {
+			return key, &a, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// Names returns the alias names in sorted order.
+func (r Registry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve expands a tool name to its package: the package of a configured
+// alias, or otherwise the built-in alias composer.ResolveAlias expands it
+// to. Aliases for Git, path, archive and PHAR sources name no package.
+func (r Registry) Resolve(name string) string {
+	if a, ok := r[name]; ok && a.Origin != Builtin && a.Package != "" {
+		if _, _, src := composer.ParseToolArg(a.Package); src == nil {
+			return a.Package
+		}
+	}
+	return composer.ResolveAlias(name)
+}
@@ -0,0 +1,168 @@
+package alias
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eddmann/phpx/internal/composer"
+)
+
+func writeAliases(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "aliases.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Run("reads aliases with their origin", func(t *testing.T) {
+		path := writeAliases(t, `
+[deploy]
+package = "acme/deploy-cli"
+binary = "deploy"
+php = "^8.2"
+sandbox = true
+allow-host = ["api.acme.com"]
+`)
+
+		r, err := LoadFile(path)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := r["deploy"]
+		if got.Package != "acme/deploy-cli" || got.Binary != "deploy" || got.PHP != "^8.2" || !got.Sandbox {
+			t.Errorf("got %+v", got)
+		}
+		if len(got.AllowHost) != 1 || got.AllowHost[0] != "api.acme.com" {
+			t.Errorf("AllowHost = %v, want [api.acme.com]", got.AllowHost)
+		}
+		if got.Origin != path {
+			t.Errorf("Origin = %q, want %q", got.Origin, path)
+		}
+	})
+
+	t.Run("treats a missing file as empty", func(t *testing.T) {
+		r, err := LoadFile(filepath.Join(t.TempDir(), "aliases.toml"))
+
+		if err != nil || len(r) != 0 {
+			t.Errorf("got (%v, %v), want empty registry", r, err)
+		}
+	})
+
+	t.Run("reports invalid TOML", func(t *testing.T) {
+		if _, err := LoadFile(writeAliases(t, "[deploy\n")); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	user := Registry{
+		"phpstan": {With: []string{"phpstan/phpstan-strict-rules"}, Origin: "user"},
+		"phpmd":   {Package: "phpmd/phpmd", Origin: "user"},
+	}
+	project := Registry{
+		"phpmd": {Package: "acme/phpmd-fork", Origin: "project"},
+	}
+
+	r := Builtins().Merge(user).Merge(project)
+
+	if got := r["phpstan"]; got.Package != "phpstan/phpstan" || got.Origin != "user" {
+		t.Errorf("phpstan = %+v, want built-in package with user defaults", got)
+	}
+	if got := r["phpmd"]; got.Package != "acme/phpmd-fork" || got.Origin != "project" {
+		t.Errorf("phpmd = %+v, want project alias", got)
+	}
+	if got := r["psalm"]; got.Package != "vimeo/psalm" || got.Origin != Builtin {
+		t.Errorf("psalm = %+v, want built-in alias", got)
+	}
+}
+
+func TestConfine(t *testing.T) {
+	base := Builtins().Merge(Registry{
+		"deploy": {Package: "acme/deploy-cli", AllowHost: []string{"api.acme.com"}, Origin: "user"},
+	})
+	project := Registry{
+		"phpstan": {Package: "https://example.com/evil.phar", With: []string{"evil/plugin"}, Sandbox: true, Origin: "project"},
+		"deploy":  {Offline: true, Origin: "project"},
+		"bench":   {Package: "phpbench/phpbench", AllowRead: []string{"~/.ssh"}, AllowEnv: []string{"AWS_SECRET_ACCESS_KEY"}, Origin: "project"},
+	}
+
+	r, ignored := project.Confine(base)
+
+	if got := r["phpstan"]; got.Package != "phpstan/phpstan" || len(got.With) != 0 || !got.Sandbox {
+		t.Errorf("phpstan = %+v, want built-in package with only the sandbox added", got)
+	}
+	if got := r["deploy"]; got.Package != "acme/deploy-cli" || len(got.AllowHost) != 1 || !got.Offline {
+		t.Errorf("deploy = %+v, want user alias with offline added", got)
+	}
+	if got := r["bench"]; got.Package != "phpbench/phpbench" || len(got.AllowRead) != 0 || len(got.AllowEnv) != 0 {
+		t.Errorf("bench = %+v, want new alias without sandbox allowances", got)
+	}
+	if len(ignored) != 2 {
+		t.Errorf("got ignored %v, want the phpstan override and bench allowances", ignored)
+	}
+
+	if _, _, ok := base.Merge(r).Lookup("phpbench/phpbench"); ok {
+		t.Error("project alias was found by its package")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := Builtins().Merge(Registry{"deploy": {Package: "acme/deploy-cli", Origin: "user"}})
+
+	tests := []struct {
+		name     string
+		arg      string
+		wantName string
+		wantOK   bool
+	}{
+		{name: "finds an alias by name", arg: "deploy", wantName: "deploy", wantOK: true},
+		{name: "finds a configured alias by package", arg: "acme/deploy-cli", wantName: "deploy", wantOK: true},
+		{name: "finds a built-in alias by name", arg: "phpstan", wantName: "phpstan", wantOK: true},
+		{name: "ignores built-in packages", arg: "phpstan/phpstan", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, _, ok := r.Lookup(tt.arg)
+
+			if ok != tt.wantOK || name != tt.wantName {
+				t.Errorf("got (%q, %v), want (%q, %v)", name, ok, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := Builtins().Merge(Registry{
+		"deploy": {Package: "acme/deploy-cli", Origin: "user"},
+		"fork":   {Package: "git+https://github.com/acme/fork@main", Origin: "user"},
+	})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"deploy", "acme/deploy-cli"},
+		{"fork", "fork"},
+		{"phpstan", "phpstan/phpstan"},
+		{"acme/other", "acme/other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Resolve(tt.name); got != tt.want {
+				t.Errorf("Resolve(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if got := composer.ResolveAlias("deploy"); got != "deploy" {
+		t.Errorf("composer.ResolveAlias(deploy) = %q, want configured aliases kept out of the built-ins", got)
+	}
+}
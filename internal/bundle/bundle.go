@@ -0,0 +1,331 @@
+// Package bundle packs a script, its local includes and its dependencies
+// into a self-contained PHAR or executable, or exports them as a Composer
+// project.
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Options describes a bundle to build.
+type Options struct {
+	Script     string   // Absolute path of the script to run
+	Includes   []string // Absolute paths of local files the script includes
+	VendorDir  string   // Installed dependencies; empty without packages
+	PHPBinary  string   // PHP used to build the PHAR
+	PHP        string   // PHP constraint checked when the PHAR starts
+	Extensions []string // Extensions checked when the PHAR starts
+	Output     string   // Path of the PHAR to write
+	Verbose    bool
+}
+
+// Result describes a built bundle.
+type Result struct {
+	Files int
+	Size  int64
+}
+
+// srcDir and vendorDir are where the script and its dependencies live in
+// the PHAR.
+const (
+	srcDir    = "src"
+	vendorDir = "vendor"
+)
+
+// builder builds the PHAR from a manifest of paths inside the PHAR to files
+// on disk. Phar only writes files with a .phar extension, so it is built
+// under a temporary name and moved into place afterwards.
+const builder = `<?php
+$manifest = json_decode(file_get_contents($argv[1]), true);
+$phar = new Phar($manifest['output'], 0, $manifest['alias']);
+$phar->startBuffering();
+$phar->buildFromIterator(new ArrayIterator($manifest['files']));
+$phar->setMetadata($manifest['metadata']);
+$phar->setStub($manifest['stub']);
+$phar->stopBuffering();
+`
+
+// manifest is passed to the builder.
+type manifest struct {
+	Output   string            `json:"output"`
+	Alias    string            `json:"alias"`
+	Files    map[string]string `json:"files"`
+	Metadata map[string]any    `json:"metadata"`
+	Stub     string            `json:"stub"`
+}
+
+// Build writes a PHAR that runs the script with its dependencies, checking
+// the declared PHP version and extensions first.
+func Build(opts *Options) (*Result, error) {
+	work, err := os.MkdirTemp("", "phpx-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(work) }()
+
+	files, entry, err := sourceFiles(opts.Script, opts.Includes, srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The PHP CLI only skips a shebang on the script it runs, so the line
+	// is blanked in the bundled copy, keeping line numbers intact
+	content, err := os.ReadFile(opts.Script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	if stripped, ok := stripShebang(content); ok {
+		path := filepath.Join(work, filepath.Base(opts.Script))
+		if err := os.WriteFile(path, stripped, 0644); err != nil {
+			return nil, err
+		}
+		files[entry] = path
+	}
+
+	autoload := ""
+	if opts.VendorDir != "" {
+		vendor, err := vendorFiles(opts.VendorDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect dependencies: %w", err)
+		}
+		for name, path := range vendor {
+			files[name] = path
+		}
+		autoload = vendorDir + "/autoload.php"
+	}
+
+	output, err := filepath.Abs(opts.Output)
+	if err != nil {
+		return nil, err
+	}
+	alias := filepath.Base(output)
+
+	m := manifest{
+		Output:   filepath.Join(filepath.Dir(output), "."+alias+".tmp-"+strconv.Itoa(os.Getpid())+".phar"),
+		Alias:    alias,
+		Files:    files,
+		Metadata: map[string]any{"php": opts.PHP, "extensions": extensionList(opts.Extensions)},
+	}
+	m.Stub, err = Stub(alias, entry, autoload, opts.PHP, opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestPath := filepath.Join(work, "manifest.json")
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return nil, err
+	}
+
+	builderPath := filepath.Join(work, "build.php")
+	if err := os.WriteFile(builderPath, []byte(builder), 0644); err != nil {
+		return nil, err
+	}
+
+	if opts.Verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Packing %d files into %s\n", len(files), output)
+	}
+
+	cmd := exec.Command(opts.PHPBinary, "-d", "phar.readonly=0", builderPath, manifestPath)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(m.Output)
+		return nil, fmt.Errorf("failed to build PHAR: %w", err)
+	}
+
+	if err := os.Chmod(m.Output, 0755); err != nil {
+		_ = os.Remove(m.Output)
+		return nil, err
+	}
+	if err := os.Rename(m.Output, output); err != nil {
+		_ = os.Remove(m.Output)
+		return nil, err
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Files: len(files), Size: info.Size()}, nil
+}
+
+// Executable writes a self-contained executable to output: a static PHP
+// micro SAPI followed by a PHAR, which micro runs on startup.
+func Executable(micro, phar, output string) error {
+	tmp := output + ".tmp-" + strconv.Itoa(os.Getpid())
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{micro, phar} {
+		if err := appendFile(out, path); err != nil {
+			_ = out.Close()
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// appendFile copies the file at path to w.
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// sourceFiles maps the script and its includes to their paths under dir,
+// keeping their layout relative to the directory containing them all so
+// that includes relative to __DIR__ still resolve. It also returns the
+// script's path.
+func sourceFiles(script string, includes []string, dir string) (map[string]string, string, error) {
+	base := filepath.Dir(script)
+	for _, path := range includes {
+		for !within(path, base) {
+			base = filepath.Dir(base)
+		}
+	}
+
+	files := make(map[string]string)
+	entry := ""
+	for _, path := range append([]string{script}, includes...) {
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil, "", err
+		}
+		name := dir + "/" + filepath.ToSlash(rel)
+		files[name] = path
+		if path == script {
+			entry = name
+		}
+	}
+	return files, entry, nil
+}
+
+// within reports whether path is inside dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// pruneDirs are left out of bundled dependencies wherever they appear.
+var pruneDirs = map[string]bool{".git": true, ".github": true, ".hg": true, ".svn": true}
+
+// prunePackageDirs are left out of the top level of each package unless the
+// package autoloads from them.
+var prunePackageDirs = map[string]bool{"tests": true, "Tests": true, "test": true, "docs": true, "doc": true}
+
+// prunePackageFiles match files left out of the top level of each package.
+var prunePackageFiles = []string{"*.md", "phpunit.xml*", "phpstan.neon*", "psalm.xml*", ".*"}
+
+// vendorFiles maps the files of an installed vendor directory to their paths
+// in the PHAR, leaving out VCS metadata, package tests and documentation,
+// and vendor/bin, whose proxies cannot run from inside a PHAR.
+func vendorFiles(dir string) (map[string]string, error) {
+	autoloads := autoloadedPaths(dir)
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		depth := strings.Count(rel, "/") + 1 // vendor/package/name/<depth 3>
+
+		if d.IsDir() {
+			switch {
+			case rel == "bin", pruneDirs[d.Name()]:
+				return filepath.SkipDir
+			case depth == 3 && prunePackageDirs[d.Name()] && !autoloadsFrom(autoloads, rel):
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if depth == 3 && pruneFile(d.Name()) {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		files[vendorDir+"/"+rel] = path
+		return nil
+	})
+	return files, err
+}
+
+// autoloadsFrom reports whether Composer's autoload maps name the vendor
+// path rel or a file under it. PSR-4 and PSR-0 roots are written without a
+// trailing slash, e.g. $vendorDir . '/acme/lib/tests'.
+func autoloadsFrom(autoloads, rel string) bool {
+	return strings.Contains(autoloads, "/"+rel+"/") || strings.Contains(autoloads, "/"+rel+"'")
+}
+
+// autoloadedPaths returns the contents of Composer's generated autoload
+// maps, which name every path a package autoloads from.
+func autoloadedPaths(dir string) string {
+	maps, _ := filepath.Glob(filepath.Join(dir, "composer", "autoload_*.php"))
+
+	var b strings.Builder
+	for _, path := range maps {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			b.Write(data)
+		}
+	}
+	return b.String()
+}
+
+func pruneFile(name string) bool {
+	for _, pattern := range prunePackageFiles {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stripShebang blanks a leading #! line.
+func stripShebang(content []byte) ([]byte, bool) {
+	if !strings.HasPrefix(string(content), "#!") {
+		return content, false
+	}
+	end := strings.IndexByte(string(content), '\n')
+	if end == -1 {
+		return nil, true
+	}
+	return content[end:], true
+}
@@ -0,0 +1,194 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSourceFiles(t *testing.T) {
+	t.Run("keeps includes at their path relative to the script", func(t *testing.T) {
+		files, entry, err := sourceFiles("/app/bin/tool.php", []string{"/app/bin/helpers.php", "/app/lib/util.php"}, "src")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]string{
+			"src/bin/tool.php":    "/app/bin/tool.php",
+			"src/bin/helpers.php": "/app/bin/helpers.php",
+			"src/lib/util.php":    "/app/lib/util.php",
+		}
+		if !reflect.DeepEqual(files, want) {
+			t.Errorf("got %v, want %v", files, want)
+		}
+		if entry != "src/bin/tool.php" {
+			t.Errorf("got entry %s, want src/bin/tool.php", entry)
+		}
+	})
+
+	t.Run("places a lone script at the top of src", func(t *testing.T) {
+		_, entry, err := sourceFiles("/app/tool.php", nil, "src")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if entry != "src/tool.php" {
+			t.Errorf("got entry %s, want src/tool.php", entry)
+		}
+	})
+}
+
+func TestVendorFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"autoload.php":                     "<?php",
+		"bin/tool":                         "#!/usr/bin/env php",
+		"composer/autoload_files.php":      "<?php return [$vendorDir . '/acme/helpers/tests/functions.php'];",
+		"acme/lib/src/Lib.php":             "<?php",
+		"acme/lib/composer.json":           "{}",
+		"acme/lib/LICENSE":                 "MIT",
+		"acme/lib/README.md":               "# lib",
+		"acme/lib/phpunit.xml.dist":        "<phpunit/>",
+		"acme/lib/.gitattributes":          "",
+		"acme/lib/tests/LibTest.php":       "<?php",
+		"acme/lib/docs/index.md":           "# docs",
+		"acme/lib/src/.git/HEAD":           "ref",
+		"acme/lib/src/tests/Fixture.php":   "<?php",
+		"acme/helpers/tests/functions.php": "<?php",
+		"composer/autoload_psr4.php":       "<?php return array('Acme\\\\Fixtures\\\\' => array($vendorDir . '/acme/fixtures/tests'));",
+		"acme/fixtures/tests/Factory.php":  "<?php",
+		"acme/fixtures/test/Unused.php":    "<?php",
+	})
+
+	files, err := vendorFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for name := range files {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"vendor/acme/fixtures/tests/Factory.php",
+		"vendor/acme/helpers/tests/functions.php",
+		"vendor/acme/lib/LICENSE",
+		"vendor/acme/lib/composer.json",
+		"vendor/acme/lib/src/Lib.php",
+		"vendor/acme/lib/src/tests/Fixture.php",
+		"vendor/autoload.php",
+		"vendor/composer/autoload_files.php",
+		"vendor/composer/autoload_psr4.php",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStripShebang(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantOK  bool
+	}{
+		{"blanks the shebang line", "#!/usr/bin/env phpx\n<?php echo 1;\n", "\n<?php echo 1;\n", true},
+		{"leaves scripts without one", "<?php echo 1;\n", "<?php echo 1;\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := stripShebang([]byte(tt.content))
+
+			if string(got) != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStub(t *testing.T) {
+	t.Run("checks requirements then runs the script with its autoloader", func(t *testing.T) {
+		stub, err := Stub("tool.phar", "src/tool.php", "vendor/autoload.php", ">=8.2", []string{"redis", "opcache"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, want := range []string{
+			"#!/usr/bin/env php\n<?php\n",
+			`if (!(\version_compare($phpxVersion, '8.2.0.0-dev', '>='))) {`,
+			`'tool.phar requires PHP >=8.2, running '`,
+			`foreach (array('redis', 'Zend OPcache') as $phpxExtension) {`,
+			"Phar::mapPhar('tool.phar');\nrequire 'phar://tool.phar/vendor/autoload.php';\nrequire 'phar://tool.phar/src/tool.php';\n",
+		} {
+			if !strings.Contains(stub, want) {
+				t.Errorf("stub missing %q:\n%s", want, stub)
+			}
+		}
+		if !strings.HasSuffix(stub, "__HALT_COMPILER(); ?>\n") {
+			t.Errorf("stub does not end with __HALT_COMPILER:\n%s", stub)
+		}
+	})
+
+	t.Run("omits checks and autoloader that are not needed", func(t *testing.T) {
+		stub, err := Stub("tool.phar", "src/tool.php", "", "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, unwanted := range []string{"$phpxVersion", "$phpxMissing", "autoload.php"} {
+			if strings.Contains(stub, unwanted) {
+				t.Errorf("stub contains %q:\n%s", unwanted, stub)
+			}
+		}
+	})
+
+	t.Run("rejects an invalid PHP constraint", func(t *testing.T) {
+		if _, err := Stub("tool.phar", "src/tool.php", "", ">=>8", nil); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
+
+func TestExecutable(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"micro.sfx": "SFX",
+		"tool.phar": "PHAR",
+	})
+	output := filepath.Join(dir, "tool")
+
+	if err := Executable(filepath.Join(dir, "micro.sfx"), filepath.Join(dir, "tool.phar"), output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "SFXPHAR" {
+		t.Errorf("got %q, want micro.sfx followed by the PHAR", data)
+	}
+	info, _ := os.Stat(output)
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("got mode %v, want executable", info.Mode())
+	}
+}
@@ -0,0 +1,137 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/metadata"
+)
+
+// binDir and appDir are where an exported project's entrypoint and its own
+// classes live.
+const (
+	binDir       = "bin"
+	appDir       = "src"
+	appNamespace = `App\`
+)
+
+// ProjectOptions describes a Composer project to export a script to.
+type ProjectOptions struct {
+	Script       string   // Absolute path of the script
+	Includes     []string // Absolute paths of local files the script includes
+	Requirements *composer.ProjectRequirements
+	LockFile     string // composer.lock of the script's installed dependencies, if any
+	Dir          string // Project directory to write
+}
+
+// Project writes a Composer project for a script: composer.json from its
+// requirements, composer.lock from its installed dependencies, and the
+// script as a bin/ entrypoint without its // phpx block, loading the
+// project's autoloader. It returns the entrypoint's path in the project.
+func Project(opts *ProjectOptions) (string, error) {
+	if cache.Exists(filepath.Join(opts.Dir, "composer.json")) {
+		return "", fmt.Errorf("%s already has a composer.json", opts.Dir)
+	}
+
+	files, entry, err := sourceFiles(opts.Script, opts.Includes, binDir)
+	if err != nil {
+		return "", err
+	}
+	delete(files, entry)
+	entry = strings.TrimSuffix(entry, ".php")
+
+	for name, src := range files {
+		if err := copyFile(src, filepath.Join(opts.Dir, filepath.FromSlash(name)), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	content, err := os.ReadFile(opts.Script)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %w", err)
+	}
+	autoload := strings.Repeat("/..", strings.Count(entry, "/")) + "/vendor/autoload.php"
+	content = Entrypoint(metadata.Strip(content), autoload)
+
+	entryPath := filepath.Join(opts.Dir, filepath.FromSlash(entry))
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(entryPath, content, 0755); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Join(opts.Dir, appDir), 0755); err != nil {
+		return "", err
+	}
+
+	err = composer.WriteProject(opts.Dir, opts.Requirements, &composer.ProjectOptions{
+		Autoload: map[string]string{appNamespace: appDir + "/"},
+		Bin:      []string{entry},
+		LockFile: opts.LockFile,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return entry, nil
+}
+
+var (
+	openTag = regexp.MustCompile(`<\?php\b[ \t]*\r?\n?`)
+
+	// leadingDeclare matches a declare() statement at the start of a file's
+	// code, after any comments, which must stay the first statement.
+	leadingDeclare = regexp.MustCompile(`^(?s:(?:\s|//[^\n]*\n|#[^\n]*\n|/\*.*?\*/)*declare\s*\([^)]*\)\s*;[ \t]*\r?\n?)`)
+)
+
+// Entrypoint turns a script into a Composer bin entrypoint: it runs with
+// php from the PATH and requires the autoloader, given relative to the
+// script's directory, as its first statement after any declare().
+func Entrypoint(content []byte, autoload string) []byte {
+	s := string(content)
+
+	if strings.HasPrefix(s, "#!") {
+		if end := strings.IndexByte(s, '\n'); end != -1 {
+			s = s[end+1:]
+		} else {
+			s = ""
+		}
+	}
+
+	require := fmt.Sprintf("\nrequire __DIR__ . '%s';\n", autoload)
+
+	loc := openTag.FindStringIndex(s)
+	if loc == nil {
+		s = "<?php\n" + require + "?>\n" + s
+	} else {
+		at := loc[1]
+		if m := leadingDeclare.FindStringIndex(s[at:]); m != nil {
+			at += m[1]
+		}
+		head := s[:at]
+		if !strings.HasSuffix(head, "\n") {
+			head = strings.TrimRight(head, " \t") + "\n"
+		}
+		s = head + require + s[at:]
+	}
+
+	return []byte("#!/usr/bin/env php\n" + s)
+}
+
+// copyFile copies src to dst, creating dst's directory.
+func copyFile(src, dst string, perm os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, perm)
+}
@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eddmann/phpx/internal/composer"
+)
+
+func TestEntrypoint(t *testing.T) {
+	const require = "require __DIR__ . '/../vendor/autoload.php';\n"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "requires the autoloader after the opening tag",
+			content: "<?php\necho 1;\n",
+			want:    "#!/usr/bin/env php\n<?php\n\n" + require + "echo 1;\n",
+		},
+		{
+			name:    "replaces a phpx shebang",
+			content: "#!/usr/bin/env phpx\n<?php\n\necho 1;\n",
+			want:    "#!/usr/bin/env php\n<?php\n\n" + require + "\necho 1;\n",
+		},
+		{
+			name:    "keeps declare() the first statement",
+			content: "<?php\n\n/** Greets */\ndeclare(strict_types=1);\n\necho 1;\n",
+			want:    "#!/usr/bin/env php\n<?php\n\n/** Greets */\ndeclare(strict_types=1);\n\n" + require + "\necho 1;\n",
+		},
+		{
+			name:    "handles code on the opening line",
+			content: "<?php echo 1;\n",
+			want:    "#!/usr/bin/env php\n<?php\n\n" + require + "echo 1;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Entrypoint([]byte(tt.content), "/../vendor/autoload.php")); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"greet.php":       "#!/usr/bin/env phpx\n<?php\n// phpx\n// php = \">=8.2\"\n\nrequire __DIR__ . '/lib/helpers.php';\necho greet();\n",
+		"lib/helpers.php": "<?php function greet() { return 'hi'; }\n",
+	})
+	script := filepath.Join(src, "greet.php")
+	dir := t.TempDir()
+
+	entry, err := Project(&ProjectOptions{
+		Script:       script,
+		Includes:     []string{filepath.Join(src, "lib", "helpers.php")},
+		Requirements: &composer.ProjectRequirements{PHP: ">=8.2"},
+		Dir:          dir,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if entry != "bin/greet" {
+		t.Errorf("got entry %s, want bin/greet", entry)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "bin", "greet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#!/usr/bin/env php\n<?php\n\nrequire __DIR__ . '/../vendor/autoload.php';\nrequire __DIR__ . '/lib/helpers.php';\necho greet();\n"
+	if string(data) != want {
+		t.Errorf("got entrypoint %q, want %q", data, want)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "bin", "greet")); info.Mode().Perm()&0111 == 0 {
+		t.Errorf("got mode %v, want executable", info.Mode())
+	}
+
+	for _, path := range []string{"bin/lib/helpers.php", "src", "composer.json"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("missing %s: %v", path, err)
+		}
+	}
+
+	composerJSON, _ := os.ReadFile(filepath.Join(dir, "composer.json"))
+	if !strings.Contains(string(composerJSON), `"bin/greet"`) {
+		t.Errorf("composer.json does not list bin/greet:\n%s", composerJSON)
+	}
+
+	t.Run("refuses to overwrite a project", func(t *testing.T) {
+		_, err := Project(&ProjectOptions{
+			Script:       script,
+			Requirements: &composer.ProjectRequirements{},
+			Dir:          dir,
+		})
+
+		if err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
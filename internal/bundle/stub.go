@@ -0,0 +1,82 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/util"
+	"github.com/eddmann/phpx/internal/version"
+)
+
+// extensionNames maps extensions to the names extension_loaded() knows them
+// by, where those differ.
+var extensionNames = map[string]string{
+	"opcache": "Zend OPcache",
+}
+
+// Stub returns the PHAR stub: it checks the PHP version and extensions the
+// script declares, then loads the autoloader (when there is one) and runs
+// the script. The checks only use syntax every PHP version can parse, so
+// an old PHP reports what is needed rather than failing to compile.
+func Stub(alias, entry, autoload, phpConstraint string, extensions []string) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("#!/usr/bin/env php\n<?php\n")
+	b.WriteString("// Bundled by phpx\n")
+
+	if phpConstraint != "" {
+		c, err := version.ParseConstraint(phpConstraint)
+		if err != nil {
+			return "", fmt.Errorf("invalid PHP constraint %q: %w", phpConstraint, err)
+		}
+
+		b.WriteString("$phpxVersion = PHP_MAJOR_VERSION . '.' . PHP_MINOR_VERSION . '.' . PHP_RELEASE_VERSION . '.0';\n")
+		fmt.Fprintf(&b, "if (!(%s)) {\n", c.Compile("$phpxVersion"))
+		fmt.Fprintf(&b, "    fwrite(STDERR, %s . PHP_VERSION . \"\\n\");\n", util.PHPString(alias+" requires PHP "+phpConstraint+", running "))
+		b.WriteString("    exit(1);\n}\n")
+		b.WriteString("unset($phpxVersion);\n")
+	}
+
+	if names := extensionList(extensions); len(names) > 0 {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = util.PHPString(name)
+		}
+
+		b.WriteString("$phpxMissing = array();\n")
+		fmt.Fprintf(&b, "foreach (array(%s) as $phpxExtension) {\n", strings.Join(quoted, ", "))
+		b.WriteString("    if (!extension_loaded($phpxExtension)) {\n")
+		b.WriteString("        $phpxMissing[] = $phpxExtension;\n")
+		b.WriteString("    }\n}\n")
+		b.WriteString("if ($phpxMissing) {\n")
+		fmt.Fprintf(&b, "    fwrite(STDERR, %s . implode(', ', $phpxMissing) . \"\\n\");\n", util.PHPString(alias+" requires the PHP extensions: "))
+		b.WriteString("    exit(1);\n}\n")
+		b.WriteString("unset($phpxMissing, $phpxExtension);\n")
+	}
+
+	fmt.Fprintf(&b, "Phar::mapPhar(%s);\n", util.PHPString(alias))
+	if autoload != "" {
+		fmt.Fprintf(&b, "require %s;\n", util.PHPString("phar://"+alias+"/"+autoload))
+	}
+	fmt.Fprintf(&b, "require %s;\n", util.PHPString("phar://"+alias+"/"+entry))
+	b.WriteString("__HALT_COMPILER(); ?>\n")
+
+	return b.String(), nil
+}
+
+// extensionList returns the names of extensions as extension_loaded() knows
+// them.
+func extensionList(extensions []string) []string {
+	var names []string
+	for _, ext := range extensions {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if name, ok := extensionNames[strings.ToLower(ext)]; ok {
+			ext = name
+		}
+		names = append(names, ext)
+	}
+	return names
+}
@@ -45,6 +45,16 @@ func PHPPath(version, tier string) (string, error) {
 	return filepath.Join(dir, version+"-"+tier, "bin", "php"), nil
 }
 
+// MicroPath returns the path to a static PHP micro SAPI for a platform,
+// such as "linux-x86_64".
+func MicroPath(version, tier, platform string) (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "micro", version+"-"+tier+"-"+platform, "micro.sfx"), nil
+}
+
 // DepsDir returns the path to the dependencies cache directory.
 func DepsDir() (string, error) {
 	base, err := Dir()
@@ -73,14 +83,102 @@ func ToolsDir() (string, error) {
 }
 
 // ToolPath returns the path to a specific tool installation.
-func ToolPath(pkg, version string) (string, error) {
+//
+// variant lists any further installation options, such as extra packages;
+// when present, a short hash of them is appended so each combination is
+// installed separately.
+func ToolPath(pkg, version string, variant ...string) (string, error) {
 	dir, err := ToolsDir()
 	if err != nil {
 		return "", err
 	}
 	// Replace / with - for directory name
 	safePkg := strings.ReplaceAll(pkg, "/", "-")
-	return filepath.Join(dir, safePkg+"-"+version), nil
+	name := safePkg + "-" + version
+	if len(variant) > 0 {
+		sum := sha256.Sum256([]byte(strings.Join(variant, "\n")))
+		name += "-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// SourcesDir returns the path to the directory holding tool sources
+// downloaded from archive URLs.
+func SourcesDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "sources"), nil
+}
+
+// SourcePath returns the path to an extracted tool source, keyed by the
+// archive's content hash.
+func SourcePath(hash string) (string, error) {
+	dir, err := SourcesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hash), nil
+}
+
+// EvalDir returns the path to the directory holding code run with -e.
+func EvalDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "eval"), nil
+}
+
+// EvalPath returns the path code run with -e is written to. It is keyed by
+// the code's hash, so the same code always runs from the same path.
+func EvalPath(code []byte) (string, error) {
+	dir, err := EvalDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(code)
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".php"), nil
+}
+
+// ScriptsDir returns the path to the directory holding scripts fetched
+// from URLs.
+func ScriptsDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "scripts"), nil
+}
+
+// ScriptPath returns the path of a script fetched from a URL, keyed by the
+// URL and the content's sha256 digest.
+func ScriptPath(url, digest string) (string, error) {
+	dir, err := ScriptsDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url + "#" + digest))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])), nil
+}
+
+// BinDir returns the default directory for the shims of installed tools.
+func BinDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "bin"), nil
+}
+
+// InstalledDir returns the path to the receipts of installed tools.
+func InstalledDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "installed"), nil
 }
 
 // ComposerDir returns the path to the Composer cache directory.
@@ -101,22 +199,96 @@ func ComposerPath(version string) (string, error) {
 	return filepath.Join(dir, version, "composer.phar"), nil
 }
 
-// DepsHash computes a cache key from a list of packages.
-// Packages are sorted and lowercased before hashing.
-func DepsHash(packages []string) string {
-	// Copy and normalize
-	normalized := make([]string, len(packages))
-	for i, pkg := range packages {
-		normalized[i] = strings.ToLower(pkg)
+// PackagistDir returns the path to the cached Packagist metadata directory.
+func PackagistDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
 	}
-	sort.Strings(normalized)
+	return filepath.Join(base, "packagist"), nil
+}
+
+// PackagistPath returns the path to the cached metadata for a package.
+func PackagistPath(pkg string) (string, error) {
+	dir, err := PackagistDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(strings.ToLower(pkg))+".json"), nil
+}
 
-	// Hash
+// ReleasePath returns the path recording the release a latest-release PHAR
+// URL last resolved to.
+func ReleasePath(url string) (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(base, "releases", hex.EncodeToString(sum[:8])), nil
+}
+
+// ComposerCacheDir returns the path to the shared Composer download cache.
+// All installs point COMPOSER_CACHE_DIR here so package dists are fetched once.
+func ComposerCacheDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "composer-cache"), nil
+}
+
+// StoreDir returns the path to the content-addressed file store used to
+// deduplicate identical package files across deps and tool installations.
+func StoreDir() (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "store"), nil
+}
+
+// DepsKey describes everything that affects how Composer resolves a
+// dependency set, so installs are only reused for an equivalent platform.
+type DepsKey struct {
+	Packages         []string // Package requirements as vendor/name:constraint
+	PHPVersion       string   // PHP major.minor the deps were resolved under
+	Extensions       []string // Extensions available to the platform
+	Repositories     []string // Additional repositories as type:url
+	MinimumStability string   // Composer minimum-stability setting
+	AllowPlugins     []string // Composer plugin rules as name=true|false
+	AllowScripts     bool     // Composer scripts run during install
+}
+
+// DepsHash computes a cache key for a dependency set.
+// Packages and extensions are sorted and lowercased before hashing.
+func DepsHash(key DepsKey) string {
 	h := sha256.New()
-	h.Write([]byte(strings.Join(normalized, "\n")))
+	h.Write([]byte(strings.Join(normalizeList(key.Packages), "\n")))
+	h.Write([]byte("\nphp:" + key.PHPVersion))
+	h.Write([]byte("\next:" + strings.Join(normalizeList(key.Extensions), ",")))
+	h.Write([]byte("\nrepos:" + strings.Join(key.Repositories, ",")))
+	h.Write([]byte("\nstability:" + strings.ToLower(key.MinimumStability)))
+	// Only hashed when set, so existing installs keep their keys
+	if len(key.AllowPlugins) > 0 {
+		h.Write([]byte("\nplugins:" + strings.Join(normalizeList(key.AllowPlugins), ",")))
+	}
+	if key.AllowScripts {
+		h.Write([]byte("\nscripts"))
+	}
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// normalizeList returns a sorted, lowercased copy of a list.
+func normalizeList(items []string) []string {
+	normalized := make([]string, len(items))
+	for i, item := range items {
+		normalized[i] = strings.ToLower(strings.TrimSpace(item))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
 // Exists checks if a path exists.
 func Exists(path string) bool {
 	_, err := os.Stat(path)
@@ -129,7 +301,7 @@ func EnsureDir(path string) error {
 }
 
 // Clean removes cache items based on the specified target.
-// Valid targets: "php", "deps", "tools", "index", "composer", "all"
+// Valid targets: "php", "deps", "tools", "index", "composer", "store", "all"
 func Clean(target string) error {
 	base, err := Dir()
 	if err != nil {
@@ -138,19 +310,56 @@ func Clean(target string) error {
 
 	switch target {
 	case "php":
+		if err := os.RemoveAll(filepath.Join(base, "micro")); err != nil {
+			return err
+		}
 		return os.RemoveAll(filepath.Join(base, "php"))
 	case "deps":
+		for _, dir := range []string{"eval", "scripts"} {
+			if err := os.RemoveAll(filepath.Join(base, dir)); err != nil {
+				return err
+			}
+		}
 		return os.RemoveAll(filepath.Join(base, "deps"))
 	case "tools":
+		if err := os.RemoveAll(filepath.Join(base, "sources")); err != nil {
+			return err
+		}
 		return os.RemoveAll(filepath.Join(base, "tools"))
 	case "index":
+		for _, dir := range []string{"packagist", "releases"} {
+			if err := os.RemoveAll(filepath.Join(base, dir)); err != nil {
+				return err
+			}
+		}
 		return os.RemoveAll(filepath.Join(base, "index"))
 	case "composer":
 		return os.RemoveAll(filepath.Join(base, "composer"))
+	case "store":
+		if err := os.RemoveAll(filepath.Join(base, "composer-cache")); err != nil {
+			return err
+		}
+		return os.RemoveAll(filepath.Join(base, "store"))
 	case "all":
-		return os.RemoveAll(base)
+		// Installed tools and their shims are removed with 'phpx tool uninstall'
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		for _, e := range entries {
+			if e.Name() == "bin" || e.Name() == "installed" {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(base, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
 	default:
 		// Default to tools only
-		return os.RemoveAll(filepath.Join(base, "tools"))
+		return Clean("tools")
 	}
 }
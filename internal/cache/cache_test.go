@@ -71,52 +71,128 @@ func TestToolPath(t *testing.T) {
 			t.Errorf("got %q, want to contain phpstan-phpstan-1.10.0", path)
 		}
 	})
+
+	t.Run("separates installs with different variants", func(t *testing.T) {
+		plain, _ := ToolPath("phpstan/phpstan", "1.10.0")
+		withA, _ := ToolPath("phpstan/phpstan", "1.10.0", "with:phpstan/phpstan-symfony")
+		withB, _ := ToolPath("phpstan/phpstan", "1.10.0", "with:phpstan/phpstan-doctrine")
+
+		if plain == withA || withA == withB {
+			t.Errorf("got %q, %q, %q, want distinct paths", plain, withA, withB)
+		}
+		if !strings.HasPrefix(filepath.Base(withA), "phpstan-phpstan-1.10.0-") {
+			t.Errorf("got %q, want phpstan-phpstan-1.10.0- prefix", withA)
+		}
+	})
+}
+
+func TestEvalPath(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+
+	first, err := EvalPath([]byte("<?php echo 1;\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, _ := EvalPath([]byte("<?php echo 1;\n"))
+	other, _ := EvalPath([]byte("<?php echo 2;\n"))
+
+	if filepath.Dir(first) != "/home/me/.phpx/eval" || filepath.Ext(first) != ".php" {
+		t.Errorf("got %s, want a .php file in /home/me/.phpx/eval", first)
+	}
+	if first != again {
+		t.Errorf("same code got different paths: %s and %s", first, again)
+	}
+	if first == other {
+		t.Errorf("different code got the same path: %s", first)
+	}
 }
 
 func TestDepsHash(t *testing.T) {
+	base := DepsKey{
+		Packages:   []string{"vendor/a:^1.0", "vendor/b:^2.0"},
+		PHPVersion: "8.4",
+		Extensions: []string{"redis"},
+	}
+
 	tests := []struct {
 		name     string
-		packages []string
+		key      DepsKey
 		wantSame bool
-		compare  []string
+		compare  DepsKey
 	}{
 		{
-			name: "produces deterministic hash",
-			packages: []string{"vendor/a:^1.0", "vendor/b:^2.0"},
+			name:     "produces deterministic hash",
+			key:      base,
 			wantSame: true,
-			compare:  []string{"vendor/a:^1.0", "vendor/b:^2.0"},
+			compare:  base,
 		},
 		{
-			name: "produces same hash regardless of order",
-			packages: []string{"vendor/b:^2.0", "vendor/a:^1.0"},
+			name:     "produces same hash regardless of order",
+			key:      DepsKey{Packages: []string{"vendor/b:^2.0", "vendor/a:^1.0"}, PHPVersion: "8.4", Extensions: []string{"redis"}},
 			wantSame: true,
-			compare:  []string{"vendor/a:^1.0", "vendor/b:^2.0"},
+			compare:  base,
 		},
 		{
-			name: "produces same hash regardless of case",
-			packages: []string{"Vendor/A:^1.0"},
+			name:     "produces same hash regardless of case",
+			key:      DepsKey{Packages: []string{"Vendor/A:^1.0"}},
 			wantSame: true,
-			compare:  []string{"vendor/a:^1.0"},
+			compare:  DepsKey{Packages: []string{"vendor/a:^1.0"}},
+		},
+		{
+			name:     "produces different hash for different packages",
+			key:      DepsKey{Packages: []string{"vendor/a:^1.0"}},
+			wantSame: false,
+			compare:  DepsKey{Packages: []string{"vendor/b:^1.0"}},
+		},
+		{
+			name:     "produces different hash for different php minor versions",
+			key:      base,
+			wantSame: false,
+			compare:  DepsKey{Packages: base.Packages, PHPVersion: "8.1", Extensions: base.Extensions},
+		},
+		{
+			name:     "produces different hash for different extensions",
+			key:      base,
+			wantSame: false,
+			compare:  DepsKey{Packages: base.Packages, PHPVersion: "8.4", Extensions: []string{"redis", "intl"}},
+		},
+		{
+			name:     "produces different hash for different repositories",
+			key:      base,
+			wantSame: false,
+			compare:  DepsKey{Packages: base.Packages, PHPVersion: "8.4", Extensions: base.Extensions, Repositories: []string{"vcs:https://example.com/repo.git"}},
+		},
+		{
+			name:     "produces different hash for different stability",
+			key:      base,
+			wantSame: false,
+			compare:  DepsKey{Packages: base.Packages, PHPVersion: "8.4", Extensions: base.Extensions, MinimumStability: "dev"},
+		},
+		{
+			name:     "produces different hash for allowed plugins",
+			key:      DepsKey{Packages: []string{"vendor/a:^1.0"}, AllowPlugins: []string{"php-http/discovery=true"}},
+			wantSame: false,
+			compare:  DepsKey{Packages: []string{"vendor/a:^1.0"}},
 		},
 		{
-			name: "produces different hash for different packages",
-			packages: []string{"vendor/a:^1.0"},
+			name:     "produces different hash when scripts are allowed",
+			key:      DepsKey{Packages: []string{"vendor/a:^1.0"}, AllowScripts: true},
 			wantSame: false,
-			compare:  []string{"vendor/b:^1.0"},
+			compare:  DepsKey{Packages: []string{"vendor/a:^1.0"}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hash1 := DepsHash(tt.packages)
+			hash1 := DepsHash(tt.key)
 			hash2 := DepsHash(tt.compare)
 
 			if tt.wantSame && hash1 != hash2 {
-				t.Errorf("DepsHash(%v) = %s, DepsHash(%v) = %s, want same", tt.packages, hash1, tt.compare, hash2)
+				t.Errorf("DepsHash(%v) = %s, DepsHash(%v) = %s, want same", tt.key, hash1, tt.compare, hash2)
 			}
 
 			if !tt.wantSame && hash1 == hash2 {
-				t.Errorf("DepsHash(%v) = DepsHash(%v), want different", tt.packages, tt.compare)
+				t.Errorf("DepsHash(%v) = DepsHash(%v), want different", tt.key, tt.compare)
 			}
 		})
 	}
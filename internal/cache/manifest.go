@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the digest manifest written into each installation.
+const ManifestFile = ".phpx-manifest.json"
+
+// Manifest records content digests of an installation at install time so
+// later corruption or tampering can be detected.
+type Manifest struct {
+	// PHPBinary is the PHP binary the installation was created with, used to repair it.
+	PHPBinary string `json:"php_binary,omitempty"`
+
+	// Files maps slash-separated paths relative to the installation to SHA-256 digests.
+	Files map[string]string `json:"files"`
+}
+
+// ErrUnverified is returned by VerifyFiles for installations created before
+// digests were recorded, which have no manifest to check against.
+var ErrUnverified = errors.New("no digests recorded")
+
+// Drift describes a file whose contents no longer match its recorded digest.
+type Drift struct {
+	Path   string
+	Reason string // "modified" or "missing"
+}
+
+// WriteManifest records digests for every regular file under the given
+// paths (relative to dir) and writes the manifest into dir.
+func WriteManifest(dir, phpBinary string, paths ...string) error {
+	m := &Manifest{PHPBinary: phpBinary, Files: make(map[string]string)}
+
+	for _, p := range paths {
+		root := filepath.Join(dir, p)
+		if !Exists(root) {
+			continue
+		}
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			digest, err := FileDigest(path)
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			m.Files[filepath.ToSlash(rel)] = digest
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644)
+}
+
+// ReadManifest loads the manifest from an installation directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+	return &m, nil
+}
+
+// VerifyManifest recomputes every digest recorded for an installation and
+// returns the files that have drifted, sorted by path.
+func VerifyManifest(dir string) ([]Drift, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var drift []Drift
+	for _, p := range paths {
+		if d := checkDigest(dir, p, m.Files[p]); d != nil {
+			drift = append(drift, *d)
+		}
+	}
+	return drift, nil
+}
+
+// VerifyFiles checks specific files of an installation against its manifest.
+// Symlinks are resolved so an entrypoint such as vendor/bin/tool is checked
+// against the file it points to.
+func VerifyFiles(dir string, files ...string) error {
+	m, err := ReadManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", dir, ErrUnverified)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot verify %s: %w", dir, err)
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			if rel, err := filepath.Rel(resolvedDir, resolved); err == nil && !strings.HasPrefix(rel, "..") {
+				f = rel
+			}
+		}
+
+		rel := filepath.ToSlash(f)
+		want, ok := m.Files[rel]
+		if !ok {
+			return fmt.Errorf("%s is not recorded in the manifest for %s", rel, dir)
+		}
+		if d := checkDigest(dir, rel, want); d != nil {
+			return fmt.Errorf("%s in %s is %s (run 'phpx cache verify --repair')", d.Path, dir, d.Reason)
+		}
+	}
+	return nil
+}
+
+// checkDigest compares a file against its recorded digest.
+func checkDigest(dir, rel, want string) *Drift {
+	got, err := FileDigest(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return &Drift{Path: rel, Reason: "missing"}
+	}
+	if got != want {
+		return &Drift{Path: rel, Reason: "modified"}
+	}
+	return nil
+}
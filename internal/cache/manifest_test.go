@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyManifest(t *testing.T) {
+	t.Run("reports no drift for untouched installation", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "vendor", "autoload.php"), "<?php", 0644)
+
+		if err := WriteManifest(dir, "/usr/bin/php", "vendor"); err != nil {
+			t.Fatalf("WriteManifest() error: %v", err)
+		}
+
+		drift, err := VerifyManifest(dir)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(drift) != 0 {
+			t.Errorf("got drift %v, want none", drift)
+		}
+	})
+
+	t.Run("reports modified and missing files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "vendor", "a.php"), "a", 0644)
+		writeTestFile(t, filepath.Join(dir, "vendor", "b.php"), "b", 0644)
+		_ = WriteManifest(dir, "", "vendor")
+
+		writeTestFile(t, filepath.Join(dir, "vendor", "a.php"), "tampered", 0644)
+		_ = os.Remove(filepath.Join(dir, "vendor", "b.php"))
+
+		drift, err := VerifyManifest(dir)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(drift) != 2 {
+			t.Fatalf("got %d drifted files, want 2", len(drift))
+		}
+		if drift[0].Path != "vendor/a.php" || drift[0].Reason != "modified" {
+			t.Errorf("drift[0] = %+v, want vendor/a.php modified", drift[0])
+		}
+		if drift[1].Path != "vendor/b.php" || drift[1].Reason != "missing" {
+			t.Errorf("drift[1] = %+v, want vendor/b.php missing", drift[1])
+		}
+	})
+
+	t.Run("returns error when manifest is absent", func(t *testing.T) {
+		_, err := VerifyManifest(t.TempDir())
+
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestVerifyFiles(t *testing.T) {
+	t.Run("checks symlinked entrypoint against its target", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "vendor", "acme", "tool", "bin", "tool")
+		writeTestFile(t, target, "#!/usr/bin/env php", 0755)
+		link := filepath.Join(dir, "vendor", "bin", "tool")
+		_ = os.MkdirAll(filepath.Dir(link), 0755)
+		if err := os.Symlink("../acme/tool/bin/tool", link); err != nil {
+			t.Fatalf("Symlink() error: %v", err)
+		}
+		_ = WriteManifest(dir, "", "vendor")
+
+		if err := VerifyFiles(dir, "vendor/bin/tool"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		writeTestFile(t, target, "tampered", 0755)
+
+		if err := VerifyFiles(dir, "vendor/bin/tool"); err == nil {
+			t.Error("expected error for tampered entrypoint, got nil")
+		}
+	})
+
+	t.Run("reports installs without a manifest as unverified", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "bin", "php"), "binary", 0755)
+
+		err := VerifyFiles(dir, "bin/php")
+
+		if !errors.Is(err, ErrUnverified) {
+			t.Errorf("got %v, want ErrUnverified", err)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Dedupe replaces regular files under dir with hardlinks into the shared
+// content-addressed store, so identical package files across deps and tool
+// installations occupy disk space only once. Linked files are made
+// read-only, since an edit to one would change every installation sharing it.
+func Dedupe(dir string) error {
+	store, err := StoreDir()
+	if err != nil {
+		return err
+	}
+	return dedupe(dir, store)
+}
+
+// PruneStore removes store entries that are no longer linked from any
+// deps or tool installation.
+func PruneStore() error {
+	store, err := StoreDir()
+	if err != nil {
+		return err
+	}
+	return pruneStore(store)
+}
+
+// EvictDrift removes the store entries for an installation's drifted files,
+// named by the digests its manifest recorded. A file edited in place changes
+// the store entry it is linked to, which would otherwise be linked back over
+// the file when the installation is repaired.
+func EvictDrift(dir string, drift []Drift) error {
+	store, err := StoreDir()
+	if err != nil {
+		return err
+	}
+	return evictDrift(dir, store, drift)
+}
+
+func evictDrift(dir, store string, drift []Drift) error {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range drift {
+		digest, ok := m.Files[d.Path]
+		if !ok {
+			continue
+		}
+		for _, key := range []string{digest, digest + "-x"} {
+			if err := os.Remove(filepath.Join(store, key[:2], key)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dedupe(dir, store string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Composer rewrites its own autoload files in place
+		if d.IsDir() && d.Name() == "composer" && filepath.Dir(path) == dir {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		digest, err := FileDigest(path)
+		if err != nil {
+			return err
+		}
+
+		// Files with identical content but different permissions must not share an inode
+		key := digest
+		if info.Mode().Perm()&0111 != 0 {
+			key += "-x"
+		}
+
+		target := filepath.Join(store, key[:2], key)
+		if !Exists(target) {
+			if err := EnsureDir(filepath.Dir(target)); err != nil {
+				return err
+			}
+			// Linking fails across filesystems; keep the plain copy in that case
+			if err := os.Link(path, target); err != nil {
+				return nil
+			}
+			return seal(path, info)
+		}
+
+		if sameFile(path, target) {
+			return seal(path, info)
+		}
+
+		// A store entry shares its inode with every installation linked to
+		// it, so a file edited in place changes the entry too. Check it still
+		// holds what its name says before linking, and replace it if not.
+		stored, err := FileDigest(target)
+		if err != nil {
+			return err
+		}
+		if stored != digest {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+			if err := os.Link(path, target); err != nil {
+				return nil
+			}
+			return seal(path, info)
+		}
+
+		// Link into place via a temporary name so the swap is atomic
+		tmp := path + ".phpx-link"
+		if err := os.Link(target, tmp); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		return seal(path, info)
+	})
+}
+
+// seal makes a file linked into the store read-only. Its inode is shared by
+// every installation with the same content, so an in-place edit, such as
+// patching vendor/ while debugging, would otherwise change all of them.
+func seal(path string, info fs.FileInfo) error {
+	return os.Chmod(path, info.Mode().Perm()&^0222)
+}
+
+func pruneStore(store string) error {
+	if !Exists(store) {
+		return nil
+	}
+
+	return filepath.WalkDir(store, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		// A link count of one means only the store references the file
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink <= 1 {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// FileDigest returns the hex-encoded SHA-256 digest of a file's contents.
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sameFile reports whether two paths refer to the same inode.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	t.Run("links identical files to a single inode", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		a := filepath.Join(tmpDir, "deps-a", "vendor", "pkg", "src.php")
+		b := filepath.Join(tmpDir, "deps-b", "vendor", "pkg", "src.php")
+		writeTestFile(t, a, "<?php echo 1;", 0644)
+		writeTestFile(t, b, "<?php echo 1;", 0644)
+
+		if err := dedupe(filepath.Join(tmpDir, "deps-a"), store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := dedupe(filepath.Join(tmpDir, "deps-b"), store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !sameFile(a, b) {
+			t.Error("identical files were not linked together")
+		}
+
+		data, err := os.ReadFile(b)
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		if string(data) != "<?php echo 1;" {
+			t.Errorf("got %q, want original content", data)
+		}
+
+		for _, path := range []string{a, b} {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Stat() error: %v", err)
+			}
+			if info.Mode().Perm()&0222 != 0 {
+				t.Errorf("%s has mode %v, want read-only", path, info.Mode().Perm())
+			}
+		}
+	})
+
+	t.Run("leaves Composer's autoload files writable", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		autoload := filepath.Join(tmpDir, "vendor", "composer", "autoload_real.php")
+		writeTestFile(t, autoload, "<?php", 0644)
+
+		if err := dedupe(filepath.Join(tmpDir, "vendor"), store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		info, err := os.Stat(autoload)
+		if err != nil {
+			t.Fatalf("Stat() error: %v", err)
+		}
+		if info.Mode().Perm() != 0644 {
+			t.Errorf("got mode %v, want 0644", info.Mode().Perm())
+		}
+	})
+
+	t.Run("keeps files with different permissions separate", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		a := filepath.Join(tmpDir, "a", "bin")
+		b := filepath.Join(tmpDir, "b", "bin")
+		writeTestFile(t, a, "#!/bin/sh", 0755)
+		writeTestFile(t, b, "#!/bin/sh", 0644)
+
+		_ = dedupe(filepath.Join(tmpDir, "a"), store)
+		_ = dedupe(filepath.Join(tmpDir, "b"), store)
+
+		if sameFile(a, b) {
+			t.Error("executable and non-executable files were linked together")
+		}
+	})
+
+	t.Run("does not link to a store entry that was modified in place", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		a := filepath.Join(tmpDir, "a", "src.php")
+		b := filepath.Join(tmpDir, "b", "src.php")
+		writeTestFile(t, a, "<?php echo 1;", 0644)
+		writeTestFile(t, b, "<?php echo 1;", 0644)
+
+		_ = dedupe(filepath.Join(tmpDir, "a"), store)
+		// Editing a's file in place, past its read-only mode, edits the
+		// store entry it is linked to
+		_ = os.Chmod(a, 0644)
+		if err := os.WriteFile(a, []byte("<?php echo 2;"), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+
+		if err := dedupe(filepath.Join(tmpDir, "b"), store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(b)
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		if string(data) != "<?php echo 1;" {
+			t.Errorf("got %q, want original content", data)
+		}
+		if sameFile(a, b) {
+			t.Error("file was linked to the modified store entry")
+		}
+
+		// The entry now holds b's intact content again
+		c := filepath.Join(tmpDir, "c", "src.php")
+		writeTestFile(t, c, "<?php echo 1;", 0644)
+		_ = dedupe(filepath.Join(tmpDir, "c"), store)
+		if !sameFile(b, c) {
+			t.Error("replaced store entry was not reused")
+		}
+	})
+}
+
+func TestEvictDrift(t *testing.T) {
+	t.Run("lets a repaired install replace a corrupted deduplicated file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		dir := filepath.Join(tmpDir, "deps")
+		file := filepath.Join(dir, "vendor", "pkg", "src.php")
+		writeTestFile(t, file, "<?php echo 1;", 0644)
+		_ = WriteManifest(dir, "", "vendor")
+		_ = dedupe(filepath.Join(dir, "vendor"), store)
+
+		// Editing the file in place, past its read-only mode, corrupts the
+		// store entry it is linked to
+		_ = os.Chmod(file, 0644)
+		if err := os.WriteFile(file, []byte("<?php echo 2;"), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+		drift, _ := VerifyManifest(dir)
+		if len(drift) != 1 {
+			t.Fatalf("got %d drifted files, want 1", len(drift))
+		}
+
+		if err := evictDrift(dir, store, drift); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Reinstalling writes a fresh copy, which is then deduplicated
+		_ = os.Remove(file)
+		writeTestFile(t, file, "<?php echo 1;", 0644)
+		if err := dedupe(filepath.Join(dir, "vendor"), store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		drift, _ = VerifyManifest(dir)
+		if len(drift) != 0 {
+			t.Errorf("got drift %v after repair, want none", drift)
+		}
+	})
+}
+
+func TestPruneStore(t *testing.T) {
+	t.Run("removes entries no longer linked from an installation", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := filepath.Join(tmpDir, "store")
+		kept := filepath.Join(tmpDir, "kept", "file.php")
+		removed := filepath.Join(tmpDir, "removed", "file.php")
+		writeTestFile(t, kept, "kept", 0644)
+		writeTestFile(t, removed, "removed", 0644)
+
+		_ = dedupe(filepath.Join(tmpDir, "kept"), store)
+		_ = dedupe(filepath.Join(tmpDir, "removed"), store)
+		_ = os.RemoveAll(filepath.Join(tmpDir, "removed"))
+
+		if err := pruneStore(store); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var entries int
+		_ = filepath.Walk(store, func(_ string, info os.FileInfo, _ error) error {
+			if info != nil && !info.IsDir() {
+				entries++
+			}
+			return nil
+		})
+
+		if entries != 1 {
+			t.Errorf("got %d store entries, want 1", entries)
+		}
+		if !Exists(kept) {
+			t.Error("linked installation file was removed")
+		}
+	})
+}
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/alias"
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var toolAliasesCmd = &cobra.Command{
+	Use:   "aliases",
+	Short: "List tool aliases",
+	Long: `List the tool aliases phpx knows: the built-in aliases, those defined in
+the user alias file and those under [aliases] in the project's phpx.toml.
+Later sources override earlier ones.
+
+The user alias file is aliases.toml in the phpx config directory
+(e.g. ~/.config/phpx/aliases.toml), with one table per alias:
+
+    [deploy]
+    package = "acme/deploy-cli"
+    binary = "deploy"
+    php = "^8.2"
+    extensions = ["intl"]
+    with = ["acme/deploy-plugins"]
+    sandbox = true
+    allow-host = ["api.acme.com"]
+
+Aliases may also set offline, allow-read, allow-write and allow-env. An
+alias without a package adds defaults to the alias it overrides.
+
+Project aliases may only add new names and tighten the sandbox: their
+allow-* settings are ignored, and for a built-in or user alias only sandbox
+and offline apply.`,
+	Args: cobra.NoArgs,
+	RunE: listAliases,
+}
+
+func init() {
+	toolCmd.AddCommand(toolAliasesCmd)
+}
+
+func listAliases(cmd *cobra.Command, args []string) error {
+	m, err := manifest.Find(".")
+	if err != nil {
+		return err
+	}
+
+	aliases, err := loadAliases(m)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for name := range aliases {
+		width = max(width, len(name))
+	}
+
+	for _, name := range aliases.Names() {
+		a := aliases[name]
+		fmt.Printf("%-*s  %s", width, name, a.Package)
+		if a.Origin != alias.Builtin {
+			fmt.Printf("  (%s)", a.Origin)
+		}
+		fmt.Println()
+
+		for _, d := range aliasDefaults(&a) {
+			fmt.Printf("%-*s    %s\n", width, "", d)
+		}
+	}
+
+	return nil
+}
+
+// aliasDefaults describes the defaults an alias sets, one per line.
+func aliasDefaults(a *alias.Alias) []string {
+	var out []string
+	add := func(label string, values ...string) {
+		if values = nonEmpty(values); len(values) > 0 {
+			out = append(out, label+": "+strings.Join(values, ", "))
+		}
+	}
+
+	add("binary", a.Binary)
+	add("php", a.PHP)
+	add("extensions", a.Extensions...)
+	add("with", a.With...)
+	if a.Sandbox {
+		out = append(out, "sandbox")
+	}
+	if a.Offline {
+		out = append(out, "offline")
+	}
+	add("allow-host", a.AllowHost...)
+	add("allow-read", a.AllowRead...)
+	add("allow-write", a.AllowWrite...)
+	add("allow-env", a.AllowEnv...)
+	return out
+}
+
+// loadAliases merges the built-in aliases with those from the user alias
+// file and the project manifest, and hands them to the manifest to resolve
+// its tools' packages with.
+func loadAliases(m *manifest.Manifest) (alias.Registry, error) {
+	aliases := alias.Builtins()
+
+	path, err := alias.UserFile()
+	if err == nil {
+		user, err := alias.LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		aliases = aliases.Merge(user)
+	}
+
+	// A cloned repository's phpx.toml may not loosen the sandbox, repoint
+	// aliases the user already has or enable plugins and scripts unasked
+	if m != nil {
+		ignoredTools := m.Confine(aliases, toolTrustProject)
+		project, ignored := m.Aliases.Confine(aliases)
+		if !quiet {
+			for _, setting := range ignoredTools {
+				fmt.Fprintf(os.Stderr, "[phpx] Warning: ignoring project tool %s\n", setting)
+			}
+			for _, setting := range ignored {
+				fmt.Fprintf(os.Stderr, "[phpx] Warning: ignoring project alias %s\n", setting)
+			}
+		}
+		aliases = aliases.Merge(project)
+	}
+
+	if m != nil {
+		m.UseAliases(aliases)
+	}
+	return aliases, nil
+}
+
+// configuredAlias returns the user or project alias for a tool argument.
+// Built-in aliases carry no defaults and are resolved by the composer package.
+func configuredAlias(aliases alias.Registry, arg string) (string, *alias.Alias, bool) {
+	name, _, src := composer.ParseToolArg(arg)
+	if src != nil {
+		return "", nil, false
+	}
+
+	key, a, ok := aliases.Lookup(name)
+	if !ok || a.Origin == alias.Builtin {
+		return "", nil, false
+	}
+	return key, a, true
+}
+
+// applyAlias fills in a tool's settings from a configured alias. Settings
+// given on the command line or in phpx.toml take precedence.
+func applyAlias(spec *toolSpec, name string, a *alias.Alias) {
+	// Expand the alias unless phpx.toml already named the package. Built-in
+	// packages are left to prepareTool, which resolves PHAR release tags.
+	pkg, _, _ := composer.ParseToolArg(spec.Arg)
+	if pkg == name && a.Package != "" && a.Package != alias.Builtins()[name].Package {
+		if _, _, src := composer.ParseToolArg(a.Package); src != nil {
+			spec.Arg = a.Package
+		} else {
+			spec.Arg = a.Package + strings.TrimPrefix(spec.Arg, name)
+		}
+	}
+
+	if spec.From == "" {
+		spec.From = a.Binary
+	}
+	if spec.PHP == "" {
+		spec.PHP = a.PHP
+	}
+	for _, ext := range a.Extensions {
+		if !slices.Contains(spec.Extensions, ext) {
+			spec.Extensions = append(spec.Extensions, ext)
+		}
+	}
+	if len(spec.With) == 0 {
+		spec.With = a.With
+	}
+}
+
+// applyAliasSandbox applies an alias's sandbox defaults to the security
+// flags that were not given on the command line.
+func applyAliasSandbox(a *alias.Alias) {
+	toolSandbox = toolSandbox || a.Sandbox
+	toolOffline = toolOffline || a.Offline
+	if toolAllowHost == "" {
+		toolAllowHost = strings.Join(a.AllowHost, ",")
+	}
+	if toolAllowRead == "" {
+		toolAllowRead = strings.Join(a.AllowRead, ",")
+	}
+	if toolAllowWrite == "" {
+		toolAllowWrite = strings.Join(a.AllowWrite, ",")
+	}
+	if toolAllowEnv == "" {
+		toolAllowEnv = strings.Join(a.AllowEnv, ",")
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/index"
+)
+
+// listToolBins prints the binaries a tool's package ships, marking the one
+// phpx runs by default.
+func listToolBins(idx *index.Index, spec *toolSpec) error {
+	rt, err := resolveTool(idx, spec)
+	if err != nil {
+		return err
+	}
+
+	bins := rt.Version.Bin
+	if len(bins) == 0 {
+		return fmt.Errorf("binary not found in package: %s", rt.Package)
+	}
+
+	header := rt.Package
+	if rt.Version.Version != "" {
+		header += " " + rt.Version.Version
+	}
+	if rt.Version.Description != "" {
+		header += " - " + rt.Version.Description
+	}
+	fmt.Println(header)
+	fmt.Println()
+
+	def, _ := composer.InferBinary(rt.Package, bins, spec.From, rt.Requested)
+
+	width := 0
+	for _, bin := range bins {
+		width = max(width, len(filepath.Base(bin)))
+	}
+	for _, bin := range bins {
+		name := filepath.Base(bin)
+		line := fmt.Sprintf("  %-*s  %s", width, name, bin)
+		if name == def {
+			line += "  (default)"
+		}
+		fmt.Println(line)
+	}
+
+	if len(bins) > 1 && rt.Src == nil {
+		fmt.Printf("\nRun one with: phpx tool %s:<binary>\n", rt.Requested)
+	}
+	return nil
+}
+
+// selectBinary picks a binary when a package ships several and none is
+// named after it. On a terminal the user chooses; otherwise the first binary
+// runs, with a warning naming the alternatives.
+func selectBinary(amb *composer.AmbiguousBinaryError) (string, error) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "[phpx] Warning: %v; running %s\n", amb, amb.Binaries[0])
+		}
+		return amb.Binaries[0], nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%s has several binaries:\n", amb.Package)
+	for i, bin := range amb.Binaries {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, bin)
+	}
+	fmt.Fprint(os.Stderr, "Select a binary [1]: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no binary selected")
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return amb.Binaries[0], nil
+	}
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(amb.Binaries) {
+		return amb.Binaries[n-1], nil
+	}
+	for _, bin := range amb.Binaries {
+		if bin == answer {
+			return bin, nil
+		}
+	}
+	return "", fmt.Errorf("unknown binary %q; choose one of %s", answer, strings.Join(amb.Binaries, ", "))
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/bundle"
+	"github.com/eddmann/phpx/internal/metadata"
+	"github.com/eddmann/phpx/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleOutput     string
+	bundlePHP        string
+	bundlePackages   string
+	bundleExtensions string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle <script.php> [-o tool.phar]",
+	Short: "Bundle a script and its dependencies into a PHAR",
+	Long: `Bundle a script, the local files it includes and its dependencies into a
+single executable PHAR that runs anywhere PHP does, without phpx.
+
+Examples:
+    phpx bundle script.php
+    phpx bundle script.php -o tool.phar
+    phpx bundle script.php --php '>=8.2' --packages symfony/console
+
+PHP and dependencies are resolved as for 'phpx run', and the PHAR is built
+with the managed PHP, so no local PHP is needed. Package tests, docs and
+VCS metadata are left out. When run, the PHAR checks the PHP version and
+extensions the script declares before running it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBundle,
+}
+
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleOutput, "output", "o", "", "PHAR to write (default: the script's name with .phar)")
+	bundleCmd.Flags().StringVar(&bundlePHP, "php", "", "PHP version constraint (overrides script)")
+	bundleCmd.Flags().StringVar(&bundlePackages, "packages", "", "comma-separated packages to add")
+	bundleCmd.Flags().StringVar(&bundleExtensions, "extensions", "", "comma-separated PHP extensions")
+
+	rootCmd.AddCommand(bundleCmd)
+}
+
+func runBundle(cmd *cobra.Command, args []string) error {
+	scriptPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	output := bundleOutput
+	if output == "" {
+		output = scriptName(scriptPath) + ".phar"
+	}
+
+	result, _, err := bundleScript(scriptPath, bundlePHP, bundlePackages, bundleExtensions, output)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "[phpx] Bundled %s into %s (%d files, %s)\n",
+			filepath.Base(scriptPath), output, result.Files, formatSize(result.Size))
+	}
+	return nil
+}
+
+// bundleScript resolves PHP and installs dependencies for a script as
+// 'phpx run' does, with the --php, --packages and --extensions flags given,
+// and builds its PHAR at output.
+func bundleScript(scriptPath, phpFlag, packagesFlag, extensionsFlag, output string) (*bundle.Result, *scriptEnv, error) {
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("script not found: %s", scriptPath)
+	}
+
+	meta, err := metadata.Parse(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	// Merge CLI flags with metadata
+	phpConstraint := phpFlag
+	if phpConstraint == "" {
+		phpConstraint = meta.PHP
+	}
+
+	packages := meta.Packages
+	if packagesFlag != "" {
+		packages = append(packages, strings.Split(packagesFlag, ",")...)
+	}
+
+	extensions := meta.Extensions
+	if extensionsFlag != "" {
+		extensions = append(extensions, strings.Split(extensionsFlag, ",")...)
+	}
+
+	env, err := prepareScript(meta, phpConstraint, packages, extensions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vendorDir := ""
+	if env.Autoload != "" {
+		vendorDir = filepath.Dir(env.Autoload)
+	}
+
+	result, err := bundle.Build(&bundle.Options{
+		Script:     scriptPath,
+		Includes:   watch.Includes(scriptPath)[1:],
+		VendorDir:  vendorDir,
+		PHPBinary:  env.PHP.Path,
+		PHP:        phpConstraint,
+		Extensions: extensions,
+		Output:     output,
+		Verbose:    verbose,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, env, nil
+}
+
+// scriptName returns a script's file name without its extension.
+func scriptName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
@@ -1,21 +1,28 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/php"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cleanPHP     bool
-	cleanDeps    bool
-	cleanIndex   bool
-	cleanAll     bool
+	cleanPHP   bool
+	cleanDeps  bool
+	cleanIndex bool
+	cleanStore bool
+	cleanAll   bool
+
+	verifyRepair bool
 )
 
 var cacheCmd = &cobra.Command{
@@ -36,10 +43,11 @@ var cacheCleanCmd = &cobra.Command{
 	Long: `Remove cached items. By default, removes tool cache only.
 
 Flags:
-    --php     Remove PHP binaries
-    --deps    Remove dependencies
-    --index   Remove index cache (forces re-fetch)
-    --all     Remove everything`,
+    --php     Remove PHP binaries and micro SAPIs
+    --deps    Remove dependencies, inline code and remote scripts
+    --index   Remove index and Packagist metadata cache (forces re-fetch)
+    --store   Remove shared download cache and package store
+    --all     Remove everything except installed tools`,
 	RunE: cacheClean,
 }
 
@@ -55,16 +63,35 @@ var cacheRefreshCmd = &cobra.Command{
 	RunE:  cacheRefresh,
 }
 
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check cached items against recorded digests",
+	Long: `Recompute digests of cached PHP binaries, dependencies and tools and
+report any file that no longer matches what was recorded at install time.
+
+Flags:
+    --repair  Reinstall entries that fail verification
+
+Set PHPX_VERIFY_ON_RUN=1 to also check the PHP binary and tool entrypoint
+before every execution.`,
+	Args: cobra.NoArgs,
+	RunE: cacheVerify,
+}
+
 func init() {
-	cacheCleanCmd.Flags().BoolVar(&cleanPHP, "php", false, "remove PHP binaries")
-	cacheCleanCmd.Flags().BoolVar(&cleanDeps, "deps", false, "remove dependencies")
+	cacheVerifyCmd.Flags().BoolVar(&verifyRepair, "repair", false, "reinstall entries that fail verification")
+
+	cacheCleanCmd.Flags().BoolVar(&cleanPHP, "php", false, "remove PHP binaries and micro SAPIs")
+	cacheCleanCmd.Flags().BoolVar(&cleanDeps, "deps", false, "remove dependencies, inline code and remote scripts")
 	cacheCleanCmd.Flags().BoolVar(&cleanIndex, "index", false, "remove index cache")
-	cacheCleanCmd.Flags().BoolVar(&cleanAll, "all", false, "remove everything")
+	cacheCleanCmd.Flags().BoolVar(&cleanStore, "store", false, "remove shared download cache and package store")
+	cacheCleanCmd.Flags().BoolVar(&cleanAll, "all", false, "remove everything except installed tools")
 
 	cacheCmd.AddCommand(cacheListCmd)
 	cacheCmd.AddCommand(cacheCleanCmd)
 	cacheCmd.AddCommand(cacheDirCmd)
 	cacheCmd.AddCommand(cacheRefreshCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
 
 	rootCmd.AddCommand(cacheCmd)
 }
@@ -136,6 +163,20 @@ func cacheList(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
+	// Shared download cache and deduplicated package store
+	composerCacheDir, _ := cache.ComposerCacheDir()
+	storeDir, _ := cache.StoreDir()
+	if cache.Exists(composerCacheDir) || cache.Exists(storeDir) {
+		fmt.Println("Shared:")
+		if cache.Exists(composerCacheDir) {
+			fmt.Printf("  downloads (%s)\n", formatSize(dirSize(composerCacheDir)))
+		}
+		if cache.Exists(storeDir) {
+			fmt.Printf("  package store (%s)\n", formatSize(dirSize(storeDir)))
+		}
+		fmt.Println()
+	}
+
 	// Index
 	indexDir, _ := cache.IndexDir()
 	if cache.Exists(indexDir) {
@@ -185,6 +226,14 @@ func cacheClean(cmd *cobra.Command, args []string) error {
 		cleaned = true
 	}
 
+	if cleanStore {
+		if err := cache.Clean("store"); err != nil {
+			return err
+		}
+		fmt.Println("Removed shared download cache and package store")
+		cleaned = true
+	}
+
 	// Default: clean tools only
 	if !cleaned {
 		if err := cache.Clean("tools"); err != nil {
@@ -193,7 +242,8 @@ func cacheClean(cmd *cobra.Command, args []string) error {
 		fmt.Println("Removed tools")
 	}
 
-	return nil
+	// Drop store entries that were only referenced by removed installations
+	return cache.PruneStore()
 }
 
 func cacheDir(cmd *cobra.Command, args []string) error {
@@ -215,6 +265,145 @@ func cacheRefresh(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func cacheVerify(cmd *cobra.Command, args []string) error {
+	phpDir, err := cache.PHPDir()
+	if err != nil {
+		return err
+	}
+	depsDir, err := cache.DepsDir()
+	if err != nil {
+		return err
+	}
+	toolsDir, err := cache.ToolsDir()
+	if err != nil {
+		return err
+	}
+
+	var checked, drifted, unverified, repaired int
+
+	// PHP binaries are checked first so later repairs reinstall with a sound binary
+	for _, group := range []struct {
+		dir    string
+		repair func(dir string) error
+	}{
+		{phpDir, repairPHP},
+		{depsDir, repairInstall},
+		{toolsDir, repairInstall},
+	} {
+		entries, _ := os.ReadDir(group.dir)
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			dir := filepath.Join(group.dir, e.Name())
+			label := filepath.Join(filepath.Base(group.dir), e.Name())
+			checked++
+
+			drift, err := cache.VerifyManifest(dir)
+			if err != nil {
+				unverified++
+				if verbose {
+					fmt.Printf("  %s: unverified (no digests recorded)\n", label)
+				}
+				continue
+			}
+
+			if len(drift) == 0 {
+				if verbose {
+					fmt.Printf("  %s: ok\n", label)
+				}
+				continue
+			}
+
+			drifted++
+			for _, d := range drift {
+				fmt.Printf("  %s: %s %s\n", label, d.Path, d.Reason)
+			}
+
+			if verifyRepair {
+				if err := cache.EvictDrift(dir, drift); err != nil {
+					fmt.Printf("  %s: repair failed: %v\n", label, err)
+					continue
+				}
+				if err := group.repair(dir); err != nil {
+					fmt.Printf("  %s: repair failed: %v\n", label, err)
+					continue
+				}
+				fmt.Printf("  %s: repaired\n", label)
+				repaired++
+			}
+		}
+	}
+
+	fmt.Printf("Verified %d entries: %d drifted, %d unverified", checked, drifted, unverified)
+	if verifyRepair {
+		fmt.Printf(", %d repaired", repaired)
+	}
+	fmt.Println()
+
+	if drifted > repaired {
+		return fmt.Errorf("cache verification failed for %d entries", drifted-repaired)
+	}
+	return nil
+}
+
+// repairPHP re-downloads a PHP binary directory named {version}-{tier}.
+func repairPHP(dir string) error {
+	name := filepath.Base(dir)
+	idx := strings.LastIndex(name, "-")
+	if idx == -1 {
+		return fmt.Errorf("unrecognised PHP directory %s", name)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	return php.Download(name[:idx], name[idx+1:], filepath.Join(dir, "bin", "php"), !quiet)
+}
+
+// repairInstall reinstalls a deps or tool directory from its lock file,
+// using the PHP binary it was originally installed with.
+func repairInstall(dir string) error {
+	// PHAR tools have no lock file to reinstall from
+	if !cache.Exists(filepath.Join(dir, "composer.json")) {
+		return fmt.Errorf("not a Composer installation; run the tool again after 'phpx cache clean'")
+	}
+
+	phpPath, composerPath, err := installToolchain(dir)
+	if err != nil {
+		return err
+	}
+
+	return composer.Reinstall(phpPath, composerPath, dir, verbose)
+}
+
+// verifyOnRun reports whether PHPX_VERIFY_ON_RUN asks for the PHP binary and
+// tool entrypoint to be verified before every execution.
+func verifyOnRun() bool {
+	switch strings.ToLower(os.Getenv("PHPX_VERIFY_ON_RUN")) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// checkVerified turns a PHPX_VERIFY_ON_RUN verification error into a
+// failure, except for installs created before digests were recorded, which
+// are reported as unverified and allowed to run.
+func checkVerified(what string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, cache.ErrUnverified) {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "[phpx] Warning: %s is unverified: %v\n", what, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s failed verification: %w", what, err)
+}
+
 func dirSize(path string) int64 {
 	var size int64
 	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/eddmann/phpx/internal/bundle"
+	"github.com/eddmann/phpx/internal/php"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compileOutput     string
+	compilePlatform   string
+	compilePHP        string
+	compilePackages   string
+	compileExtensions string
+)
+
+var compileCmd = &cobra.Command{
+	Use:   "compile <script.php> [-o mytool]",
+	Short: "Compile a script into a self-contained executable",
+	Long: `Compile a script and its dependencies into a single native executable
+that runs without PHP installed.
+
+Examples:
+    phpx compile script.php
+    phpx compile script.php -o mytool
+    phpx compile script.php -o mytool-arm --platform linux-aarch64
+
+The script is bundled into a PHAR as with 'phpx bundle', then appended to
+the static PHP micro SAPI (micro.sfx) for the resolved PHP version and
+tier. --platform packages for another OS and architecture (linux-x86_64,
+linux-aarch64, macos-x86_64 or macos-aarch64); micro builds are cached, so
+later builds for a platform need no download.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCompile,
+}
+
+func init() {
+	compileCmd.Flags().StringVarP(&compileOutput, "output", "o", "", "executable to write (default: the script's name)")
+	compileCmd.Flags().StringVar(&compilePlatform, "platform", "", "target platform, e.g. linux-aarch64 (default: this machine)")
+	compileCmd.Flags().StringVar(&compilePHP, "php", "", "PHP version constraint (overrides script)")
+	compileCmd.Flags().StringVar(&compilePackages, "packages", "", "comma-separated packages to add")
+	compileCmd.Flags().StringVar(&compileExtensions, "extensions", "", "comma-separated PHP extensions")
+
+	rootCmd.AddCommand(compileCmd)
+}
+
+func runCompile(cmd *cobra.Command, args []string) error {
+	platform := php.HostPlatform()
+	if compilePlatform != "" {
+		p, err := php.ParsePlatform(compilePlatform)
+		if err != nil {
+			return err
+		}
+		platform = p
+	}
+
+	scriptPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	output := compileOutput
+	if output == "" {
+		output = scriptName(scriptPath)
+	}
+	if abs, err := filepath.Abs(output); err == nil && abs == scriptPath {
+		return fmt.Errorf("output %s would overwrite the script; use -o", output)
+	}
+
+	work, err := os.MkdirTemp("", "phpx-compile-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(work) }()
+
+	phar := filepath.Join(work, filepath.Base(output)+".phar")
+	result, env, err := bundleScript(scriptPath, compilePHP, compilePackages, compileExtensions, phar)
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Using PHP %s micro (%s tier) for %s\n", env.PHP.Version, env.PHP.Tier, platform)
+	}
+
+	micro, err := php.EnsureMicro(env.PHP.Version.String(), env.PHP.Tier, platform, !quiet && !verbose)
+	if err != nil {
+		return err
+	}
+
+	if err := bundle.Executable(micro, phar, output); err != nil {
+		return fmt.Errorf("failed to write executable: %w", err)
+	}
+
+	if !quiet {
+		size := int64(0)
+		if info, err := os.Stat(output); err == nil {
+			size = info.Size()
+		}
+		fmt.Fprintf(os.Stderr, "[phpx] Compiled %s into %s for %s (PHP %s, %d files, %s)\n",
+			filepath.Base(scriptPath), output, platform, env.PHP.Version, result.Files, formatSize(size))
+	}
+	return nil
+}
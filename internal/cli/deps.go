@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/executor"
+	"github.com/eddmann/phpx/internal/index"
+	"github.com/eddmann/phpx/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+// upgradeReportFile holds version changes from a background upgrade until
+// the next run reports them.
+const upgradeReportFile = ".phpx-upgrade.json"
+
+var cacheUpgradeDepsCmd = &cobra.Command{
+	Use:    "upgrade-deps <dir>",
+	Short:  "Re-resolve a cached dependency directory",
+	Hidden: true, // Spawned in the background when PHPX_DEPS_TTL expires
+	Args:   cobra.ExactArgs(1),
+	RunE:   cacheUpgradeDeps,
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheUpgradeDepsCmd)
+
+	composer.RunSandboxed = runComposerSandboxed
+}
+
+// runComposerSandboxed runs installs that enable Composer plugins or scripts
+// in the best available sandbox.
+func runComposerSandboxed(cmd *composer.Command) error {
+	sb := sandbox.Detect()
+	if !sb.IsSandboxed() {
+		return composer.ErrNoSandbox
+	}
+	return executor.RunComposer(context.Background(), sb, cmd)
+}
+
+// composerFor returns the path to a Composer phar compatible with phpVersion,
+// downloading it if necessary.
+func composerFor(idx *index.Index, phpVersion string) (string, error) {
+	cv, err := idx.SelectComposer(phpVersion)
+	if err != nil {
+		return "", err
+	}
+
+	composerPath, err := index.DownloadComposer(cv)
+	if err != nil {
+		return "", fmt.Errorf("failed to download Composer: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Using Composer %s\n", cv.Version)
+	}
+
+	return composerPath, nil
+}
+
+// pathComposer returns a cached Composer for executions to find on PATH, or
+// "" when no compatible one is cached. Nothing is downloaded, so runs
+// without dependencies need no network; any dependency install caches one.
+func pathComposer(idx *index.Index, phpVersion string) string {
+	cv, err := idx.SelectComposer(phpVersion)
+	if err != nil {
+		return ""
+	}
+
+	path, err := cache.ComposerPath(cv.Version)
+	if err != nil || !cache.Exists(path) {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] Composer %s is not cached, so composer will not be on PATH\n", cv.Version)
+		}
+		return ""
+	}
+	return path
+}
+
+// installToolchain returns the PHP binary an installation was created with,
+// and a compatible Composer, as recorded in its manifest.
+func installToolchain(dir string) (phpPath, composerPath string, err error) {
+	m, err := cache.ReadManifest(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if m.PHPBinary == "" || !cache.Exists(m.PHPBinary) {
+		return "", "", fmt.Errorf("PHP binary %q used for the install is no longer cached", m.PHPBinary)
+	}
+
+	// PHP binaries live at php/{version}-{tier}/bin/php
+	phpName := filepath.Base(filepath.Dir(filepath.Dir(m.PHPBinary)))
+	phpVersion := phpName
+	if idx := strings.LastIndex(phpName, "-"); idx != -1 {
+		phpVersion = phpName[:idx]
+	}
+
+	idx, err := index.Load()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load index: %w", err)
+	}
+
+	composerPath, err = composerFor(idx, phpVersion)
+	if err != nil {
+		return "", "", err
+	}
+
+	return m.PHPBinary, composerPath, nil
+}
+
+// depsTTL returns the PHPX_DEPS_TTL duration after which floating
+// constraints are re-resolved in the background, or 0 when unset.
+func depsTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PHPX_DEPS_TTL"))
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// depsStale reports whether a dependency directory with floating
+// constraints has outlived PHPX_DEPS_TTL.
+func depsStale(depsPath string, packages []string) bool {
+	ttl := depsTTL()
+	if ttl == 0 || !composer.HasFloatingConstraint(packages) {
+		return false
+	}
+
+	refreshed, err := composer.RefreshedAt(depsPath)
+	if err != nil {
+		return true
+	}
+	return time.Since(refreshed) > ttl
+}
+
+// startBackgroundUpgrade spawns a detached phpx process that re-resolves
+// depsPath, so the current run continues with the existing tree.
+func startBackgroundUpgrade(depsPath string) {
+	// The lock passes to the spawned process, which releases it
+	if !composer.LockUpgrade(depsPath) {
+		return
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		composer.UnlockUpgrade(depsPath)
+		return
+	}
+
+	cmd := exec.Command(self, "cache", "upgrade-deps", depsPath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		composer.UnlockUpgrade(depsPath)
+		return
+	}
+	_ = cmd.Process.Release()
+
+	if verbose {
+		fmt.Fprintln(os.Stderr, "[phpx] Dependencies older than PHPX_DEPS_TTL, upgrading in the background")
+	}
+}
+
+func cacheUpgradeDeps(cmd *cobra.Command, args []string) error {
+	depsPath := args[0]
+	defer composer.UnlockUpgrade(depsPath)
+
+	phpPath, composerPath, err := installToolchain(depsPath)
+	if err != nil {
+		return err
+	}
+
+	changes, err := composer.Upgrade(phpPath, composerPath, depsPath, false)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(depsPath, upgradeReportFile), data, 0644)
+}
+
+// reportBackgroundUpgrade prints changes recorded by a finished background
+// upgrade of depsPath, then discards the report.
+func reportBackgroundUpgrade(depsPath string) {
+	reportPath := filepath.Join(depsPath, upgradeReportFile)
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return
+	}
+	_ = os.Remove(reportPath)
+
+	var changes []composer.VersionChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return
+	}
+	printVersionChanges("Dependencies upgraded in the background:", changes)
+}
+
+// printVersionChanges reports package version changes on stderr.
+func printVersionChanges(heading string, changes []composer.VersionChange) {
+	if quiet || len(changes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "[phpx] %s\n", heading)
+	for _, c := range changes {
+		fmt.Fprintf(os.Stderr, "[phpx]   %s\n", c)
+	}
+}
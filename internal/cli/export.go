@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/eddmann/phpx/internal/bundle"
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/metadata"
+	"github.com/eddmann/phpx/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var exportTo string
+
+var exportCmd = &cobra.Command{
+	Use:   "export <script.php> [--to dir]",
+	Short: "Export a script to a Composer project",
+	Long: `Export a script and its // phpx metadata to a full Composer project.
+
+Examples:
+    phpx export script.php
+    phpx export script.php --to ./my-project
+
+The project gets a composer.json built from the metadata (require, php,
+ext-* entries, repositories, minimum-stability, allow-plugins and a PSR-4
+autoload for src/), and a composer.lock of the versions phpx resolved for
+the script, so 'composer install' installs exactly what the script ran
+with. The script becomes a bin/ entrypoint without its // phpx block,
+loading the project's autoloader; files it includes are copied alongside.
+
+See 'phpx import' for the reverse.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "project directory to write (default: the script's name)")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	scriptPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("script not found: %s", args[0])
+	}
+
+	meta, err := metadata.Parse(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	dir := exportTo
+	if dir == "" {
+		dir = scriptName(scriptPath)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// The lock comes from the script's installed dependency set
+	lockFile := ""
+	if len(meta.Packages) > 0 {
+		env, err := prepareScript(meta, meta.PHP, meta.Packages, meta.Extensions)
+		if err != nil {
+			return err
+		}
+		lockFile = filepath.Join(env.DepsPath, "composer.lock")
+	}
+
+	entry, err := bundle.Project(&bundle.ProjectOptions{
+		Script:   scriptPath,
+		Includes: watch.Includes(scriptPath)[1:],
+		Requirements: &composer.ProjectRequirements{
+			Requirements: composer.Requirements{
+				Packages:         meta.Packages,
+				Repositories:     meta.Repositories,
+				MinimumStability: meta.MinimumStability,
+				AllowPlugins:     meta.AllowPlugins,
+				AllowScripts:     meta.AllowScripts,
+			},
+			PHP:        meta.PHP,
+			Extensions: meta.Extensions,
+		},
+		LockFile: lockFile,
+		Dir:      dir,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "[phpx] Exported %s to %s (entrypoint %s)\n", filepath.Base(scriptPath), dir, entry)
+		fmt.Fprintf(os.Stderr, "[phpx] Run 'composer install' in %s to install its dependencies\n", dir)
+	}
+	return nil
+}
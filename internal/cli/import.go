@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <composer.json> <script.php>",
+	Short: "Generate a script's // phpx block from a composer.json",
+	Long: `Generate a // phpx block from a Composer project's requirements.
+
+Examples:
+    phpx import composer.json script.php
+    phpx import ../app/composer.json tool.php
+
+The block declares require's packages, php constraint and ext-* entries,
+along with repositories, minimum-stability and allow-plugins. It is added
+after the script's opening <?php tag, replacing any existing block; the
+script is created if it does not exist. Other platform requirements
+(lib-*, composer-plugin-api) and dev requirements are left out.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runImport,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	composerJSON, scriptPath := args[0], args[1]
+
+	req, err := composer.ReadRequirements(composerJSON)
+	if err != nil {
+		return err
+	}
+
+	block := metadata.Format(&metadata.Metadata{
+		PHP:              req.PHP,
+		Packages:         req.Packages,
+		Extensions:       req.Extensions,
+		Repositories:     req.Repositories,
+		MinimumStability: req.MinimumStability,
+		AllowPlugins:     req.AllowPlugins,
+	})
+
+	content, err := os.ReadFile(scriptPath)
+	switch {
+	case os.IsNotExist(err):
+		content = []byte("<?php\n" + block + "\n")
+	case err != nil:
+		return err
+	default:
+		if !quiet && len(metadata.Strip(content)) != len(content) {
+			fmt.Fprintf(os.Stderr, "[phpx] Replacing the // phpx block in %s\n", scriptPath)
+		}
+		content = metadata.Insert(content, block)
+	}
+
+	if err := os.WriteFile(scriptPath, content, 0644); err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "[phpx] Imported %d packages from %s into %s\n", len(req.Packages), composerJSON, scriptPath)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/index"
+	"github.com/eddmann/phpx/internal/manifest"
+	"github.com/eddmann/phpx/internal/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	installBinDir string
+	installForce  bool
+	upgradeAll    bool
+)
+
+var toolInstallCmd = &cobra.Command{
+	Use:   "install <package[@version]>",
+	Short: "Install a tool with a shim on PATH",
+	Long: `Install a tool persistently and write a shim for its binary, so it can be
+run from editors, git hooks and scripts without 'phpx tool'.
+
+The shim runs the tool through phpx with the package version, binary and
+PHP version resolved at install time. Shims are written to ~/.phpx/bin, or
+the directory in PHPX_BIN_DIR or --bin-dir; add it to your PATH.
+
+Examples:
+    phpx tool install phpstan@^1.11
+    phpx tool install php-cs-fixer --php 8.3
+    phpx tool install phpstan --with phpstan/phpstan-symfony`,
+	Args: cobra.ExactArgs(1),
+	RunE: installTool,
+}
+
+var toolListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed tools",
+	Args:  cobra.NoArgs,
+	RunE:  listTools,
+}
+
+var toolUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [name...]",
+	Short: "Upgrade installed tools",
+	Long: `Re-resolve installed tools against the version and PHP constraints they
+were installed with, and repoint their shims at the newest matches.`,
+	RunE: upgradeTools,
+}
+
+var toolUninstallCmd = &cobra.Command{
+	Use:   "uninstall <name...>",
+	Short: "Remove installed tools and their shims",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  uninstallTools,
+}
+
+func init() {
+	toolInstallCmd.Flags().StringVar(&toolPHP, "php", "", "PHP version constraint")
+	toolInstallCmd.Flags().StringVar(&toolExtensions, "extensions", "", "comma-separated PHP extensions")
+	toolInstallCmd.Flags().StringVar(&toolFrom, "from", "", "explicit package name when binary differs")
+	toolInstallCmd.Flags().StringArrayVar(&toolWith, "with", nil, "extra package to install alongside the tool (vendor/name:constraint, repeatable)")
+	toolInstallCmd.Flags().StringArrayVar(&toolPlugins, "allow-plugin", nil, "Composer plugin allowed to run during install (vendor/name[=false], repeatable)")
+	toolInstallCmd.Flags().BoolVar(&toolScripts, "allow-scripts", false, "run Composer scripts during install")
+	toolInstallCmd.Flags().StringVar(&installBinDir, "bin-dir", "", "directory to write the shim to (default: $PHPX_BIN_DIR or ~/.phpx/bin)")
+	toolInstallCmd.Flags().BoolVar(&installForce, "force", false, "replace an installed tool or existing file of the same name")
+
+	toolUpgradeCmd.Flags().BoolVar(&upgradeAll, "all", false, "upgrade every installed tool")
+
+	toolCmd.AddCommand(toolInstallCmd)
+	toolCmd.AddCommand(toolListCmd)
+	toolCmd.AddCommand(toolUpgradeCmd)
+	toolCmd.AddCommand(toolUninstallCmd)
+}
+
+func installTool(cmd *cobra.Command, args []string) error {
+	spec := newToolSpec(args[0])
+
+	m, err := manifest.Find(".")
+	if err != nil {
+		return err
+	}
+	aliases, err := loadAliases(m)
+	if err != nil {
+		return err
+	}
+	if name, a, ok := configuredAlias(aliases, args[0]); ok {
+		applyAlias(spec, name, a)
+	}
+
+	binDir := installBinDir
+	if binDir == "" {
+		dir, err := shim.BinDir()
+		if err != nil {
+			return err
+		}
+		binDir = dir
+	}
+	binDir, err = filepath.Abs(binDir)
+	if err != nil {
+		return err
+	}
+
+	phpx, err := phpxExecutable()
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	tool, err := prepareTool(idx, spec)
+	if err != nil {
+		return err
+	}
+
+	r := &shim.Receipt{
+		Name:         tool.Binary,
+		Spec:         spec.Arg,
+		Binary:       tool.Binary,
+		PHP:          spec.PHP,
+		Extensions:   spec.Extensions,
+		With:         spec.With,
+		AllowPlugins: spec.Plugins,
+		AllowScripts: spec.Scripts,
+		Shim:         filepath.Join(binDir, tool.Binary),
+	}
+	setResolved(r, tool)
+
+	existing, err := shim.Load(r.Name)
+	switch {
+	case err == nil && !installForce:
+		return fmt.Errorf("%s is already installed (%s); use 'phpx tool upgrade %s' or --force", r.Name, receiptLabel(existing), r.Name)
+	case err != nil && !errors.Is(err, shim.ErrNotInstalled):
+		return err
+	}
+
+	if cache.Exists(r.Shim) && !shim.IsShim(r.Shim) && !installForce {
+		return fmt.Errorf("%s already exists and was not written by phpx; use --force to replace it", r.Shim)
+	}
+
+	if err := shim.Write(phpx, r); err != nil {
+		return fmt.Errorf("failed to write shim: %w", err)
+	}
+	if err := shim.Save(r); err != nil {
+		return err
+	}
+
+	// A reinstall into another directory leaves no stale shim behind
+	if existing != nil && existing.Shim != r.Shim && shim.IsShim(existing.Shim) {
+		_ = os.Remove(existing.Shim)
+	}
+
+	if !quiet {
+		fmt.Printf("Installed %s (%s) to %s\n", r.Name, receiptLabel(r), r.Shim)
+		if !shim.InPath(binDir) {
+			fmt.Printf("Add %s to your PATH to run %s directly\n", binDir, r.Name)
+		}
+	}
+
+	return nil
+}
+
+func listTools(cmd *cobra.Command, args []string) error {
+	receipts, err := shim.List()
+	if err != nil {
+		return err
+	}
+
+	if len(receipts) == 0 {
+		fmt.Println("No tools installed")
+		return nil
+	}
+
+	for _, r := range receipts {
+		fmt.Printf("%s  %s\n", r.Name, receiptLabel(r))
+		if verbose {
+			fmt.Printf("  spec: %s\n", r.Spec)
+			fmt.Printf("  shim: %s\n", r.Shim)
+			fmt.Printf("  installed: %s\n", r.InstalledAt.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+func upgradeTools(cmd *cobra.Command, args []string) error {
+	if upgradeAll == (len(args) > 0) {
+		return fmt.Errorf("name the tools to upgrade, or use --all")
+	}
+
+	var receipts []*shim.Receipt
+	if upgradeAll {
+		all, err := shim.List()
+		if err != nil {
+			return err
+		}
+		receipts = all
+	} else {
+		for _, name := range args {
+			r, err := shim.Load(name)
+			if err != nil {
+				return err
+			}
+			receipts = append(receipts, r)
+		}
+	}
+
+	if len(receipts) == 0 {
+		if !quiet {
+			fmt.Println("No tools installed")
+		}
+		return nil
+	}
+
+	phpx, err := phpxExecutable()
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	for _, r := range receipts {
+		before := receiptLabel(r)
+
+		tool, err := prepareTool(idx, &toolSpec{
+			Arg:        r.Spec,
+			PHP:        r.PHP,
+			Extensions: r.Extensions,
+			From:       r.Binary,
+			With:       r.With,
+			Plugins:    r.AllowPlugins,
+			Scripts:    r.AllowScripts,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		setResolved(r, tool)
+
+		// Rewritten even when unchanged, in case phpx itself has moved
+		if err := shim.Write(phpx, r); err != nil {
+			return fmt.Errorf("failed to write shim: %w", err)
+		}
+		if err := shim.Save(r); err != nil {
+			return err
+		}
+
+		if !quiet {
+			if after := receiptLabel(r); after != before {
+				fmt.Printf("Upgraded %s: %s → %s\n", r.Name, before, after)
+			} else {
+				fmt.Printf("%s is up to date (%s)\n", r.Name, after)
+			}
+		}
+	}
+
+	return nil
+}
+
+func uninstallTools(cmd *cobra.Command, args []string) error {
+	for _, name := range args {
+		r, err := shim.Load(name)
+		if err != nil {
+			return err
+		}
+		if err := shim.Remove(r); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Printf("Uninstalled %s\n", name)
+		}
+	}
+	return nil
+}
+
+// setResolved records what a tool resolved to in its receipt.
+func setResolved(r *shim.Receipt, tool *preparedTool) {
+	r.Package = tool.Package
+	r.Version = tool.Version
+	r.PHPVersion = tool.PHP.Version.String()
+	r.InstalledAt = time.Now().UTC()
+}
+
+// receiptLabel describes an installed tool, e.g. "phpstan/phpstan 1.11.5, PHP 8.3.10".
+func receiptLabel(r *shim.Receipt) string {
+	label := r.Package
+	if r.Version != "" {
+		label += " " + r.Version
+	} else if r.Spec != r.Package {
+		label += " from " + r.Spec
+	}
+	return label + ", PHP " + r.PHPVersion
+}
+
+// phpxExecutable returns the path of the running phpx binary for shims to
+// exec, with symlinks resolved.
+func phpxExecutable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate phpx: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path, nil
+}
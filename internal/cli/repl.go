@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/executor"
+	"github.com/eddmann/phpx/internal/metadata"
+	"github.com/eddmann/phpx/internal/sandbox"
+	"github.com/eddmann/phpx/internal/util"
+	"github.com/spf13/cobra"
+)
+
+// replPackage is the REPL installed into the dependency set.
+const replPackage = "psy/psysh"
+
+var (
+	replPHP        string
+	replPackages   string
+	replExtensions string
+	replLoad       bool
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl [script.php] [-- psysh args...]",
+	Short: "Start a PsySH shell with packages loaded",
+	Long: `Start an interactive PsySH shell with packages installed and autoloaded.
+
+Examples:
+    phpx repl --packages nesbot/carbon,ramsey/uuid --php 8.3
+    phpx repl script.php
+    phpx repl script.php --load
+
+PHP and dependencies are resolved as for 'phpx run', with PsySH added to the
+dependency set. Given a script, its // phpx metadata applies; with --load the
+script is also included on startup, so its functions and classes are defined
+(its top-level code runs too).`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runREPL,
+}
+
+func init() {
+	replCmd.Flags().StringVar(&replPHP, "php", "", "PHP version constraint (overrides script)")
+	replCmd.Flags().StringVar(&replPackages, "packages", "", "comma-separated packages to add")
+	replCmd.Flags().StringVar(&replExtensions, "extensions", "", "comma-separated PHP extensions")
+	replCmd.Flags().BoolVar(&replLoad, "load", false, "include the script on startup")
+
+	rootCmd.AddCommand(replCmd)
+}
+
+func runREPL(cmd *cobra.Command, args []string) error {
+	// A script, if given, comes before any '--'
+	var scriptPath string
+	psyshArgs := args
+	if dash := cmd.ArgsLenAtDash(); (dash == -1 && len(args) > 0) || dash > 0 {
+		scriptPath, psyshArgs = args[0], args[1:]
+	}
+	if replLoad && scriptPath == "" {
+		return fmt.Errorf("--load needs a script")
+	}
+
+	meta := &metadata.Metadata{}
+	if scriptPath != "" {
+		abs, err := filepath.Abs(scriptPath)
+		if err != nil {
+			return err
+		}
+		scriptPath = abs
+
+		content, err := os.ReadFile(scriptPath)
+		if err != nil {
+			return fmt.Errorf("script not found: %s", scriptPath)
+		}
+		meta, err = metadata.Parse(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse metadata: %w", err)
+		}
+	}
+
+	// Merge CLI flags with metadata
+	phpConstraint := replPHP
+	if phpConstraint == "" {
+		phpConstraint = meta.PHP
+	}
+
+	packages := meta.Packages
+	if replPackages != "" {
+		packages = append(packages, strings.Split(replPackages, ",")...)
+	}
+	if !slices.ContainsFunc(packages, func(p string) bool {
+		name, _, _ := composer.ParseToolArg(p)
+		return name == replPackage
+	}) {
+		packages = append(packages, replPackage)
+	}
+
+	extensions := meta.Extensions
+	if replExtensions != "" {
+		extensions = append(extensions, strings.Split(replExtensions, ",")...)
+	}
+
+	env, err := prepareScript(meta, phpConstraint, packages, extensions)
+	if err != nil {
+		return err
+	}
+
+	if replLoad {
+		config, err := replConfig(scriptPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(config) }()
+		psyshArgs = append([]string{"--config", config}, psyshArgs...)
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		workDir = "/"
+	}
+
+	// The terminal is passed straight through, without limits or sandbox
+	runner := executor.NewToolRunner(&executor.ToolOptions{
+		PHPBinary:    env.PHP.Path,
+		ToolDir:      env.DepsPath,
+		BinaryName:   "psysh",
+		ComposerPhar: pathComposer(env.Index, env.PHP.Version.String()),
+		Sandbox:      &sandbox.None{},
+		Network:      true,
+		Args:         psyshArgs,
+		WorkDir:      workDir,
+		Stdin:        os.Stdin,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+		Verbose:      verbose,
+	})
+
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if result.ExitCode != 0 {
+		os.Exit(result.ExitCode)
+	}
+
+	return nil
+}
+
+// replConfig writes a PsySH config file that includes script on startup,
+// keeping the user's own PsySH config if they have one.
+func replConfig(script string) (string, error) {
+	f, err := os.CreateTemp("", "phpx-psysh-*.php")
+	if err != nil {
+		return "", fmt.Errorf("failed to create PsySH config: %w", err)
+	}
+
+	config := fmt.Sprintf(`<?php
+$config = [];
+$dir = getenv('XDG_CONFIG_HOME') ?: getenv('HOME') . '/.config';
+$user = $dir . '/psysh/config.php';
+if (is_file($user)) {
+    $loaded = require $user;
+    if (is_array($loaded)) {
+        $config = $loaded;
+    }
+}
+$config['defaultIncludes'] = array_merge($config['defaultIncludes'] ?? [], [%s]);
+return $config;
+`, util.PHPString(script))
+
+	if _, err := f.WriteString(config); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write PsySH config: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
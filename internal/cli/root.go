@@ -36,13 +36,14 @@ executes Composer tools ephemerally.
 Examples:
   phpx script.php              Run a PHP script
   phpx run script.php          Same as above
+  phpx -e 'echo PHP_VERSION;'  Run inline code
   phpx tool phpstan            Run PHPStan
   phpx tool phpstan@1.10.0     Run specific version`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) == 0 {
+		if len(args) == 0 && !cmd.Flags().Changed("eval") {
 			return cmd.Help()
 		}
 		return runScript(cmd, args)
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,7 +17,9 @@ import (
 	"github.com/eddmann/phpx/internal/index"
 	"github.com/eddmann/phpx/internal/metadata"
 	"github.com/eddmann/phpx/internal/php"
+	"github.com/eddmann/phpx/internal/remote"
 	"github.com/eddmann/phpx/internal/sandbox"
+	"github.com/eddmann/phpx/internal/watch"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +27,11 @@ var (
 	runPHP        string
 	runPackages   string
 	runExtensions string
+	runUpgrade    bool
+	runEval       string
+	runWatch      bool
+	runWatchPaths []string
+	runSHA256     string
 
 	// Security flags
 	runSandbox   bool
@@ -37,7 +46,7 @@ var (
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run <script.php> [-- args...]",
+	Use:   "run <script.php | url | -e code> [-- args...]",
 	Short: "Run a PHP script with inline dependencies",
 	Long: `Run a PHP script, automatically installing any declared dependencies.
 
@@ -51,7 +60,33 @@ The script can declare dependencies in a // phpx comment block:
 
     // Script code here...
 
-Use "-" to read from stdin.
+Use "-" to read the script from stdin, or -e to run inline code; the
+opening <?php tag is optional, and stdin stays available to the code:
+
+    phpx -e 'echo Carbon\Carbon::now();' --packages nesbot/carbon
+    cat data.json | phpx -e 'var_dump(json_decode(stream_get_contents(STDIN)));'
+
+Inline code and stdin scripts run in the current directory.
+
+Scripts can also be run from a URL, or from GitHub with the shorthand
+gh:owner/repo/path/to/script.php[@ref]. A remote script must be pinned
+to its content with --sha256, or explicitly run with --sandbox:
+
+    phpx run https://example.com/script.php --sha256 <digest>
+    phpx run gh:owner/repo/bin/tool.php@v1.0 --sandbox
+
+Remote scripts are sandboxed with the network blocked unless --allow-host
+is given, and the packages and permissions they request are shown before
+they run. A pinned script may opt out with --sandbox=false. Scripts are
+cached by URL and digest, so a pinned script is only fetched once.
+
+Dependencies are cached on first run. Use --upgrade to re-resolve them
+against their constraints, or set PHPX_DEPS_TTL (e.g. "168h") to have
+floating constraints re-resolved in the background once that old.
+
+With --watch, the script is re-run whenever it, a file it includes or a
+--watch-path changes; a run still in progress is cancelled. Changes to the
+// phpx block install the new dependencies before re-running.
 
 Security options:
     --sandbox          Enable sandboxing (restricts filesystem access)
@@ -60,7 +95,7 @@ Security options:
     --allow-read       Allow reading additional paths (comma-separated)
     --allow-write      Allow writing to additional paths (comma-separated)
     --allow-env        Pass through environment variables (comma-separated)`,
-	Args:               cobra.MinimumNArgs(1),
+	Args:               cobra.ArbitraryArgs,
 	DisableFlagParsing: false,
 	RunE:               runScript,
 }
@@ -71,6 +106,11 @@ func addScriptFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&runPHP, "php", "", "PHP version constraint (overrides script)")
 	cmd.Flags().StringVar(&runPackages, "packages", "", "comma-separated packages to add")
 	cmd.Flags().StringVar(&runExtensions, "extensions", "", "comma-separated PHP extensions")
+	cmd.Flags().BoolVar(&runUpgrade, "upgrade", false, "re-resolve and reinstall cached dependencies")
+	cmd.Flags().StringVarP(&runEval, "eval", "e", "", "run inline code instead of a script")
+	cmd.Flags().BoolVar(&runWatch, "watch", false, "re-run when the script or its includes change")
+	cmd.Flags().StringArrayVar(&runWatchPaths, "watch-path", nil, "additional file, directory or glob to watch (repeatable)")
+	cmd.Flags().StringVar(&runSHA256, "sha256", "", "expected sha256 digest of a script run from a URL")
 
 	// Security flags
 	cmd.Flags().BoolVar(&runSandbox, "sandbox", false, "enable sandboxing")
@@ -90,11 +130,42 @@ func init() {
 }
 
 func runScript(cmd *cobra.Command, args []string) error {
-	scriptPath := args[0]
-	scriptArgs := args[1:]
+	// Inline code and stdin scripts run where phpx was started, rather than
+	// from the directory their file is written to
+	var workDir string
+
+	var scriptPath string
+	var scriptArgs []string
+	switch {
+	case cmd.Flags().Changed("eval"):
+		scriptArgs = args
+	case len(args) == 0:
+		return fmt.Errorf("requires a script, - for stdin, or -e code")
+	default:
+		scriptPath, scriptArgs = args[0], args[1:]
+	}
+
+	if runSHA256 != "" && (scriptPath == "" || !remote.IsRemote(scriptPath)) {
+		return fmt.Errorf("--sha256 is only used for scripts run from a URL")
+	}
+
+	if cmd.Flags().Changed("eval") {
+		// Inline code never changes, so there is nothing of its own to watch
+		if runWatch && len(runWatchPaths) == 0 {
+			return fmt.Errorf("--watch with -e needs --watch-path")
+		}
+
+		path, err := writeEval(runEval)
+		if err != nil {
+			return err
+		}
+		scriptPath = path
+		workDir, _ = os.Getwd()
+	} else if scriptPath == "-" {
+		if runWatch {
+			return fmt.Errorf("--watch needs a script file, not stdin")
+		}
 
-	// Handle stdin
-	if scriptPath == "-" {
 		tmpFile, err := os.CreateTemp("", "phpx-*.php")
 		if err != nil {
 			return fmt.Errorf("failed to create temp file: %w", err)
@@ -107,6 +178,18 @@ func runScript(cmd *cobra.Command, args []string) error {
 		}
 		_ = tmpFile.Close()
 		scriptPath = tmpFile.Name()
+		workDir, _ = os.Getwd()
+	} else if remote.IsRemote(scriptPath) {
+		if runWatch {
+			return fmt.Errorf("--watch needs a local script file")
+		}
+
+		path, err := fetchScript(cmd, scriptPath)
+		if err != nil {
+			return err
+		}
+		scriptPath = path
+		workDir, _ = os.Getwd()
 	} else {
 		// Verify script exists
 		if _, err := os.Stat(scriptPath); err != nil {
@@ -115,126 +198,229 @@ func runScript(cmd *cobra.Command, args []string) error {
 		scriptPath, _ = filepath.Abs(scriptPath)
 	}
 
-	// Read and parse script
-	content, err := os.ReadFile(scriptPath)
-	if err != nil {
-		return fmt.Errorf("failed to read script: %w", err)
+	if runWatch {
+		paths := func() []string {
+			return append(watch.Includes(scriptPath), runWatchPaths...)
+		}
+		return watchLoop(paths, func(ctx context.Context) (int, error) {
+			return executeScript(ctx, scriptPath, scriptArgs, workDir)
+		})
 	}
 
-	meta, err := metadata.Parse(content)
+	exitCode, err := executeScript(context.Background(), scriptPath, scriptArgs, workDir)
 	if err != nil {
-		return fmt.Errorf("failed to parse metadata: %w", err)
+		return err
 	}
 
-	// Merge CLI flags with metadata
-	phpConstraint := runPHP
-	if phpConstraint == "" {
-		phpConstraint = meta.PHP
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
 
-	packages := meta.Packages
-	if runPackages != "" {
-		packages = append(packages, strings.Split(runPackages, ",")...)
-	}
+	return nil
+}
 
-	extensions := meta.Extensions
-	if runExtensions != "" {
-		extensions = append(extensions, strings.Split(runExtensions, ",")...)
+// fetchScript fetches a script run from a URL and applies the remote
+// script policy: it must be pinned with --sha256 or sandboxed, and is
+// sandboxed with the network blocked unless the flags say otherwise. The
+// permissions its metadata requests are shown before it runs.
+func fetchScript(cmd *cobra.Command, arg string) (string, error) {
+	url, err := remote.ResolveURL(arg)
+	if err != nil {
+		return "", err
+	}
+	runSHA256 = strings.ToLower(runSHA256)
+	if runSHA256 != "" && !remote.ValidDigest(runSHA256) {
+		return "", fmt.Errorf("invalid --sha256 digest %q; expected 64 hex characters", runSHA256)
 	}
 
-	// Load index
 	if verbose {
-		fmt.Fprintln(os.Stderr, "[phpx] Loading index...")
+		fmt.Fprintf(os.Stderr, "[phpx] Fetching %s\n", url)
 	}
 
-	idx, err := index.Load()
+	script, err := remote.Fetch(url, runSHA256)
 	if err != nil {
-		return fmt.Errorf("failed to load index: %w", err)
+		return "", err
 	}
 
-	// Resolve PHP
-	if verbose {
-		if phpConstraint != "" {
-			fmt.Fprintf(os.Stderr, "[phpx] Resolving PHP version for constraint '%s'\n", phpConstraint)
-		} else {
-			fmt.Fprintln(os.Stderr, "[phpx] Resolving latest PHP version")
-		}
+	if verbose && script.Cached {
+		fmt.Fprintf(os.Stderr, "[phpx] Using cached script %s\n", script.Path)
 	}
 
-	res, err := php.Resolve(idx, phpConstraint, extensions)
-	if err != nil {
-		if phpConstraint != "" {
-			return fmt.Errorf("failed to resolve PHP for constraint %q: %w", phpConstraint, err)
+	if runSHA256 == "" && !runSandbox {
+		return "", fmt.Errorf("refusing to run unpinned remote script %s\n"+
+			"Pin it with --sha256 %s after reviewing it, or run it with --sandbox", url, script.Digest)
+	}
+
+	// Sandboxed by default; only a pinned script may opt out
+	if !cmd.Flags().Changed("sandbox") {
+		if !sandbox.Detect().IsSandboxed() {
+			return "", fmt.Errorf("remote scripts run sandboxed, but no sandbox is available on this system\n" +
+				"Pass --sandbox=false with --sha256 to run it unsandboxed")
 		}
-		return fmt.Errorf("failed to resolve PHP: %w", err)
+		runSandbox = true
+	}
+	if runSandbox && runAllowHost == "" {
+		runOffline = true
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Matched: %s (%s tier)\n", res.Version, res.Tier)
+	content, err := os.ReadFile(script.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %w", err)
+	}
+	meta, err := metadata.Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse metadata: %w", err)
 	}
 
-	// Ensure PHP is available
-	showProgress := !quiet && !verbose
-	if err := php.EnsurePHP(res, showProgress); err != nil {
-		return err
+	if !quiet {
+		describePermissions(os.Stderr, script, meta)
 	}
 
-	if verbose && !res.Cached {
-		fmt.Fprintf(os.Stderr, "[phpx] PHP binary downloaded to %s\n", res.Path)
+	return script.Path, nil
+}
+
+// describePermissions prints what a remote script will install and be
+// allowed to do.
+func describePermissions(w io.Writer, script *remote.Script, meta *metadata.Metadata) {
+	fmt.Fprintf(w, "[phpx] Running %s\n", script.URL)
+	fmt.Fprintf(w, "[phpx]   sha256:        %s\n", script.Digest)
+
+	if meta.PHP != "" {
+		fmt.Fprintf(w, "[phpx]   php:           %s\n", meta.PHP)
+	}
+	if len(meta.Packages) > 0 {
+		fmt.Fprintf(w, "[phpx]   packages:      %s\n", strings.Join(meta.Packages, ", "))
+	}
+	if len(meta.Extensions) > 0 {
+		fmt.Fprintf(w, "[phpx]   extensions:    %s\n", strings.Join(meta.Extensions, ", "))
+	}
+	for _, repo := range meta.Repositories {
+		fmt.Fprintf(w, "[phpx]   repository:    %s\n", repo)
+	}
+	if meta.MinimumStability != "" {
+		fmt.Fprintf(w, "[phpx]   stability:     %s\n", meta.MinimumStability)
 	}
 
-	var autoloadPath string
+	var plugins []string
+	for name, allow := range meta.AllowPlugins {
+		if allow {
+			plugins = append(plugins, name)
+		}
+	}
+	sort.Strings(plugins)
+	if len(plugins) > 0 {
+		fmt.Fprintf(w, "[phpx]   allow-plugins: %s\n", strings.Join(plugins, ", "))
+	}
+	if meta.AllowScripts {
+		fmt.Fprintln(w, "[phpx]   allow-scripts: true")
+	}
 
-	// Install dependencies if any
-	if len(packages) > 0 {
-		hash := cache.DepsHash(packages)
-		depsPath, err := cache.DepsPath(hash)
-		if err != nil {
-			return err
+	// The sandbox mounts only the cached script and the allowed paths; the
+	// working directory is replaced by an empty one
+	fs := "unrestricted"
+	if runSandbox {
+		fs = "cached script"
+		if runAllowRead != "" {
+			fs += ", read " + runAllowRead
+		}
+		if runAllowWrite != "" {
+			fs += ", write " + runAllowWrite
 		}
+		if runAllowRead == "" && runAllowWrite == "" {
+			fs += " only"
+		}
+	}
+	network := "unrestricted"
+	switch {
+	case runAllowHost != "":
+		network = runAllowHost
+	case runOffline:
+		network = "blocked"
+	}
+	fmt.Fprintf(w, "[phpx]   filesystem:    %s\n", fs)
+	fmt.Fprintf(w, "[phpx]   network:       %s\n", network)
+}
 
-		autoloadPath = filepath.Join(depsPath, "vendor", "autoload.php")
+// writeEval writes inline code to its file in the eval cache, adding an
+// opening <?php tag if the code has none, and returns the file's path.
+func writeEval(code string) (string, error) {
+	if !strings.HasPrefix(strings.TrimLeft(code, " \t\r\n"), "<?") {
+		code = "<?php\n" + code
+	}
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
 
-		if !cache.Exists(autoloadPath) {
-			if verbose {
-				fmt.Fprintf(os.Stderr, "[phpx] Installing dependencies to %s\n", depsPath)
-			}
+	path, err := cache.EvalPath([]byte(code))
+	if err != nil {
+		return "", err
+	}
+	if cache.Exists(path) {
+		return path, nil
+	}
 
-			// Get Composer
-			cv, err := idx.SelectComposer(res.Version.String())
-			if err != nil {
-				return err
-			}
+	if err := cache.EnsureDir(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	// Written in place atomically, as concurrent runs may share the path
+	tmp := path + ".tmp-" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write inline code: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to write inline code: %w", err)
+	}
+	return path, nil
+}
 
-			composerPath, err := index.DownloadComposer(cv)
-			if err != nil {
-				return fmt.Errorf("failed to download Composer: %w", err)
-			}
+// executeScript installs what a script declares and runs it, returning its
+// exit code. workDir defaults to the script's directory.
+func executeScript(ctx context.Context, scriptPath string, scriptArgs []string, workDir string) (int, error) {
+	// Read and parse script
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read script: %w", err)
+	}
 
-			if verbose {
-				fmt.Fprintf(os.Stderr, "[phpx] Using Composer %s\n", cv.Version)
-			}
+	meta, err := metadata.Parse(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse metadata: %w", err)
+	}
 
-			// Install
-			if err := composer.InstallDeps(res.Path, composerPath, packages, depsPath, verbose); err != nil {
-				return err
-			}
-		} else if verbose {
-			fmt.Fprintln(os.Stderr, "[phpx] Dependencies cached")
-		}
+	// Merge CLI flags with metadata
+	phpConstraint := runPHP
+	if phpConstraint == "" {
+		phpConstraint = meta.PHP
+	}
+
+	packages := meta.Packages
+	if runPackages != "" {
+		packages = append(packages, strings.Split(runPackages, ",")...)
+	}
+
+	extensions := meta.Extensions
+	if runExtensions != "" {
+		extensions = append(extensions, strings.Split(runExtensions, ",")...)
+	}
+
+	env, err := prepareScript(meta, phpConstraint, packages, extensions)
+	if err != nil {
+		return 0, err
 	}
+	idx, res, autoloadPath := env.Index, env.PHP, env.Autoload
 
 	// Determine sandbox
 	var sb sandbox.Sandbox = &sandbox.None{}
 	if runSandbox {
 		sb = sandbox.Detect()
 		if !sb.IsSandboxed() {
-			return fmt.Errorf("--sandbox requested but no sandbox is available on this system")
+			return 0, fmt.Errorf("--sandbox requested but no sandbox is available on this system")
 		}
 	} else if runOffline || runAllowHost != "" {
 		sb = sandbox.DetectNetworkOnly()
 		if !sb.IsSandboxed() {
-			return fmt.Errorf("--offline/--allow-host requires network sandboxing, but no sandbox is available on this system")
+			return 0, fmt.Errorf("--offline/--allow-host requires network sandboxing, but no sandbox is available on this system")
 		}
 	}
 
@@ -267,6 +453,7 @@ func runScript(cmd *cobra.Command, args []string) error {
 		ScriptPath:     scriptPath,
 		PHPBinary:      res.Path,
 		AutoloadFile:   autoloadPath,
+		ComposerPhar:   pathComposer(idx, res.Version.String()),
 		Sandbox:        sb,
 		Network:        network,
 		AllowedHosts:   allowedHosts,
@@ -277,6 +464,7 @@ func runScript(cmd *cobra.Command, args []string) error {
 		Timeout:        time.Duration(runTimeout) * time.Second,
 		CPUSeconds:     runCPU,
 		Args:           scriptArgs,
+		WorkDir:        workDir,
 		Stdin:          os.Stdin,
 		Stdout:         os.Stdout,
 		Stderr:         os.Stderr,
@@ -285,20 +473,169 @@ func runScript(cmd *cobra.Command, args []string) error {
 
 	// Execute script using executor
 	runner := executor.NewScriptRunner(opts)
-	result, err := runner.Run(context.Background())
+	result, err := runner.Run(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if verbose {
 		fmt.Fprintf(os.Stderr, "[phpx] Exit code: %d\n", result.ExitCode)
 	}
 
-	if result.ExitCode != 0 {
-		os.Exit(result.ExitCode)
+	return result.ExitCode, nil
+}
+
+// scriptEnv is the PHP build and dependencies resolved for a script.
+type scriptEnv struct {
+	Index    *index.Index
+	PHP      *php.Resolution
+	DepsPath string // Dependency set directory; empty without packages
+	Autoload string // Path of the dependencies' vendor/autoload.php
+}
+
+// prepareScript resolves PHP for a script's requirements and installs its
+// packages, downloading both if they are not cached.
+func prepareScript(meta *metadata.Metadata, phpConstraint string, packages, extensions []string) (*scriptEnv, error) {
+	// Load index
+	if verbose {
+		fmt.Fprintln(os.Stderr, "[phpx] Loading index...")
+	}
+
+	idx, err := index.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
 	}
 
-	return nil
+	// Resolve PHP
+	if verbose {
+		if phpConstraint != "" {
+			fmt.Fprintf(os.Stderr, "[phpx] Resolving PHP version for constraint '%s'\n", phpConstraint)
+		} else {
+			fmt.Fprintln(os.Stderr, "[phpx] Resolving latest PHP version")
+		}
+	}
+
+	res, err := php.Resolve(idx, phpConstraint, extensions)
+	if err != nil {
+		if phpConstraint != "" {
+			return nil, fmt.Errorf("failed to resolve PHP for constraint %q: %w", phpConstraint, err)
+		}
+		return nil, fmt.Errorf("failed to resolve PHP: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Matched: %s (%s tier)\n", res.Version, res.Tier)
+	}
+
+	// Ensure PHP is available
+	showProgress := !quiet && !verbose
+	if err := php.EnsurePHP(res, showProgress); err != nil {
+		return nil, err
+	}
+
+	if verbose && !res.Cached {
+		fmt.Fprintf(os.Stderr, "[phpx] PHP binary downloaded to %s\n", res.Path)
+	}
+
+	if verifyOnRun() {
+		if err := checkVerified("PHP binary", php.Verify(res)); err != nil {
+			return nil, err
+		}
+	}
+
+	env := &scriptEnv{Index: idx, PHP: res}
+
+	// Install dependencies if any
+	if len(packages) > 0 {
+		repositories := make([]string, len(meta.Repositories))
+		for i, repo := range meta.Repositories {
+			repositories[i] = repo.String()
+		}
+
+		var plugins []string
+		for name, allow := range meta.AllowPlugins {
+			plugins = append(plugins, fmt.Sprintf("%s=%t", name, allow))
+		}
+
+		hash := cache.DepsHash(cache.DepsKey{
+			Packages:         packages,
+			PHPVersion:       fmt.Sprintf("%d.%d", res.Version.Major(), res.Version.Minor()),
+			Extensions:       extensions,
+			Repositories:     repositories,
+			MinimumStability: meta.MinimumStability,
+			AllowPlugins:     plugins,
+			AllowScripts:     meta.AllowScripts,
+		})
+		depsPath, err := cache.DepsPath(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		env.DepsPath = depsPath
+		env.Autoload = filepath.Join(depsPath, "vendor", "autoload.php")
+
+		// The vendor tree is briefly missing while an upgrade swaps it
+		if !cache.Exists(env.Autoload) {
+			composer.WaitForUpgrade(depsPath)
+		}
+
+		if !cache.Exists(env.Autoload) {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "[phpx] Installing dependencies to %s\n", depsPath)
+			}
+
+			composerPath, err := composerFor(idx, res.Version.String())
+			if err != nil {
+				return nil, err
+			}
+
+			// Install
+			req := &composer.Requirements{
+				Packages:         packages,
+				Repositories:     meta.Repositories,
+				MinimumStability: meta.MinimumStability,
+				AllowPlugins:     meta.AllowPlugins,
+				AllowScripts:     meta.AllowScripts,
+			}
+			if err := composer.InstallDeps(res.Path, composerPath, req, depsPath, verbose); err != nil {
+				return nil, err
+			}
+		} else if runUpgrade {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "[phpx] Upgrading dependencies in %s\n", depsPath)
+			}
+
+			composerPath, err := composerFor(idx, res.Version.String())
+			if err != nil {
+				return nil, err
+			}
+
+			if !composer.LockUpgrade(depsPath) {
+				return nil, fmt.Errorf("dependencies are already being upgraded; try again shortly")
+			}
+			changes, err := composer.Upgrade(res.Path, composerPath, depsPath, verbose)
+			composer.UnlockUpgrade(depsPath)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(changes) == 0 && !quiet {
+				fmt.Fprintln(os.Stderr, "[phpx] Dependencies already up to date")
+			}
+			printVersionChanges("Upgraded dependencies:", changes)
+		} else {
+			if verbose {
+				fmt.Fprintln(os.Stderr, "[phpx] Dependencies cached")
+			}
+
+			reportBackgroundUpgrade(depsPath)
+			if depsStale(depsPath, packages) {
+				startBackgroundUpgrade(depsPath)
+			}
+		}
+	}
+
+	return env, nil
 }
 
 // splitCSV splits a comma-separated string into a slice, trimming whitespace.
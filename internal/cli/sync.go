@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eddmann/phpx/internal/index"
+	"github.com/eddmann/phpx/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var toolSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Install every tool declared in phpx.toml",
+	Long: `Install every tool declared in the project's phpx.toml, found in the
+current directory or its parents, and record the resolved versions in its
+lock section.
+
+Locked versions are reused while they satisfy the declared constraints.
+The manifest's allow-plugins and allow-scripts settings only apply with
+--trust-project.`,
+	Args: cobra.NoArgs,
+	RunE: syncTools,
+}
+
+func init() {
+	toolSyncCmd.Flags().BoolVar(&toolTrustProject, "trust-project", false, "apply allow-plugins and allow-scripts from phpx.toml")
+	toolCmd.AddCommand(toolSyncCmd)
+}
+
+func syncTools(cmd *cobra.Command, args []string) error {
+	m, err := manifest.Find(".")
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("no %s found in this directory or its parents", manifest.FileName)
+	}
+
+	if len(m.Tools) == 0 {
+		if !quiet {
+			fmt.Printf("No tools declared in %s\n", m.Path())
+		}
+		return nil
+	}
+
+	aliases, err := loadAliases(m)
+	if err != nil {
+		return err
+	}
+
+	// Tools resolve as they would when run from here
+	project, err := toolProject()
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	for _, name := range m.Names() {
+		mt := m.Tools[name]
+		spec := &toolSpec{Project: project}
+		applyManifest(spec, m, name, &mt)
+		if key, a, ok := configuredAlias(aliases, name); ok {
+			applyAlias(spec, key, a)
+		}
+
+		tool, err := prepareTool(idx, spec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if err := lockTool(m, name, tool); err != nil {
+			return err
+		}
+
+		if !quiet {
+			label := tool.Package
+			if tool.Version != "" {
+				label += "@" + tool.Version
+			}
+			fmt.Fprintf(os.Stdout, "%s: %s (PHP %s)\n", name, label, tool.PHP.Version)
+		}
+	}
+
+	return nil
+}
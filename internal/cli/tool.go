@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,15 +13,24 @@ import (
 	"github.com/eddmann/phpx/internal/composer"
 	"github.com/eddmann/phpx/internal/executor"
 	"github.com/eddmann/phpx/internal/index"
+	"github.com/eddmann/phpx/internal/manifest"
 	"github.com/eddmann/phpx/internal/php"
 	"github.com/eddmann/phpx/internal/sandbox"
 	"github.com/spf13/cobra"
 )
 
 var (
-	toolPHP        string
-	toolExtensions string
-	toolFrom       string
+	toolPHP          string
+	toolExtensions   string
+	toolFrom         string
+	toolWith         []string
+	toolPlugins      []string
+	toolScripts      bool
+	toolNoProject    bool
+	toolTrustProject bool
+	toolListBins     bool
+	toolWatch        bool
+	toolWatchPaths   []string
 
 	// Security flags
 	toolSandbox    bool
@@ -43,6 +53,34 @@ Examples:
     phpx tool phpstan -- analyze src/
     phpx tool phpstan@1.10.0 -- analyze src/
     phpx tool phpstan:^1.10 -- analyze src/
+    phpx tool phpstan --with phpstan/phpstan-symfony \
+        --with phpstan/extension-installer \
+        --allow-plugin phpstan/extension-installer -- analyze src/
+    phpx tool pest --allow-plugin 'pestphp/*' --allow-scripts
+
+Packages with several binaries run the one named after the package or
+alias; select another with package:binary, and list them with --list-bins:
+    phpx tool php_codesniffer --list-bins
+    phpx tool php_codesniffer:phpcbf@^3.7 -- src/
+
+With --watch, the tool re-runs whenever a file under the working directory
+changes (or a --watch-path, e.g. 'src/**/*.php'), cancelling a run still in
+progress. vendor, node_modules and hidden files are not watched.
+
+Inside a project with a phpx.toml, declared tools use its pinned versions
+and settings; 'phpx tool sync' installs them all. Its allow-plugins and
+allow-scripts settings only apply with --trust-project.
+
+To run a tool without 'phpx tool', install it with a shim on PATH:
+    phpx tool install phpstan@^1.11
+    phpx tool list | upgrade [name|--all] | uninstall <name>
+
+In a directory with a composer.json, PHP is chosen to satisfy the project's
+require.php, config.platform.php and ext-* requirements, and the project's
+vendor directory is kept read-only in the sandbox (--no-project to ignore).
+
+Composer plugins and package scripts are disabled unless allowed. When a
+sandbox is available, installs that enable them run inside it.
 
 Common aliases are supported:
     phpstan      → phpstan/phpstan
@@ -55,6 +93,16 @@ Common aliases are supported:
     phpcs        → squizlabs/php_codesniffer
     laravel      → laravel/installer
     psysh        → psy/psysh
+    phpmd        → phpmd/phpmd
+    deptrac      → deptrac/deptrac
+    phpbench     → phpbench/phpbench
+
+More aliases can be defined in the user alias file or phpx.toml; run
+'phpx tool aliases' to list them.
+
+Run from signed release PHARs:
+    box, infection
+    phar:<url>[#sha256=<digest>]
 
 Security options:
     --sandbox          Enable sandboxing (restricts filesystem access)
@@ -71,6 +119,14 @@ func init() {
 	toolCmd.Flags().StringVar(&toolPHP, "php", "", "PHP version constraint")
 	toolCmd.Flags().StringVar(&toolExtensions, "extensions", "", "comma-separated PHP extensions")
 	toolCmd.Flags().StringVar(&toolFrom, "from", "", "explicit package name when binary differs")
+	toolCmd.Flags().StringArrayVar(&toolWith, "with", nil, "extra package to install alongside the tool (vendor/name:constraint, repeatable)")
+	toolCmd.Flags().StringArrayVar(&toolPlugins, "allow-plugin", nil, "Composer plugin allowed to run during install (vendor/name[=false], repeatable)")
+	toolCmd.Flags().BoolVar(&toolScripts, "allow-scripts", false, "run Composer scripts during install")
+	toolCmd.Flags().BoolVar(&toolTrustProject, "trust-project", false, "apply allow-plugins and allow-scripts from phpx.toml")
+	toolCmd.Flags().BoolVar(&toolNoProject, "no-project", false, "ignore the Composer project in the working directory")
+	toolCmd.Flags().BoolVar(&toolListBins, "list-bins", false, "list the package's binaries instead of running one")
+	toolCmd.Flags().BoolVar(&toolWatch, "watch", false, "re-run when files in the working directory change")
+	toolCmd.Flags().StringArrayVar(&toolWatchPaths, "watch-path", nil, "file, directory or glob to watch instead of the working directory (repeatable)")
 
 	// Security flags
 	toolCmd.Flags().BoolVar(&toolSandbox, "sandbox", false, "enable sandboxing")
@@ -87,55 +143,47 @@ func init() {
 }
 
 func runTool(cmd *cobra.Command, args []string) error {
-	toolArg := args[0]
 	toolArgs := args[1:]
 
-	// Parse package and version
-	pkgName, versionConstraint := composer.ParseToolArg(toolArg)
-	pkgName = composer.ResolveAlias(pkgName)
-
-	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Tool: %s", pkgName)
-		if versionConstraint != "" {
-			fmt.Fprintf(os.Stderr, " (%s)", versionConstraint)
-		}
-		fmt.Fprintln(os.Stderr)
-	}
+	spec := newToolSpec(args[0])
 
-	// Fetch package info
-	if verbose {
-		fmt.Fprintln(os.Stderr, "[phpx] Fetching package info from Packagist...")
-	}
-
-	pkgInfo, err := composer.FetchPackage(pkgName)
+	// Tools declared in the project manifest use its pinned settings
+	m, err := manifest.Find(".")
 	if err != nil {
 		return err
 	}
 
-	// Resolve version
-	version, err := composer.ResolveVersion(pkgInfo, versionConstraint)
+	aliases, err := loadAliases(m)
 	if err != nil {
 		return err
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Resolved version: %s\n", version.Version)
-	}
-
-	// Infer binary
-	binary, err := composer.InferBinary(pkgName, version.Bin, toolFrom)
-	if err != nil {
-		return err
+	var toolName string
+	if m != nil {
+		if name, mt, ok := m.Lookup(spec.Arg); ok {
+			toolName = name
+			applyManifest(spec, m, name, mt)
+			if len(toolArgs) == 0 {
+				toolArgs = mt.Args
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "[phpx] Using %s from %s\n", name, m.Path())
+			}
+		}
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Binary: %s\n", binary)
+	// Configured aliases supply defaults for anything still unset
+	if name, a, ok := configuredAlias(aliases, args[0]); ok {
+		applyAlias(spec, name, a)
+		applyAliasSandbox(a)
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] Using alias %s from %s\n", name, a.Origin)
+		}
 	}
 
-	// Parse extensions
-	var extensions []string
-	if toolExtensions != "" {
-		extensions = strings.Split(toolExtensions, ",")
+	spec.Project, err = toolProject()
+	if err != nil {
+		return err
 	}
 
 	// Load index
@@ -148,75 +196,28 @@ func runTool(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load index: %w", err)
 	}
 
-	// Resolve PHP
-	phpConstraint := toolPHP
-	if phpConstraint == "" {
-		// Use package's PHP requirement if available
-		if req, ok := version.Require["php"]; ok {
-			phpConstraint = req
-		}
-	}
-
-	if verbose {
-		if phpConstraint != "" {
-			fmt.Fprintf(os.Stderr, "[phpx] Resolving PHP version for constraint '%s'\n", phpConstraint)
-		} else {
-			fmt.Fprintln(os.Stderr, "[phpx] Resolving latest PHP version")
-		}
-	}
-
-	res, err := php.Resolve(idx, phpConstraint, extensions)
-	if err != nil {
-		if phpConstraint != "" {
-			return fmt.Errorf("failed to resolve PHP for constraint %q: %w", phpConstraint, err)
-		}
-		return fmt.Errorf("failed to resolve PHP: %w", err)
-	}
-
-	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Matched: %s (%s tier)\n", res.Version, res.Tier)
+	if toolListBins {
+		return listToolBins(idx, spec)
 	}
 
-	// Ensure PHP is available
-	showProgress := !quiet && !verbose
-	if err := php.EnsurePHP(res, showProgress); err != nil {
-		return err
-	}
-
-	// Check if tool is cached
-	toolPath, err := cache.ToolPath(pkgName, version.Version)
+	tool, err := prepareTool(idx, spec)
 	if err != nil {
 		return err
 	}
 
-	binaryPath := filepath.Join(toolPath, "vendor", "bin", binary)
-
-	if !cache.Exists(binaryPath) {
-		if verbose {
-			fmt.Fprintf(os.Stderr, "[phpx] Installing %s@%s to %s\n", pkgName, version.Version, toolPath)
-		}
-
-		// Get Composer
-		cv, err := idx.SelectComposer(res.Version.String())
-		if err != nil {
+	if toolName != "" {
+		if err := lockTool(m, toolName, tool); err != nil {
 			return err
 		}
+	}
 
-		composerPath, err := index.DownloadComposer(cv)
-		if err != nil {
-			return fmt.Errorf("failed to download Composer: %w", err)
-		}
-
-		if verbose {
-			fmt.Fprintf(os.Stderr, "[phpx] Using Composer %s\n", cv.Version)
+	if verifyOnRun() {
+		if err := checkVerified("PHP binary", php.Verify(tool.PHP)); err != nil {
+			return err
 		}
-
-		// Install
-		if err := composer.InstallTool(res.Path, composerPath, pkgName, version.Version, toolPath, verbose); err != nil {
+		if err := checkVerified("tool", cache.VerifyFiles(tool.Dir, tool.Entrypoint)); err != nil {
 			return err
 		}
-	} else if verbose {
-		fmt.Fprintln(os.Stderr, "[phpx] Tool cached")
 	}
 
 	// Determine sandbox
@@ -249,6 +250,12 @@ func runTool(cmd *cobra.Command, args []string) error {
 		writePaths = splitCSV(toolAllowWrite)
 	}
 
+	// The project's dependencies are readable by the tool but never modified
+	var readOnlyPaths []string
+	if spec.Project != nil && cache.Exists(spec.Project.VendorDir) {
+		readOnlyPaths = append(readOnlyPaths, spec.Project.VendorDir)
+	}
+
 	var allowedEnvVars []string
 	if toolAllowEnv != "" {
 		allowedEnvVars = splitCSV(toolAllowEnv)
@@ -265,15 +272,18 @@ func runTool(cmd *cobra.Command, args []string) error {
 
 	// Build executor options with real-time I/O streaming
 	opts := &executor.ToolOptions{
-		PHPBinary:      res.Path,
-		ToolDir:        toolPath,
-		BinaryName:     binary,
+		PHPBinary:      tool.PHP.Path,
+		ToolDir:        tool.Dir,
+		BinaryName:     tool.Binary,
+		Entrypoint:     tool.Entrypoint,
+		ComposerPhar:   pathComposer(idx, tool.PHP.Version.String()),
 		Sandbox:        sb,
 		Network:        network,
 		AllowedHosts:   allowedHosts,
 		AllowedEnvVars: allowedEnvVars,
 		ReadPaths:      readPaths,
 		WritePaths:     writePaths,
+		ReadOnlyPaths:  readOnlyPaths,
 		MemoryMB:       toolMemory,
 		Timeout:        time.Duration(toolTimeout) * time.Second,
 		CPUSeconds:     toolCPU,
@@ -287,18 +297,454 @@ func runTool(cmd *cobra.Command, args []string) error {
 
 	// Execute tool using executor
 	runner := executor.NewToolRunner(opts)
-	result, err := runner.Run(context.Background())
+	execute := func(ctx context.Context) (int, error) {
+		result, err := runner.Run(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] Exit code: %d\n", result.ExitCode)
+		}
+		return result.ExitCode, nil
+	}
+
+	if toolWatch {
+		paths := toolWatchPaths
+		if len(paths) == 0 {
+			paths = []string{workDir}
+		}
+		return watchLoop(func() []string { return paths }, execute)
+	}
+
+	exitCode, err := execute(context.Background())
 	if err != nil {
 		return err
 	}
 
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+
+	return nil
+}
+
+// toolProject returns the Composer project in the working directory, unless
+// --no-project is given.
+func toolProject() (*composer.Project, error) {
+	if toolNoProject {
+		return nil, nil
+	}
+
+	project, err := composer.FindProject(".")
+	if err != nil || project == nil {
+		return nil, err
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Project: %s\n", project.Dir)
+		if constraints := project.PHPConstraints(); len(constraints) > 0 {
+			fmt.Fprintf(os.Stderr, "[phpx] Project PHP: %s\n", strings.Join(constraints, ", "))
+		}
+		if project.Autoload == "" {
+			fmt.Fprintln(os.Stderr, "[phpx] Project dependencies are not installed; run 'composer install' to make its classes available")
+		}
+	}
+
+	return project, nil
+}
+
+// toolSpec describes a tool to install, from the command line or phpx.toml.
+type toolSpec struct {
+	Arg        string // Package[@version|:constraint], source or PHAR
+	PHP        string
+	Extensions []string
+	From       string
+	With       []string
+	Plugins    map[string]bool
+	Scripts    bool
+	Project    *composer.Project // Project whose PHP requirements apply; nil outside one
+}
+
+// newToolSpec describes the tool named by arg with the install flags given
+// on the command line. A binary selected with package:binary acts as --from.
+func newToolSpec(arg string) *toolSpec {
+	arg, binary := composer.SplitBinary(arg)
+	if toolFrom != "" {
+		binary = toolFrom
+	}
+
+	spec := &toolSpec{
+		Arg:     arg,
+		PHP:     toolPHP,
+		From:    binary,
+		With:    toolWith,
+		Plugins: parsePluginRules(toolPlugins),
+		Scripts: toolScripts,
+	}
+	if toolExtensions != "" {
+		spec.Extensions = strings.Split(toolExtensions, ",")
+	}
+	return spec
+}
+
+// preparedTool is an installed tool ready to run.
+type preparedTool struct {
+	Package    string
+	Version    string // Resolved Packagist version; empty for other sources
+	PHP        *php.Resolution
+	Dir        string
+	Binary     string
+	Entrypoint string // Path of the binary relative to Dir
+}
+
+// resolvedTool is the package version chosen for a tool, before PHP and the
+// tool are installed.
+type resolvedTool struct {
+	Package        string
+	Requested      string // Name the tool was asked for, e.g. the alias "phpcs"
+	Version        *composer.PackageVersion
+	Src            *composer.Source         // Git, path, archive or PHAR source; nil for Packagist
+	Source         *composer.ResolvedSource // Resolved Git, path or archive source
+	InstallVersion string                   // Identifies the installed content in the tool cache
+	PHPConstraints []string
+	Extensions     []string
+}
+
+// resolveTool picks the package version a tool runs, taking the PHP and
+// extension requirements of the spec and its project into account.
+func resolveTool(idx *index.Index, spec *toolSpec) (*resolvedTool, error) {
+	// Parse package and version
+	pkgName, versionConstraint, src := composer.ParseToolArg(spec.Arg)
+	if src == nil {
+		src = composer.PharAlias(pkgName, versionConstraint)
+	}
+	requested := pkgName
+	pkgName = composer.ResolveAlias(pkgName)
+
+	isPhar := src != nil && src.Type == composer.SourcePhar
+	if isPhar && (len(spec.With) > 0 || len(spec.Plugins) > 0 || spec.Scripts) {
+		return nil, fmt.Errorf("--with, --allow-plugin and --allow-scripts do not apply to PHAR tools")
+	}
+
+	if verbose {
+		if src != nil {
+			fmt.Fprintf(os.Stderr, "[phpx] Tool source: %s (%s)\n", src, src.Type)
+		} else {
+			fmt.Fprintf(os.Stderr, "[phpx] Tool: %s", pkgName)
+			if versionConstraint != "" {
+				fmt.Fprintf(os.Stderr, " (%s)", versionConstraint)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+
+	extensions := append([]string(nil), spec.Extensions...)
+	phpConstraints := []string{spec.PHP}
+
+	// The project's requirements apply unless a PHP version was chosen
+	if spec.Project != nil {
+		extensions = append(extensions, spec.Project.Extensions...)
+		if spec.PHP == "" {
+			phpConstraints = append(phpConstraints, spec.Project.PHPConstraints()...)
+		}
+	}
+
+	var version *composer.PackageVersion
+	var source *composer.ResolvedSource
+	var installVersion string
+	var err error
+
+	if isPhar {
+		src, err = composer.ResolvePhar(src)
+		if err != nil {
+			return nil, err
+		}
+
+		// A standalone PHAR has no Composer metadata to resolve
+		pkgName = "phar/" + strings.TrimSuffix(composer.PharName(src), ".phar")
+		version = &composer.PackageVersion{Bin: []string{composer.PharName(src)}}
+		installVersion = composer.PharKey(src)
+	} else if src != nil {
+		source, err = composer.ResolveSource(src)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgName = source.Name
+		version = &source.Package
+		installVersion = source.Key[:12]
+
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] Package: %s (%s)\n", pkgName, source.Constraint)
+		}
+	} else {
+		version, err = resolveToolVersion(idx, pkgName, versionConstraint, phpConstraints, extensions)
+		if err != nil {
+			return nil, err
+		}
+		installVersion = version.Version
+	}
+
+	return &resolvedTool{
+		Package:        pkgName,
+		Requested:      requested,
+		Version:        version,
+		Src:            src,
+		Source:         source,
+		InstallVersion: installVersion,
+		PHPConstraints: phpConstraints,
+		Extensions:     extensions,
+	}, nil
+}
+
+// prepareTool resolves a tool and the PHP build to run it with, installing
+// both if they are not cached.
+func prepareTool(idx *index.Index, spec *toolSpec) (*preparedTool, error) {
+	rt, err := resolveTool(idx, spec)
+	if err != nil {
+		return nil, err
+	}
+	pkgName, version, src, source, installVersion := rt.Package, rt.Version, rt.Src, rt.Source, rt.InstallVersion
+	phpConstraints, extensions := rt.PHPConstraints, rt.Extensions
+	isPhar := src != nil && src.Type == composer.SourcePhar
+
+	// Infer binary
+	binary, err := composer.InferBinary(pkgName, version.Bin, spec.From, rt.Requested)
+	var ambiguous *composer.AmbiguousBinaryError
+	if errors.As(err, &ambiguous) {
+		binary, err = selectBinary(ambiguous)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Binary: %s\n", binary)
+	}
+
+	// Resolve PHP satisfying --php, the project and the package's own requirements
+	requiredPHP, requiredExtensions := version.PlatformRequirements()
+	phpConstraints = append(phpConstraints, requiredPHP)
+	extensions = append(extensions, requiredExtensions...)
+
+	if verbose {
+		if len(nonEmpty(phpConstraints)) > 0 {
+			fmt.Fprintf(os.Stderr, "[phpx] Resolving PHP version for constraints '%s'\n", strings.Join(nonEmpty(phpConstraints), "' and '"))
+		} else {
+			fmt.Fprintln(os.Stderr, "[phpx] Resolving latest PHP version")
+		}
+	}
+
+	res, err := php.ResolveAll(idx, phpConstraints, extensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve PHP: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Matched: %s (%s tier)\n", res.Version, res.Tier)
+	}
+
+	// Ensure PHP is available
+	showProgress := !quiet && !verbose
+	if err := php.EnsurePHP(res, showProgress); err != nil {
+		return nil, err
+	}
+
+	// Check if tool is cached
+	tool := &composer.Tool{
+		Package:      pkgName,
+		Version:      installVersion,
+		With:         spec.With,
+		AllowPlugins: spec.Plugins,
+		AllowScripts: spec.Scripts,
+		Source:       source,
+	}
+
+	toolPath, err := cache.ToolPath(pkgName, installVersion, tool.Variant()...)
+	if err != nil {
+		return nil, err
+	}
+
+	entrypoint := filepath.Join("vendor", "bin", binary)
+	if isPhar {
+		entrypoint = composer.PharName(src)
+	}
+
+	if !cache.Exists(filepath.Join(toolPath, entrypoint)) {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] Installing %s@%s to %s\n", pkgName, installVersion, toolPath)
+			for _, pkg := range spec.With {
+				fmt.Fprintf(os.Stderr, "[phpx]   with %s\n", pkg)
+			}
+			if spec.Scripts {
+				fmt.Fprintln(os.Stderr, "[phpx]   with Composer scripts enabled")
+			}
+		}
+
+		if isPhar {
+			if err := composer.InstallPhar(res.Path, src, toolPath, verbose); err != nil {
+				return nil, err
+			}
+		} else {
+			composerPath, err := composerFor(idx, res.Version.String())
+			if err != nil {
+				return nil, err
+			}
+
+			// Install
+			if err := composer.InstallTool(res.Path, composerPath, tool, toolPath, verbose); err != nil {
+				return nil, err
+			}
+		}
+	} else if verbose {
+		fmt.Fprintln(os.Stderr, "[phpx] Tool cached")
+	}
+
+	prepared := &preparedTool{
+		Package:    pkgName,
+		PHP:        res,
+		Dir:        toolPath,
+		Binary:     binary,
+		Entrypoint: entrypoint,
+	}
+	if src == nil {
+		prepared.Version = version.Version
+	}
+	return prepared, nil
+}
+
+// resolveToolVersion fetches a package from Packagist and picks the newest
+// version matching constraint that can run on an available PHP build.
+func resolveToolVersion(idx *index.Index, pkgName, constraint string, phpConstraints, extensions []string) (*composer.PackageVersion, error) {
 	if verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Exit code: %d\n", result.ExitCode)
+		fmt.Fprintln(os.Stderr, "[phpx] Fetching package info from Packagist...")
 	}
 
-	if result.ExitCode != 0 {
-		os.Exit(result.ExitCode)
+	pkgInfo, err := composer.FetchPackage(pkgName)
+	if err != nil {
+		return nil, err
 	}
 
+	// Dev branches are published separately and only fetched when requested
+	if composer.AllowsDev(constraint) {
+		devVersions, err := composer.FetchDevVersions(pkgName)
+		if err != nil {
+			return nil, err
+		}
+		pkgInfo.Versions = append(pkgInfo.Versions, devVersions...)
+	}
+
+	version, skipped, err := composer.ResolveVersion(pkgInfo, constraint, toolPlatform(idx, phpConstraints, extensions))
+	if err != nil {
+		return nil, err
+	}
+
+	if verbose {
+		for _, sv := range skipped {
+			fmt.Fprintf(os.Stderr, "[phpx] Skipped %s: %s\n", sv.Version, sv.Reason)
+		}
+		fmt.Fprintf(os.Stderr, "[phpx] Resolved version: %s\n", version.Version)
+	}
+
+	return version, nil
+}
+
+// toolPlatform checks a release's requirements against the static PHP builds
+// in idx, together with the requested PHP constraints and extensions.
+func toolPlatform(idx *index.Index, phpConstraints, extensions []string) composer.PlatformCheck {
+	return func(required string, requiredExtensions []string) error {
+		all := append(append([]string{}, extensions...), requiredExtensions...)
+		_, err := php.ResolveAll(idx, append(append([]string{}, phpConstraints...), required), all)
+		return err
+	}
+}
+
+// applyManifest fills in a tool's settings from the project manifest.
+// Settings given on the command line take precedence.
+func applyManifest(spec *toolSpec, m *manifest.Manifest, name string, mt *manifest.Tool) {
+	spec.Arg = manifestArg(m, name, mt)
+	if spec.PHP == "" {
+		spec.PHP = m.PHPConstraint(mt)
+	}
+	spec.Extensions = append(spec.Extensions, mt.Extensions...)
+	if len(spec.With) == 0 {
+		spec.With = mt.With
+	}
+	for plugin, allow := range mt.AllowPlugins {
+		if _, ok := spec.Plugins[plugin]; !ok {
+			if spec.Plugins == nil {
+				spec.Plugins = make(map[string]bool)
+			}
+			spec.Plugins[plugin] = allow
+		}
+	}
+	spec.Scripts = spec.Scripts || mt.AllowScripts
+}
+
+// manifestArg returns the tool argument for a manifest tool: its locked
+// version when the lock still satisfies the declared constraint, otherwise
+// the constraint itself.
+func manifestArg(m *manifest.Manifest, name string, mt *manifest.Tool) string {
+	pkg := m.PackageName(name, mt)
+
+	// Sources and PHAR URLs are pinned by their own content
+	if _, _, src := composer.ParseToolArg(pkg); src != nil {
+		return pkg
+	}
+
+	if locked, ok := m.LockedVersion(name); ok {
+		return pkg + "@" + locked
+	}
+	if mt.Version != "" {
+		return pkg + ":" + mt.Version
+	}
+	return pkg
+}
+
+// lockTool records a manifest tool's resolved version in the lock section.
+func lockTool(m *manifest.Manifest, name string, tool *preparedTool) error {
+	if tool.Version == "" {
+		return nil
+	}
+
+	lock := manifest.Lock{Package: tool.Package, Version: tool.Version}
+	if m.Lock[name] == lock {
+		return nil
+	}
+
+	m.Lock[name] = lock
+	if err := m.SaveLock(); err != nil {
+		return fmt.Errorf("failed to update lock in %s: %w", m.Path(), err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Locked %s to %s@%s\n", name, tool.Package, tool.Version)
+	}
 	return nil
 }
+
+// nonEmpty returns the non-empty strings of list.
+func nonEmpty(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parsePluginRules converts --allow-plugin values into an allow-plugins map.
+// A bare name allows the plugin; "name=false" denies it explicitly.
+func parsePluginRules(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+
+	rules := make(map[string]bool, len(values))
+	for _, v := range values {
+		name, allow, found := strings.Cut(v, "=")
+		rules[name] = !found || allow != "false"
+	}
+	return rules
+}
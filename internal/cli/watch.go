@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/watch"
+)
+
+// watchLoop runs execute, then runs it again whenever a watched file
+// changes, cancelling a run still in progress. paths is evaluated before
+// each run, so files a script starts to include are picked up. It returns
+// only if watching fails; stop it with Ctrl+C.
+func watchLoop(paths func() []string, execute func(ctx context.Context) (int, error)) error {
+	for {
+		w, err := watch.New(paths()...)
+		if err != nil {
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+
+		changed := runWatched(w, execute)
+		w.Close()
+
+		if isTerminal(os.Stdout) {
+			fmt.Print("\033[H\033[2J")
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "[phpx] Changed: %s\n", describeChanges(changed))
+		}
+	}
+}
+
+// runWatched runs execute once, reporting how it ended, and returns the
+// paths whose change ended the run or the wait that followed it.
+func runWatched(w *watch.Watcher, execute func(ctx context.Context) (int, error)) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type outcome struct {
+		code int
+		err  error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+
+	go func() {
+		code, err := execute(ctx)
+		done <- outcome{code, err}
+	}()
+
+	select {
+	case out := <-done:
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if !quiet {
+			if out.err != nil {
+				fmt.Fprintf(os.Stderr, "[phpx] Error: %v\n", out.err)
+				fmt.Fprintf(os.Stderr, "[phpx] Failed after %s; watching for changes...\n", elapsed)
+			} else {
+				fmt.Fprintf(os.Stderr, "[phpx] Finished in %s (exit code %d); watching for changes...\n", elapsed, out.code)
+			}
+		}
+		return <-w.Changes()
+
+	case changed := <-w.Changes():
+		cancel()
+		<-done
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "[phpx] Cancelled after %s\n", time.Since(start).Round(time.Millisecond))
+		}
+		return changed
+	}
+}
+
+// describeChanges lists changed paths relative to the working directory.
+func describeChanges(paths []string) string {
+	wd, _ := os.Getwd()
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = p
+		if rel, err := filepath.Rel(wd, p); err == nil && !strings.HasPrefix(rel, "..") {
+			names[i] = rel
+		}
+	}
+	return strings.Join(names, ", ")
+}
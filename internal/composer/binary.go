@@ -3,6 +3,7 @@ package composer
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -18,20 +19,56 @@ var Aliases = map[string]string{
 	"phpcs":        "squizlabs/php_codesniffer",
 	"laravel":      "laravel/installer",
 	"psysh":        "psy/psysh",
+	"phpmd":        "phpmd/phpmd",
+	"deptrac":      "deptrac/deptrac",
+	"phpbench":     "phpbench/phpbench",
 }
 
-// ResolveAlias expands a tool name alias to its full package name.
+// ResolveAlias expands a tool name alias to its full package name. A name
+// without a vendor also matches an aliased package's short name, so
+// "php_codesniffer" expands to "squizlabs/php_codesniffer".
 // If the name is not an alias, it's returned unchanged.
 func ResolveAlias(name string) string {
 	if full, ok := Aliases[name]; ok {
 		return full
 	}
+
+	if !strings.Contains(name, "/") {
+		match := ""
+		for _, full := range Aliases {
+			if packageShortName(full) != name {
+				continue
+			}
+			if match != "" && match != full {
+				return name // Ambiguous between packages
+			}
+			match = full
+		}
+		if match != "" {
+			return match
+		}
+	}
+
 	return name
 }
 
+// AmbiguousBinaryError is returned by InferBinary when a package ships
+// several binaries and none is named after the package.
+type AmbiguousBinaryError struct {
+	Package  string
+	Binaries []string // Binary names, in package order
+}
+
+func (e *AmbiguousBinaryError) Error() string {
+	return fmt.Sprintf("%s has several binaries (%s); select one with %s:<binary> or --from",
+		e.Package, strings.Join(e.Binaries, ", "), e.Package)
+}
+
 // InferBinary determines the binary to execute for a package.
-// Priority: fromFlag > match short name > first binary
-func InferBinary(pkg string, bins []string, fromFlag string) (string, error) {
+// Priority: fromFlag > match requested name or short name > only binary.
+// requested is the name the tool was asked for, e.g. the alias "phpcs".
+// Several binaries with no match give an *AmbiguousBinaryError.
+func InferBinary(pkg string, bins []string, fromFlag, requested string) (string, error) {
 	if fromFlag != "" {
 		return fromFlag, nil
 	}
@@ -44,19 +81,56 @@ func InferBinary(pkg string, bins []string, fromFlag string) (string, error) {
 		return filepath.Base(bins[0]), nil
 	}
 
-	// Try to match package short name
-	shortName := packageShortName(pkg)
-	for _, bin := range bins {
-		base := filepath.Base(bin)
-		// Remove .phar suffix if present
-		base = strings.TrimSuffix(base, ".phar")
-		if base == shortName {
-			return filepath.Base(bin), nil
+	// Try to match the requested name, then the package short name
+	for _, name := range []string{requested, packageShortName(pkg)} {
+		for _, bin := range bins {
+			base := filepath.Base(bin)
+			// Remove .phar suffix if present
+			if name != "" && strings.TrimSuffix(base, ".phar") == name {
+				return base, nil
+			}
 		}
 	}
 
-	// Default to first binary
-	return filepath.Base(bins[0]), nil
+	names := make([]string, len(bins))
+	for i, bin := range bins {
+		names[i] = filepath.Base(bin)
+	}
+	return "", &AmbiguousBinaryError{Package: pkg, Binaries: names}
+}
+
+// binaryName matches a binary selected with "package:binary". Version
+// constraints such as "^1.10", "1.x-dev", "v2" or "dev-main" never match.
+var binaryName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// SplitBinary separates a binary selection from a tool argument, e.g.
+// "phpcs:phpcbf@^3.7" gives "phpcs@^3.7" and "phpcbf". A suffix after ':'
+// that reads as a version constraint is left in place.
+func SplitBinary(arg string) (string, string) {
+	if parseSource(arg) != nil {
+		return arg, ""
+	}
+
+	name, rest, ok := strings.Cut(arg, ":")
+	if !ok || strings.Contains(name, "@") {
+		return arg, ""
+	}
+
+	bin, version, sep := rest, "", ""
+	if i := strings.IndexAny(rest, "@:"); i != -1 {
+		bin, sep, version = rest[:i], rest[i:i+1], rest[i+1:]
+	}
+	if !binaryName.MatchString(bin) || strings.HasPrefix(bin, "dev-") || isVersionLike(bin) {
+		return arg, ""
+	}
+
+	return name + sep + version, bin
+}
+
+// isVersionLike reports whether s looks like a version, e.g. "v2" or "x".
+func isVersionLike(s string) bool {
+	s = strings.TrimPrefix(strings.ToLower(s), "v")
+	return s == "" || s == "x" || (s[0] >= '0' && s[0] <= '9')
 }
 
 // packageShortName returns the part after the vendor slash.
@@ -70,17 +144,22 @@ func packageShortName(pkg string) string {
 }
 
 // ParseToolArg parses a tool argument like "phpstan@1.10.0" or "phpstan:^1.10".
-// Returns package name and version constraint.
-func ParseToolArg(arg string) (pkg, version string) {
+// Returns package name and version constraint. VCS URLs ("git+https://…@ref"),
+// local paths ("./checkout") and archive URLs are returned as a source instead.
+func ParseToolArg(arg string) (pkg, version string, src *Source) {
+	if src := parseSource(arg); src != nil {
+		return "", "", src
+	}
+
 	// Check for @ (exact version)
 	if idx := strings.Index(arg, "@"); idx != -1 {
-		return arg[:idx], arg[idx+1:]
+		return arg[:idx], arg[idx+1:], nil
 	}
 
 	// Check for : (constraint)
 	if idx := strings.Index(arg, ":"); idx != -1 {
-		return arg[:idx], arg[idx+1:]
+		return arg[:idx], arg[idx+1:], nil
 	}
 
-	return arg, ""
+	return arg, "", nil
 }
@@ -0,0 +1,332 @@
+package composer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProjectRequirements are what a Composer project requires, in the terms a
+// script's // phpx block declares them.
+type ProjectRequirements struct {
+	Requirements
+	PHP        string   // require.php constraint
+	Extensions []string // Required extensions, without the ext- prefix
+}
+
+// projectFile is the composer.json written for an exported project. Field
+// order is the order keys appear in the file.
+type projectFile struct {
+	Type             string            `json:"type"`
+	Require          map[string]string `json:"require"`
+	Repositories     []Repository      `json:"repositories,omitempty"`
+	MinimumStability string            `json:"minimum-stability,omitempty"`
+	PreferStable     bool              `json:"prefer-stable,omitempty"`
+	Autoload         map[string]any    `json:"autoload,omitempty"`
+	Bin              []string          `json:"bin,omitempty"`
+	Config           *projectConfig    `json:"config,omitempty"`
+}
+
+type projectConfig struct {
+	AllowPlugins allowPlugins `json:"allow-plugins,omitempty"`
+}
+
+// hashedContent holds the composer.json keys Composer's content-hash
+// covers that exported projects use, in the sorted order Composer hashes
+// them in.
+type hashedContent struct {
+	MinimumStability string            `json:"minimum-stability,omitempty"`
+	PreferStable     bool              `json:"prefer-stable,omitempty"`
+	Repositories     []Repository      `json:"repositories,omitempty"`
+	Require          map[string]string `json:"require"`
+}
+
+// ProjectOptions describes the layout of an exported project.
+type ProjectOptions struct {
+	Autoload map[string]string // PSR-4 namespace prefixes to directories
+	Bin      []string          // Entrypoints listed in composer.json's bin
+	LockFile string            // composer.lock to carry over, if any
+}
+
+// WriteProject writes composer.json, and composer.lock when opts.LockFile is
+// set, for a project with the given requirements. The lock's content-hash is
+// recomputed for the new composer.json, so Composer installs the locked
+// versions without reporting it out of date.
+func WriteProject(dir string, req *ProjectRequirements, opts *ProjectOptions) error {
+	pf := projectFile{
+		Type:             "project",
+		Require:          projectRequire(req),
+		Repositories:     req.Repositories,
+		MinimumStability: req.MinimumStability,
+		PreferStable:     req.MinimumStability != "",
+		Bin:              opts.Bin,
+	}
+	if len(opts.Autoload) > 0 {
+		pf.Autoload = map[string]any{"psr-4": opts.Autoload}
+	}
+	if len(req.AllowPlugins) > 0 {
+		pf.Config = &projectConfig{AllowPlugins: req.AllowPlugins}
+	}
+
+	// Written as Composer writes it, without escaping constraints' < and >
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(pf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "composer.json"), data.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if opts.LockFile == "" {
+		return nil
+	}
+
+	hash, err := contentHash(hashedContent{
+		MinimumStability: pf.MinimumStability,
+		PreferStable:     pf.PreferStable,
+		Repositories:     pf.Repositories,
+		Require:          pf.Require,
+	})
+	if err != nil {
+		return err
+	}
+
+	lock, err := os.ReadFile(opts.LockFile)
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %w", err)
+	}
+	lock, err = relock(lock, hash, platformRequire(pf.Require))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "composer.lock"), lock, 0644)
+}
+
+// projectRequire returns the require section for a project's requirements.
+func projectRequire(req *ProjectRequirements) map[string]string {
+	require := make(map[string]string)
+	if req.PHP != "" {
+		require["php"] = req.PHP
+	}
+	for _, ext := range req.Extensions {
+		require["ext-"+strings.ToLower(ext)] = "*"
+	}
+	for _, pkg := range req.Packages {
+		name, constraint := parsePackage(pkg)
+		if constraint == "" {
+			constraint = "*"
+		}
+		require[name] = constraint
+	}
+	return require
+}
+
+// platformRequire returns the platform requirements among require, which a
+// lock file records.
+func platformRequire(require map[string]string) map[string]string {
+	platform := make(map[string]string)
+	for name, constraint := range require {
+		if isPlatformPackage(name) {
+			platform[name] = constraint
+		}
+	}
+	return platform
+}
+
+var (
+	contentHashPattern = regexp.MustCompile(`"content-hash": "[0-9a-f]*"`)
+	platformPattern    = regexp.MustCompile(`\n    "platform": (\[\]|\{\}|\{[^}]*\})`)
+)
+
+// relock rewrites a Composer lock file's content-hash and platform
+// requirements, leaving the locked packages as they are.
+func relock(lock []byte, hash string, platform map[string]string) ([]byte, error) {
+	if !contentHashPattern.Match(lock) {
+		return nil, fmt.Errorf("lock file has no content-hash")
+	}
+	lock = contentHashPattern.ReplaceAll(lock, []byte(`"content-hash": "`+hash+`"`))
+
+	value := "{}"
+	if len(platform) > 0 {
+		names := make([]string, 0, len(platform))
+		for name := range platform {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		b.WriteString("{")
+		for i, name := range names {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n        %s: %s", jsonString(name), jsonString(platform[name]))
+		}
+		b.WriteString("\n    }")
+		value = b.String()
+	}
+
+	return platformPattern.ReplaceAllLiteral(lock, []byte("\n    \"platform\": "+value)), nil
+}
+
+// jsonString encodes s as a JSON string without escaping < and >.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// contentHash returns Composer's content-hash for the relevant composer.json
+// content: the md5 of its encoding by PHP's json_encode.
+func contentHash(content hashedContent) (string, error) {
+	data, err := phpJSON(content)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// phpJSON encodes v as PHP's json_encode does without flags: slashes and
+// non-ASCII characters are escaped, HTML characters are not.
+func phpJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+	var out bytes.Buffer
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r == '/':
+			out.WriteString(`\/`)
+		case r >= utf8.RuneSelf && r > 0xFFFF:
+			r -= 0x10000
+			fmt.Fprintf(&out, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		case r >= utf8.RuneSelf:
+			fmt.Fprintf(&out, `\u%04x`, r)
+		default:
+			out.WriteRune(r)
+		}
+		data = data[size:]
+	}
+	return out.Bytes(), nil
+}
+
+// ReadRequirements reads a composer.json's requirements: PHP and extension
+// constraints, packages, repositories, minimum stability and allowed
+// plugins. Other platform packages, such as lib-* and composer-plugin-api,
+// are left out.
+func ReadRequirements(path string) (*ProjectRequirements, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cj struct {
+		Require          map[string]string `json:"require"`
+		Repositories     json.RawMessage   `json:"repositories"`
+		MinimumStability string            `json:"minimum-stability"`
+		Config           struct {
+			AllowPlugins allowPlugins `json:"allow-plugins"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	req := &ProjectRequirements{
+		PHP: cj.Require["php"],
+		Requirements: Requirements{
+			MinimumStability: cj.MinimumStability,
+			AllowPlugins:     cj.Config.AllowPlugins,
+		},
+	}
+
+	names := make([]string, 0, len(cj.Require))
+	for name := range cj.Require {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ext, ok := strings.CutPrefix(name, "ext-"); ok {
+			req.Extensions = append(req.Extensions, strings.ToLower(ext))
+			continue
+		}
+		if isPlatformPackage(name) {
+			continue
+		}
+		req.Packages = append(req.Packages, name+":"+cj.Require[name])
+	}
+
+	repos, err := readRepositories(cj.Repositories)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repositories in %s: %w", path, err)
+	}
+	req.Repositories = repos
+
+	return req, nil
+}
+
+// readRepositories reads composer.json repositories, given as a list or as
+// an object keyed by name. Entries disabling Packagist are skipped.
+func readRepositories(raw json.RawMessage) ([]Repository, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &named); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(named))
+		for key := range named {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entries = append(entries, named[key])
+		}
+	}
+
+	var repos []Repository
+	for _, entry := range entries {
+		var repo Repository
+		if err := json.Unmarshal(entry, &repo); err != nil {
+			continue // e.g. {"packagist.org": false}
+		}
+		if repo.Type == "" || repo.URL == "" {
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// platformPackage matches names provided by the platform rather than
+// installed by Composer, as Composer's PlatformRepository defines them.
+var platformPackage = regexp.MustCompile(`^(?i:php(?:-64bit|-ipv6|-zts|-debug)?|hhvm|(?:ext|lib)-[a-z0-9](?:[_.-]?[a-z0-9]+)*|composer(?:-(?:plugin|runtime)-api)?)$`)
+
+func isPlatformPackage(name string) bool {
+	return platformPackage.MatchString(name)
+}
@@ -0,0 +1,206 @@
+package composer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	got, err := contentHash(hashedContent{
+		MinimumStability: "dev",
+		PreferStable:     true,
+		Repositories:     []Repository{{Type: "vcs", URL: "https://github.com/acme/lib"}},
+		Require:          map[string]string{"php": ">=8.2", "ext-redis": "*", "acme/lib": "dev-main"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// md5 of PHP's json_encode of the same content
+	if want := "76711151a1478e77bc8bffec79e526ee"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPHPJSON(t *testing.T) {
+	got, err := phpJSON(map[string]string{"path": "a/b", "name": "café 🐘", "html": "<&>"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `{"html":"<&>","name":"caf\u00e9 \ud83d\udc18","path":"a\/b"}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestWriteProject(t *testing.T) {
+	dir := t.TempDir()
+	lockFile := filepath.Join(t.TempDir(), "composer.lock")
+	lock := `{
+    "_readme": [
+        "This file locks the dependencies of your project to a known state"
+    ],
+    "content-hash": "0123456789abcdef0123456789abcdef",
+    "packages": [
+        {
+            "name": "acme/lib",
+            "version": "1.2.0"
+        }
+    ],
+    "packages-dev": [],
+    "aliases": [],
+    "minimum-stability": "stable",
+    "stability-flags": {},
+    "prefer-stable": false,
+    "prefer-lowest": false,
+    "platform": {},
+    "platform-dev": {},
+    "plugin-api-version": "2.6.0"
+}
+`
+	if err := os.WriteFile(lockFile, []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &ProjectRequirements{
+		Requirements: Requirements{
+			Packages:     []string{"acme/lib:^1.0", "acme/tools"},
+			AllowPlugins: map[string]bool{"acme/plugin": true},
+		},
+		PHP:        ">=8.2",
+		Extensions: []string{"Redis"},
+	}
+	err := WriteProject(dir, req, &ProjectOptions{
+		Autoload: map[string]string{`App\`: "src/"},
+		Bin:      []string{"bin/tool"},
+		LockFile: lockFile,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("writes composer.json from the requirements", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got struct {
+			Require  map[string]string            `json:"require"`
+			Autoload map[string]map[string]string `json:"autoload"`
+			Bin      []string                     `json:"bin"`
+			Config   struct {
+				AllowPlugins map[string]bool `json:"allow-plugins"`
+			} `json:"config"`
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		wantRequire := map[string]string{"php": ">=8.2", "ext-redis": "*", "acme/lib": "^1.0", "acme/tools": "*"}
+		if !reflect.DeepEqual(got.Require, wantRequire) {
+			t.Errorf("got require %v, want %v", got.Require, wantRequire)
+		}
+		if got.Autoload["psr-4"][`App\`] != "src/" {
+			t.Errorf("got autoload %v, want App\\ in src/", got.Autoload)
+		}
+		if !reflect.DeepEqual(got.Bin, []string{"bin/tool"}) {
+			t.Errorf("got bin %v, want [bin/tool]", got.Bin)
+		}
+		if !got.Config.AllowPlugins["acme/plugin"] {
+			t.Errorf("got allow-plugins %v, want acme/plugin allowed", got.Config.AllowPlugins)
+		}
+	})
+
+	t.Run("relocks for the new composer.json", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "composer.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(data)
+
+		hash, _ := contentHash(hashedContent{Require: projectRequire(req)})
+		if !strings.Contains(got, `"content-hash": "`+hash+`"`) {
+			t.Errorf("lock does not have content-hash %s:\n%s", hash, got)
+		}
+		if !strings.Contains(got, "\"platform\": {\n        \"ext-redis\": \"*\",\n        \"php\": \">=8.2\"\n    },") {
+			t.Errorf("lock does not record platform requirements:\n%s", got)
+		}
+		if !strings.Contains(got, `"name": "acme/lib"`) || !strings.Contains(got, `"platform-dev": {}`) {
+			t.Errorf("lock lost its packages:\n%s", got)
+		}
+	})
+
+	t.Run("writes no lock without a lock file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		if err := WriteProject(dir, req, &ProjectOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "composer.lock")); !os.IsNotExist(err) {
+			t.Errorf("got lock file, want none")
+		}
+	})
+}
+
+func TestReadRequirements(t *testing.T) {
+	dir := writeProject(t, `{
+		"require": {
+			"php": "^8.2",
+			"ext-Intl": "*",
+			"lib-curl": "*",
+			"composer-plugin-api": "^2.0",
+			"php-http/discovery": "^1.19",
+			"symfony/console": "^7.0"
+		},
+		"require-dev": {"phpunit/phpunit": "^11.0"},
+		"repositories": [
+			{"type": "vcs", "url": "https://github.com/acme/lib"},
+			{"packagist.org": false}
+		],
+		"minimum-stability": "beta",
+		"config": {"allow-plugins": {"php-http/discovery": true}}
+	}`)
+
+	got, err := ReadRequirements(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &ProjectRequirements{
+		Requirements: Requirements{
+			Packages:         []string{"php-http/discovery:^1.19", "symfony/console:^7.0"},
+			Repositories:     []Repository{{Type: "vcs", URL: "https://github.com/acme/lib"}},
+			MinimumStability: "beta",
+			AllowPlugins:     map[string]bool{"php-http/discovery": true},
+		},
+		PHP:        "^8.2",
+		Extensions: []string{"intl"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadRequirements_named_repositories(t *testing.T) {
+	dir := writeProject(t, `{
+		"require": {},
+		"repositories": {"lib": {"type": "path", "url": "../lib"}}
+	}`)
+
+	got, err := ReadRequirements(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Repository{{Type: "path", URL: "../lib"}}
+	if !reflect.DeepEqual(got.Repositories, want) {
+		t.Errorf("got %+v, want %+v", got.Repositories, want)
+	}
+}
@@ -1,11 +1,15 @@
 package composer
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/eddmann/phpx/internal/cache"
@@ -14,31 +18,164 @@ import (
 
 // composerJSON is the structure for composer.json.
 type composerJSON struct {
-	Require map[string]string `json:"require"`
-	Config  composerConfig    `json:"config"`
+	Require          map[string]string `json:"require"`
+	Repositories     []Repository      `json:"repositories,omitempty"`
+	MinimumStability string            `json:"minimum-stability,omitempty"`
+	PreferStable     bool              `json:"prefer-stable,omitempty"`
+	Config           composerConfig    `json:"config"`
+	Extra            *composerExtra    `json:"extra,omitempty"`
 }
 
 type composerConfig struct {
-	AllowPlugins       bool `json:"allow-plugins"`
-	OptimizeAutoloader bool `json:"optimize-autoloader"`
+	AllowPlugins       allowPlugins `json:"allow-plugins"`
+	OptimizeAutoloader bool         `json:"optimize-autoloader"`
 }
 
+// composerExtra records phpx install settings, so later reinstalls and
+// upgrades of the directory behave the same way.
+type composerExtra struct {
+	AllowScripts bool `json:"phpx-allow-scripts,omitempty"`
+}
+
+func extraFor(allowScripts bool) *composerExtra {
+	if !allowScripts {
+		return nil
+	}
+	return &composerExtra{AllowScripts: true}
+}
+
+// allowPlugins maps Composer plugin names (or patterns such as "acme/*") to
+// whether they may run. It encodes as false when empty. Otherwise exact names
+// come before patterns and a catch-all deny is appended, since Composer
+// applies the first matching rule and would fail a non-interactive install
+// on a plugin that matches none.
+type allowPlugins map[string]bool
+
+func (a allowPlugins) MarshalJSON() ([]byte, error) {
+	if len(a) == 0 {
+		return []byte("false"), nil
+	}
+
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		iPattern, jPattern := strings.Contains(names[i], "*"), strings.Contains(names[j], "*")
+		if iPattern != jPattern {
+			return !iPattern
+		}
+		return names[i] < names[j]
+	})
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":" + strconv.FormatBool(a[name]) + ",")
+	}
+	if _, ok := a["*"]; !ok {
+		buf.WriteString(`"*":false,`)
+	}
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (a *allowPlugins) UnmarshalJSON(data []byte) error {
+	var all bool
+	if err := json.Unmarshal(data, &all); err == nil {
+		*a = nil
+		if all {
+			*a = allowPlugins{"*": true}
+		}
+		return nil
+	}
+
+	var rules map[string]bool
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	*a = rules
+	return nil
+}
+
+// enabled reports whether any plugin may run.
+func (a allowPlugins) enabled() bool {
+	for _, allow := range a {
+		if allow {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository is an additional Composer package repository.
+type Repository struct {
+	Type    string         `json:"type" toml:"type"`
+	URL     string         `json:"url" toml:"url"`
+	Options map[string]any `json:"options,omitempty" toml:"options"`
+}
+
+// String returns the repository as type:url, used in cache keys.
+func (r Repository) String() string {
+	return r.Type + ":" + r.URL
+}
+
+// Requirements describes a dependency set to install.
+type Requirements struct {
+	Packages         []string
+	Repositories     []Repository
+	MinimumStability string
+	AllowPlugins     map[string]bool // Composer plugins permitted to run
+	AllowScripts     bool            // Run Composer scripts during install
+}
+
+// Command is a Composer invocation.
+type Command struct {
+	PHPBinary  string
+	Composer   string   // Path to composer.phar
+	Args       []string // Arguments after composer.phar
+	Dir        string
+	Env        []string // Composer settings added to the filtered environment
+	ReadPaths  []string // Local package sources Composer must be able to read
+	WritePaths []string // Paths Composer must be able to write
+	Verbose    bool
+}
+
+// RunSandboxed runs Composer for installs that enable plugins or scripts, so
+// third-party code executes inside the sandbox. When it is nil or returns
+// ErrNoSandbox, such installs run on the host.
+var RunSandboxed func(cmd *Command) error
+
+// ErrNoSandbox is returned by RunSandboxed when no sandbox is available.
+var ErrNoSandbox = errors.New("no sandbox available")
+
 // InstallDeps installs packages to a dependency directory.
-func InstallDeps(phpPath, composerPath string, packages []string, destDir string, verbose bool) error {
+func InstallDeps(phpPath, composerPath string, req *Requirements, destDir string, verbose bool) error {
 	if err := cache.EnsureDir(destDir); err != nil {
 		return err
 	}
 
 	// Generate composer.json
 	cj := composerJSON{
-		Require: make(map[string]string),
+		Require:          make(map[string]string),
+		Repositories:     req.Repositories,
+		MinimumStability: req.MinimumStability,
+		// Prefer stable releases even when unstable ones are permitted
+		PreferStable: req.MinimumStability != "",
 		Config: composerConfig{
-			AllowPlugins:       false,
+			AllowPlugins:       req.AllowPlugins,
 			OptimizeAutoloader: true,
 		},
+		Extra: extraFor(req.AllowScripts),
 	}
 
-	for _, pkg := range packages {
+	for _, pkg := range req.Packages {
 		name, constraint := parsePackage(pkg)
 		if constraint == "" {
 			constraint = "*"
@@ -46,110 +183,234 @@ func InstallDeps(phpPath, composerPath string, packages []string, destDir string
 		cj.Require[name] = constraint
 	}
 
-	composerJSONPath := filepath.Join(destDir, "composer.json")
-	data, err := json.MarshalIndent(cj, "", "  ")
-	if err != nil {
+	if err := writeComposerJSON(destDir, cj); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(composerJSONPath, data, 0644); err != nil {
-		return err
-	}
-
-	// Run composer install
-	args := []string{
-		composerPath,
-		"install",
-		"--no-dev",
-		"--no-interaction",
-		"--no-scripts",
-		"--prefer-dist",
-		"--optimize-autoloader",
+	if err := runComposer(phpPath, composerPath, destDir, "install", verbose); err != nil {
+		return fmt.Errorf("failed to install packages %v: %w", req.Packages, err)
 	}
 
-	if !verbose {
-		args = append(args, "--quiet")
-	}
+	return MarkRefreshed(destDir)
+}
 
-	cmd := exec.Command(phpPath, args...)
-	cmd.Dir = destDir
-	// Use filtered environment to avoid leaking secrets to package install scripts
-	cmd.Env = append(util.FilterEnv(nil), "COMPOSER_HOME="+filepath.Join(destDir, ".composer"))
+// Tool describes a tool installation.
+type Tool struct {
+	Package      string
+	Version      string
+	With         []string        // Extra packages as vendor/name:constraint
+	AllowPlugins map[string]bool // Composer plugins permitted to run
+	AllowScripts bool            // Run Composer scripts during install
+	Source       *ResolvedSource // Set when installing from outside Packagist
+}
 
-	if verbose {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+// Variant returns the installation options beyond package and version, in a
+// stable order, for use in the tool cache key.
+func (t *Tool) Variant() []string {
+	var variant []string
+	for _, pkg := range t.With {
+		variant = append(variant, "with:"+pkg)
 	}
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to install packages %v: %w", packages, err)
+	for plugin, allow := range t.AllowPlugins {
+		variant = append(variant, "plugin:"+plugin+"="+strconv.FormatBool(allow))
 	}
-
-	return nil
+	if t.AllowScripts {
+		variant = append(variant, "scripts")
+	}
+	if t.Source != nil {
+		variant = append(variant, "source:"+t.Source.Repository.String()+"@"+t.Source.Key)
+	}
+	sort.Strings(variant)
+	return variant
 }
 
-// InstallTool installs a tool package to a directory.
-func InstallTool(phpPath, composerPath string, pkg, version, destDir string, verbose bool) error {
+// InstallTool installs a tool package, along with any extra packages, to a directory.
+func InstallTool(phpPath, composerPath string, tool *Tool, destDir string, verbose bool) error {
 	if err := cache.EnsureDir(destDir); err != nil {
 		return err
 	}
 
 	// Generate composer.json
-	constraint := version
+	constraint := tool.Version
 	if constraint == "" {
 		constraint = "*"
 	}
 
 	cj := composerJSON{
 		Require: map[string]string{
-			pkg: constraint,
+			tool.Package: constraint,
 		},
 		Config: composerConfig{
-			AllowPlugins:       false,
+			AllowPlugins:       tool.AllowPlugins,
 			OptimizeAutoloader: true,
 		},
+		Extra: extraFor(tool.AllowScripts),
+	}
+
+	if src := tool.Source; src != nil {
+		cj.Require[tool.Package] = src.Constraint
+		cj.Repositories = []Repository{src.Repository}
+		// Forks and checkouts commonly depend on unreleased versions
+		cj.MinimumStability = "dev"
+		cj.PreferStable = true
+	}
+
+	for _, pkg := range tool.With {
+		name, constraint := parsePackage(pkg)
+		if constraint == "" {
+			constraint = "*"
+		}
+		cj.Require[name] = constraint
+	}
+
+	if err := writeComposerJSON(destDir, cj); err != nil {
+		return err
+	}
+
+	if err := runComposer(phpPath, composerPath, destDir, "install", verbose); err != nil {
+		return fmt.Errorf("failed to install tool %s@%s: %w", tool.Package, tool.Version, err)
 	}
 
-	composerJSONPath := filepath.Join(destDir, "composer.json")
+	return nil
+}
+
+// Reinstall discards an installation's vendor tree and reinstalls it from the
+// existing composer.json and composer.lock, restoring the locked versions.
+func Reinstall(phpPath, composerPath, destDir string, verbose bool) error {
+	if err := os.RemoveAll(filepath.Join(destDir, "vendor")); err != nil {
+		return err
+	}
+
+	if err := runComposer(phpPath, composerPath, destDir, "install", verbose); err != nil {
+		return fmt.Errorf("failed to reinstall %s: %w", destDir, err)
+	}
+
+	return nil
+}
+
+// writeComposerJSON writes the generated composer.json into destDir.
+func writeComposerJSON(destDir string, cj composerJSON) error {
 	data, err := json.MarshalIndent(cj, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(composerJSONPath, data, 0644); err != nil {
+	return os.WriteFile(filepath.Join(destDir, "composer.json"), data, 0644)
+}
+
+// runComposer runs composer install or update in destDir using the shared
+// download cache, then deduplicates the installed vendor tree.
+//
+// Installs whose composer.json enables plugins or scripts run through
+// RunSandboxed when set; all others run on the host with scripts disabled.
+func runComposer(phpPath, composerPath, destDir, command string, verbose bool) error {
+	composerCache, err := cache.ComposerCacheDir()
+	if err != nil {
+		return err
+	}
+
+	cj, err := readComposerJSON(destDir)
+	if err != nil {
 		return err
 	}
 
-	// Run composer install
 	args := []string{
-		composerPath,
-		"install",
+		command,
 		"--no-dev",
 		"--no-interaction",
-		"--no-scripts",
 		"--prefer-dist",
 		"--optimize-autoloader",
 	}
 
+	allowScripts := cj.Extra != nil && cj.Extra.AllowScripts
+	if !allowScripts {
+		args = append(args, "--no-scripts")
+	}
+
 	if !verbose {
 		args = append(args, "--quiet")
 	}
 
-	cmd := exec.Command(phpPath, args...)
-	cmd.Dir = destDir
+	composerHome := filepath.Join(destDir, ".composer")
+	cmd := &Command{
+		PHPBinary: phpPath,
+		Composer:  composerPath,
+		Args:      args,
+		Dir:       destDir,
+		Env: []string{
+			"COMPOSER_HOME=" + composerHome,
+			"COMPOSER_CACHE_DIR=" + composerCache,
+			"TMPDIR=" + filepath.Join(composerHome, "tmp"),
+		},
+		ReadPaths:  localRepositories(cj.Repositories),
+		WritePaths: []string{destDir, composerCache},
+		Verbose:    verbose,
+	}
+
+	if err := cache.EnsureDir(filepath.Join(composerHome, "tmp")); err != nil {
+		return err
+	}
+
+	err = ErrNoSandbox
+	if (cj.Config.AllowPlugins.enabled() || allowScripts) && RunSandboxed != nil {
+		err = RunSandboxed(cmd)
+		// Always warned about, since third-party code then runs unconfined
+		if errors.Is(err, ErrNoSandbox) {
+			fmt.Fprintln(os.Stderr, "[phpx] Warning: no sandbox available, Composer plugins and scripts run on the host")
+		}
+	}
+	if errors.Is(err, ErrNoSandbox) {
+		err = runOnHost(cmd)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Deduplication only saves disk space, so a failure here is not fatal
+	_ = cache.Dedupe(filepath.Join(destDir, "vendor"))
+
+	// Record digests so 'phpx cache verify' can detect later tampering
+	return cache.WriteManifest(destDir, phpPath, "composer.lock", "vendor")
+}
+
+// runOnHost runs a Composer command directly.
+func runOnHost(c *Command) error {
+	cmd := exec.Command(c.PHPBinary, append([]string{c.Composer}, c.Args...)...)
+	cmd.Dir = c.Dir
 	// Use filtered environment to avoid leaking secrets to package install scripts
-	cmd.Env = append(util.FilterEnv(nil), "COMPOSER_HOME="+filepath.Join(destDir, ".composer"))
+	cmd.Env = append(util.FilterEnv(nil), c.Env...)
 
-	if verbose {
+	if c.Verbose {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to install tool %s@%s: %w", pkg, version, err)
+	return cmd.Run()
+}
+
+// localRepositories returns the directories of path repositories.
+func localRepositories(repos []Repository) []string {
+	var dirs []string
+	for _, repo := range repos {
+		if repo.Type == "path" {
+			dirs = append(dirs, repo.URL)
+		}
 	}
+	return dirs
+}
 
-	return nil
+// readComposerJSON reads the composer.json generated in dir.
+func readComposerJSON(dir string) (*composerJSON, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cj composerJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return nil, fmt.Errorf("invalid composer.json: %w", err)
+	}
+	return &cj, nil
 }
 
 // parsePackage splits "vendor/package:constraint" into name and constraint.
@@ -0,0 +1,121 @@
+package composer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAllowPlugins_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins allowPlugins
+		want    string
+	}{
+		{
+			name:    "encodes false when no plugins are allowed",
+			plugins: nil,
+			want:    `false`,
+		},
+		{
+			name:    "lists allowed plugins before a catch-all deny",
+			plugins: allowPlugins{"phpstan/extension-installer": true},
+			want:    `{"phpstan/extension-installer":true,"*":false}`,
+		},
+		{
+			name:    "orders exact names before patterns",
+			plugins: allowPlugins{"pestphp/*": true, "php-http/discovery": false, "acme/plugin": true},
+			want:    `{"acme/plugin":true,"php-http/discovery":false,"pestphp/*":true,"*":false}`,
+		},
+		{
+			name:    "keeps an explicit catch-all",
+			plugins: allowPlugins{"*": true},
+			want:    `{"*":true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.plugins)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowPlugins_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  allowPlugins
+	}{
+		{
+			name:  "reads false as no plugins",
+			input: `false`,
+			want:  nil,
+		},
+		{
+			name:  "reads true as all plugins",
+			input: `true`,
+			want:  allowPlugins{"*": true},
+		},
+		{
+			name:  "reads an object as rules",
+			input: `{"phpstan/extension-installer":true,"*":false}`,
+			want:  allowPlugins{"phpstan/extension-installer": true, "*": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got allowPlugins
+			err := json.Unmarshal([]byte(tt.input), &got)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolVariant(t *testing.T) {
+	t.Run("is empty for a plain tool", func(t *testing.T) {
+		tool := &Tool{Package: "phpstan/phpstan", Version: "2.1.0"}
+
+		if got := tool.Variant(); len(got) != 0 {
+			t.Errorf("got %v, want empty", got)
+		}
+	})
+
+	t.Run("is independent of flag order", func(t *testing.T) {
+		a := &Tool{
+			With:         []string{"phpstan/phpstan-symfony:^2.0", "phpstan/extension-installer"},
+			AllowPlugins: map[string]bool{"phpstan/extension-installer": true},
+		}
+		b := &Tool{
+			With:         []string{"phpstan/extension-installer", "phpstan/phpstan-symfony:^2.0"},
+			AllowPlugins: map[string]bool{"phpstan/extension-installer": true},
+		}
+
+		if !reflect.DeepEqual(a.Variant(), b.Variant()) {
+			t.Errorf("got %v and %v, want equal", a.Variant(), b.Variant())
+		}
+	})
+
+	t.Run("differs when scripts are allowed", func(t *testing.T) {
+		a := &Tool{Package: "phpstan/phpstan"}
+		b := &Tool{Package: "phpstan/phpstan", AllowScripts: true}
+
+		if reflect.DeepEqual(a.Variant(), b.Variant()) {
+			t.Errorf("got equal variants %v, want different", a.Variant())
+		}
+	})
+}
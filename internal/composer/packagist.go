@@ -3,25 +3,27 @@ package composer
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/Masterminds/semver/v3"
+	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/version"
 )
 
-// NormalizeConstraint converts Composer-style constraints to semver-compatible format.
-// Composer uses single pipe (|) for OR, semver uses double pipe (||).
-func NormalizeConstraint(constraint string) string {
-	// Split on || first to preserve already-correct double pipes
-	parts := strings.Split(constraint, "||")
-	for i, part := range parts {
-		// Replace remaining single | with ||
-		parts[i] = strings.ReplaceAll(part, "|", " || ")
-	}
-	return strings.Join(parts, " || ")
-}
+// RepoURL is the base URL of the Packagist metadata v2 API.
+var RepoURL = "https://repo.packagist.org/p2/"
 
-const PackagistURL = "https://packagist.org/packages/"
+// MetadataTTL is how long cached metadata is used without revalidation.
+const MetadataTTL = 5 * time.Minute
+
+// httpClient bounds Packagist requests so an unreachable network falls back
+// to cached metadata promptly.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
 
 // PackageInfo contains information about a Composer package.
 type PackageInfo struct {
@@ -33,49 +35,135 @@ type PackageInfo struct {
 type PackageVersion struct {
 	Version           string            `json:"version"`
 	VersionNormalized string            `json:"version_normalized"`
+	Description       string            `json:"description"`
 	Require           map[string]string `json:"require"`
 	Bin               []string          `json:"bin"`
 	Type              string            `json:"type"`
+	Extra             PackageExtra      `json:"extra"`
 }
 
-// packagistResponse is the raw API response structure.
-type packagistResponse struct {
-	Package struct {
-		Name     string                    `json:"name"`
-		Versions map[string]PackageVersion `json:"versions"`
-	} `json:"package"`
+// PackageExtra holds the parts of a package's "extra" section phpx uses.
+type PackageExtra struct {
+	// BranchAlias maps a branch version such as "dev-main" to the version it
+	// stands in for, such as "2.x-dev".
+	BranchAlias map[string]string `json:"branch-alias"`
+}
+
+// p2Response is the raw metadata v2 response structure. Versions are
+// minified: each entry only lists the fields that differ from the previous one.
+type p2Response struct {
+	Packages map[string][]map[string]json.RawMessage `json:"packages"`
 }
 
 // FetchPackage retrieves package information from Packagist.
+//
+// Responses are cached on disk and revalidated with If-Modified-Since. When
+// Packagist is unreachable, cached metadata is used, falling back to the
+// versions of the package already installed as tools.
 func FetchPackage(name string) (*PackageInfo, error) {
-	url := PackagistURL + name + ".json"
+	return fetchMetadata(name, name)
+}
+
+// FetchDevVersions retrieves the dev branch versions of a package, which
+// Packagist publishes separately from tagged releases.
+func FetchDevVersions(name string) ([]PackageVersion, error) {
+	info, err := fetchMetadata(name, name+"~dev")
+	if err != nil {
+		return nil, err
+	}
+	return info.Versions, nil
+}
+
+// AllowsDev reports whether a constraint admits dev branches, meaning
+// FetchDevVersions is needed to resolve it.
+func AllowsDev(constraint string) bool {
+	return version.StabilityFlag(constraint) == "dev"
+}
+
+// fetchMetadata fetches and caches the metadata document file for a package.
+func fetchMetadata(name, file string) (*PackageInfo, error) {
+	cachePath, err := cache.PackagistPath(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, lastModified, fetchedAt := readCachedMetadata(cachePath)
+	if cached != nil && time.Since(fetchedAt) < MetadataTTL {
+		return parseMetadata(name, cached)
+	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest(http.MethodGet, RepoURL+file+".json", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch package: %w", err)
+		return nil, err
+	}
+	if cached != nil && lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return offlineFallback(name, file, cached, fmt.Errorf("failed to fetch package: %w", err))
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode == http.StatusNotFound {
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		_ = touchCachedMetadata(cachePath)
+		return parseMetadata(name, cached)
+	case http.StatusNotFound:
 		return nil, fmt.Errorf("package not found: %s", name)
+	case http.StatusOK:
+	default:
+		return offlineFallback(name, file, cached, fmt.Errorf("packagist returned HTTP %d", resp.StatusCode))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return offlineFallback(name, file, cached, fmt.Errorf("failed to read response: %w", err))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("packagist returned HTTP %d", resp.StatusCode)
+	info, err := parseMetadata(name, data)
+	if err != nil {
+		return nil, err
 	}
 
-	var data packagistResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	// Caching is an optimisation, so a failed write is not fatal
+	_ = writeCachedMetadata(cachePath, data, resp.Header.Get("Last-Modified"))
+
+	return info, nil
+}
+
+// parseMetadata decodes a metadata v2 document, expanding minified versions.
+func parseMetadata(name string, data []byte) (*PackageInfo, error) {
+	var resp p2Response
+	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	if data.Package.Name == "" {
+	entries, ok := resp.Packages[name]
+	if !ok {
 		return nil, fmt.Errorf("package not found: %s", name)
 	}
 
-	// Convert map to slice
-	versions := make([]PackageVersion, 0, len(data.Package.Versions))
-	for _, v := range data.Package.Versions {
+	versions := make([]PackageVersion, 0, len(entries))
+	expanded := make(map[string]json.RawMessage)
+	for _, entry := range entries {
+		for key, val := range entry {
+			if string(val) == `"__unset"` {
+				delete(expanded, key)
+			} else {
+				expanded[key] = val
+			}
+		}
+
+		raw, err := json.Marshal(expanded)
+		if err != nil {
+			return nil, err
+		}
+		var v PackageVersion
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("failed to parse version: %w", err)
+		}
 		versions = append(versions, v)
 	}
 
@@ -85,99 +173,258 @@ func FetchPackage(name string) (*PackageInfo, error) {
 	}, nil
 }
 
-// ResolveVersion finds the best matching version for a constraint.
-// If constraint is empty, returns the latest stable version.
-func ResolveVersion(pkg *PackageInfo, constraint string) (*PackageVersion, error) {
-	if constraint == "" {
-		return latestStable(pkg.Versions)
+// offlineFallback resolves a package without Packagist, first from cached
+// metadata and then, for tagged releases, from tool installations. fetchErr
+// is returned when neither exists.
+func offlineFallback(name, file string, cached []byte, fetchErr error) (*PackageInfo, error) {
+	if cached != nil {
+		return parseMetadata(name, cached)
+	}
+	if file != name {
+		return nil, fetchErr
+	}
+
+	versions, err := InstalledToolVersions(name)
+	if err != nil || len(versions) == 0 {
+		return nil, fetchErr
 	}
 
-	normalized := NormalizeConstraint(constraint)
-	c, err := semver.NewConstraint(normalized)
+	return &PackageInfo{
+		Name:     name,
+		Versions: versions,
+	}, nil
+}
+
+// InstalledToolVersions returns the versions of a package found in the tool
+// cache, read from each installation's vendor/composer/installed.json.
+func InstalledToolVersions(name string) ([]PackageVersion, error) {
+	toolsDir, err := cache.ToolsDir()
 	if err != nil {
-		return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+		return nil, err
 	}
 
-	// Sort versions descending and find first match
-	var candidates []*PackageVersion
-	for i := range pkg.Versions {
-		v := &pkg.Versions[i]
-		if isPrerelease(v.Version) {
+	entries, err := os.ReadDir(toolsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ReplaceAll(name, "/", "-") + "-"
+	seen := make(map[string]bool)
+	var versions []PackageVersion
+
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
 			continue
 		}
 
-		sv, err := semver.NewVersion(v.Version)
+		installed, err := readInstalled(filepath.Join(toolsDir, e.Name()))
 		if err != nil {
 			continue
 		}
 
-		if c.Check(sv) {
-			candidates = append(candidates, v)
+		for _, v := range installed {
+			if v.Name == name && !seen[v.Version] {
+				seen[v.Version] = true
+				versions = append(versions, v.PackageVersion)
+			}
 		}
 	}
 
-	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no version satisfies constraint %q", constraint)
-	}
+	return versions, nil
+}
 
-	// Return highest matching version
-	return highestVersion(candidates)
+// installedPackage is an entry of vendor/composer/installed.json.
+type installedPackage struct {
+	Name string `json:"name"`
+	PackageVersion
 }
 
-// latestStable returns the highest non-prerelease, non-dev version.
-func latestStable(versions []PackageVersion) (*PackageVersion, error) {
-	var stable []*PackageVersion
+// readInstalled reads the packages installed in a Composer project directory.
+// Composer 2 wraps the list in an object; Composer 1 writes a bare array.
+func readInstalled(dir string) ([]installedPackage, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "vendor", "composer", "installed.json"))
+	if err != nil {
+		return nil, err
+	}
 
-	for i := range versions {
-		v := &versions[i]
-		if isPrerelease(v.Version) || isDev(v.Version) {
-			continue
-		}
+	var v2 struct {
+		Packages []installedPackage `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &v2); err == nil {
+		return v2.Packages, nil
+	}
 
-		if _, err := semver.NewVersion(v.Version); err != nil {
-			continue
-		}
+	var v1 []installedPackage
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return nil, err
+	}
+	return v1, nil
+}
 
-		stable = append(stable, v)
+// readCachedMetadata returns cached metadata, its Last-Modified header and
+// when it was last fetched or revalidated. data is nil when nothing is cached.
+func readCachedMetadata(path string) (data []byte, lastModified string, fetchedAt time.Time) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", time.Time{}
 	}
 
-	if len(stable) == 0 {
-		return nil, fmt.Errorf("no stable version found")
+	if info, err := os.Stat(path); err == nil {
+		fetchedAt = info.ModTime()
+	}
+	if lm, err := os.ReadFile(path + ".last-modified"); err == nil {
+		lastModified = strings.TrimSpace(string(lm))
 	}
+	return data, lastModified, fetchedAt
+}
 
-	return highestVersion(stable)
+func writeCachedMetadata(path string, data []byte, lastModified string) error {
+	if err := cache.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".last-modified", []byte(lastModified), 0644)
+}
+
+// touchCachedMetadata marks cached metadata as freshly revalidated.
+func touchCachedMetadata(path string) error {
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// PlatformCheck returns an error explaining why no available PHP build can
+// satisfy a release's php constraint (empty when unconstrained) together with
+// the extensions it requires.
+type PlatformCheck func(phpConstraint string, extensions []string) error
+
+// SkippedVersion is a release newer than the resolved one that was passed
+// over because its platform requirements cannot be met.
+type SkippedVersion struct {
+	Version string
+	Reason  string
+}
+
+// PlatformRequirements returns the release's php constraint and the
+// extensions it requires through ext-* packages.
+func (v *PackageVersion) PlatformRequirements() (phpConstraint string, extensions []string) {
+	for name, constraint := range v.Require {
+		switch {
+		case name == "php":
+			phpConstraint = constraint
+		case strings.HasPrefix(name, "ext-"):
+			extensions = append(extensions, strings.ToLower(strings.TrimPrefix(name, "ext-")))
+		}
+	}
+	sort.Strings(extensions)
+	return phpConstraint, extensions
 }
 
-func highestVersion(versions []*PackageVersion) (*PackageVersion, error) {
-	if len(versions) == 0 {
-		return nil, fmt.Errorf("no versions provided")
+// ResolveVersion finds the highest version satisfying a Composer constraint
+// whose platform requirements pass check (which may be nil). If constraint is
+// empty, returns the latest stable version. Newer releases rejected by check
+// are returned, newest first, so callers can explain the choice.
+//
+// Versions less stable than the constraint allows (via @flags or an explicit
+// unstable version such as "2.0-beta") are skipped. Branches match by name or
+// through their branch alias, so "^2.0@dev" admits dev-main aliased to 2.x-dev.
+func ResolveVersion(pkg *PackageInfo, constraint string, check PlatformCheck) (*PackageVersion, []SkippedVersion, error) {
+	if constraint == "" {
+		constraint = "*"
+	}
+
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return nil, nil, err
 	}
+	minStability := version.Stabilities[version.StabilityFlag(constraint)]
 
-	highest := versions[0]
-	highestSV, _ := semver.NewVersion(highest.Version)
+	type rejected struct {
+		SkippedVersion
+		normalized string
+	}
+
+	var best *PackageVersion
+	var bestNormalized string
+	var unsupported []rejected
+	for i := range pkg.Versions {
+		v := &pkg.Versions[i]
 
-	for _, v := range versions[1:] {
-		sv, err := semver.NewVersion(v.Version)
+		normalized, err := normalizedVersion(v)
 		if err != nil {
 			continue
 		}
-		if sv.GreaterThan(highestSV) {
-			highest = v
-			highestSV = sv
+		if version.Stabilities[version.ParseStability(normalized)] > minStability {
+			continue
+		}
+
+		if !c.Matches(normalized) {
+			alias, ok := branchAlias(v)
+			if !ok || !c.Matches(alias) {
+				continue
+			}
+			normalized = alias
+		}
+
+		if check != nil {
+			if err := check(v.PlatformRequirements()); err != nil {
+				unsupported = append(unsupported, rejected{SkippedVersion{v.Version, err.Error()}, normalized})
+				continue
+			}
+		}
+
+		if best == nil || version.Compare(normalized, bestNormalized) > 0 {
+			best = v
+			bestNormalized = normalized
+		}
+	}
+
+	sort.Slice(unsupported, func(i, j int) bool {
+		return version.Compare(unsupported[i].normalized, unsupported[j].normalized) > 0
+	})
+
+	if best == nil {
+		switch {
+		case len(unsupported) > 0:
+			newest := unsupported[0]
+			return nil, nil, fmt.Errorf("no version satisfying %q can run on an available PHP build (%s: %s)", constraint, newest.Version, newest.Reason)
+		case constraint == "*":
+			return nil, nil, fmt.Errorf("no stable version found")
+		default:
+			return nil, nil, fmt.Errorf("no version satisfies constraint %q", constraint)
+		}
+	}
+
+	var skipped []SkippedVersion
+	for _, r := range unsupported {
+		if version.Compare(r.normalized, bestNormalized) > 0 {
+			skipped = append(skipped, r.SkippedVersion)
 		}
 	}
 
-	return highest, nil
+	return best, skipped, nil
 }
 
-func isPrerelease(version string) bool {
-	lower := strings.ToLower(version)
-	return strings.Contains(lower, "-alpha") ||
-		strings.Contains(lower, "-beta") ||
-		strings.Contains(lower, "-rc") ||
-		strings.Contains(lower, "-dev")
+// normalizedVersion returns the version's normalized form, preferring the
+// version_normalized field Packagist provides.
+func normalizedVersion(v *PackageVersion) (string, error) {
+	if v.VersionNormalized != "" {
+		return v.VersionNormalized, nil
+	}
+	return version.Normalize(v.Version)
 }
 
-func isDev(version string) bool {
-	return strings.HasPrefix(strings.ToLower(version), "dev-")
+// branchAlias returns the normalized alias of a branch version, if any.
+func branchAlias(v *PackageVersion) (string, bool) {
+	alias, ok := v.Extra.BranchAlias[v.Version]
+	if !ok {
+		return "", false
+	}
+
+	normalized, err := version.Normalize(alias)
+	if err != nil {
+		return "", false
+	}
+	return normalized, true
 }
@@ -1,7 +1,18 @@
 package composer
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
 )
 
 func TestResolveVersion(t *testing.T) {
@@ -62,7 +73,7 @@ func TestResolveVersion(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ResolveVersion(pkg, tt.constraint)
+			got, _, err := ResolveVersion(pkg, tt.constraint, nil)
 
 			if tt.wantErr {
 				if err == nil {
@@ -118,7 +129,178 @@ func TestResolveVersion_with_or_constraints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ResolveVersion(pkg, tt.constraint)
+			got, _, err := ResolveVersion(pkg, tt.constraint, nil)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got.Version != tt.want {
+				t.Errorf("got %s, want %s", got.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVersion_platform(t *testing.T) {
+	pkg := &PackageInfo{
+		Name: "test/package",
+		Versions: []PackageVersion{
+			{Version: "2.1.0", Require: map[string]string{"php": "^8.2"}},
+			{Version: "2.0.0", Require: map[string]string{"php": "^8.1", "ext-intl": "*"}},
+			{Version: "1.12.0", Require: map[string]string{"php": "^7.4|^8.0", "ext-json": "*"}},
+			{Version: "1.11.0", Require: map[string]string{"php": "^7.2|^8.0"}},
+		},
+	}
+
+	// Only PHP 7.4 builds without intl are available
+	check := func(phpConstraint string, extensions []string) error {
+		for _, ext := range extensions {
+			if ext == "intl" {
+				return fmt.Errorf("extension 'intl' not available")
+			}
+		}
+		if phpConstraint == "^8.2" || phpConstraint == "^8.1" {
+			return fmt.Errorf("no PHP version satisfies '%s'", phpConstraint)
+		}
+		return nil
+	}
+
+	t.Run("returns newest release the platform supports", func(t *testing.T) {
+		got, skipped, err := ResolveVersion(pkg, "", check)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Version != "1.12.0" {
+			t.Errorf("got %s, want 1.12.0", got.Version)
+		}
+		if len(skipped) != 2 || skipped[0].Version != "2.1.0" || skipped[1].Version != "2.0.0" {
+			t.Errorf("skipped = %+v, want 2.1.0 then 2.0.0", skipped)
+		}
+		if skipped[1].Reason != "extension 'intl' not available" {
+			t.Errorf("skipped[1].Reason = %q", skipped[1].Reason)
+		}
+	})
+
+	t.Run("returns error naming newest unsupported release", func(t *testing.T) {
+		_, _, err := ResolveVersion(pkg, "^2.0", check)
+
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if !strings.Contains(err.Error(), "2.1.0") {
+			t.Errorf("error %q does not mention 2.1.0", err)
+		}
+	})
+}
+
+func TestPlatformRequirements(t *testing.T) {
+	v := &PackageVersion{Require: map[string]string{
+		"php":             ">=8.1",
+		"ext-mbstring":    "*",
+		"ext-JSON":        "*",
+		"symfony/console": "^7.0",
+	}}
+
+	php, extensions := v.PlatformRequirements()
+
+	if php != ">=8.1" {
+		t.Errorf("php = %q, want >=8.1", php)
+	}
+	if strings.Join(extensions, ",") != "json,mbstring" {
+		t.Errorf("extensions = %v, want [json mbstring]", extensions)
+	}
+}
+
+func TestResolveVersion_composer_forms(t *testing.T) {
+	pkg := &PackageInfo{
+		Name: "test/package",
+		Versions: []PackageVersion{
+			{Version: "v2.1.0", VersionNormalized: "2.1.0.0"},
+			{Version: "v2.0.0", VersionNormalized: "2.0.0.0"},
+			{Version: "1.2.3.4"},
+			{Version: "1.2.3"},
+			{Version: "1.0.1-beta2", VersionNormalized: "1.0.1.0-beta2"},
+			{Version: "1.0.0"},
+			{Version: "3.0.0-RC1", VersionNormalized: "3.0.0.0-RC1"},
+			{
+				Version:           "dev-main",
+				VersionNormalized: "dev-main",
+				Extra:             PackageExtra{BranchAlias: map[string]string{"dev-main": "3.x-dev"}},
+			},
+			{Version: "dev-feature", VersionNormalized: "dev-feature"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "matches v-prefixed tags",
+			constraint: "^2.0",
+			want:       "v2.1.0",
+		},
+		{
+			name: "matches four-part versions",
+			constraint: "~1.2.3",
+			want:       "1.2.3.4",
+		},
+		{
+			name: "matches exact four-part version",
+			constraint: "1.2.3.0",
+			want:       "1.2.3",
+		},
+		{
+			name: "admits prereleases with stability flag",
+			constraint: "1.0.*@beta",
+			want:       "1.0.1-beta2",
+		},
+		{
+			name: "skips prereleases without stability flag",
+			constraint: "1.0.*",
+			want:       "1.0.0",
+		},
+		{
+			name: "infers stability from explicit prerelease version",
+			constraint: ">=3.0-RC1",
+			want:       "3.0.0-RC1",
+		},
+		{
+			name: "treats -stable as stable only",
+			constraint: ">=2.0-stable",
+			want:       "v2.1.0",
+		},
+		{
+			name: "matches dev branch by name",
+			constraint: "^5.0 || dev-feature",
+			want:       "dev-feature",
+		},
+		{
+			name: "matches dev branch through branch alias",
+			constraint: "^3.0@dev",
+			want:       "dev-main",
+		},
+		{
+			name: "returns error for invalid constraint",
+			constraint: "not-a-version",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := ResolveVersion(pkg, tt.constraint, nil)
 
 			if tt.wantErr {
 				if err == nil {
@@ -143,9 +325,10 @@ func TestInferBinary(t *testing.T) {
 		name     string
 		pkg      string
 		bins     []string
-		fromFlag string
-		want     string
-		wantErr  bool
+		fromFlag  string
+		requested string
+		want      string
+		wantErr   bool
 	}{
 		{
 			name: "returns single binary basename",
@@ -173,10 +356,17 @@ func TestInferBinary(t *testing.T) {
 			want: "phpstan.phar",
 		},
 		{
-			name: "returns first binary when no name match",
-			pkg:  "vendor/package",
-			bins: []string{"first", "second"},
-			want: "first",
+			name:      "matches binary to requested alias",
+			pkg:       "squizlabs/php_codesniffer",
+			bins:      []string{"bin/phpcbf", "bin/phpcs"},
+			requested: "phpcs",
+			want:      "phpcs",
+		},
+		{
+			name:    "reports ambiguity when no name match",
+			pkg:     "vendor/package",
+			bins:    []string{"first", "second"},
+			wantErr: true,
 		},
 		{
 			name: "returns error when no binaries declared",
@@ -188,7 +378,7 @@ func TestInferBinary(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := InferBinary(tt.pkg, tt.bins, tt.fromFlag)
+			got, err := InferBinary(tt.pkg, tt.bins, tt.fromFlag, tt.requested)
 
 			if tt.wantErr {
 				if err == nil {
@@ -229,6 +419,11 @@ func TestResolveAlias(t *testing.T) {
 			input: "vendor/package",
 			want:  "vendor/package",
 		},
+		{
+			name:  "expands aliased package short name",
+			input: "php_codesniffer",
+			want:  "squizlabs/php_codesniffer",
+		},
 		{
 			name: "preserves unknown short name",
 			input: "unknown",
@@ -247,6 +442,46 @@ func TestResolveAlias(t *testing.T) {
 	}
 }
 
+func TestInferBinary_lists_ambiguous_binaries(t *testing.T) {
+	_, err := InferBinary("squizlabs/php_codesniffer", []string{"bin/phpcs", "bin/phpcbf"}, "", "")
+
+	var amb *AmbiguousBinaryError
+	if !errors.As(err, &amb) {
+		t.Fatalf("got %v, want *AmbiguousBinaryError", err)
+	}
+	if want := []string{"phpcs", "phpcbf"}; !slices.Equal(amb.Binaries, want) {
+		t.Errorf("got binaries %v, want %v", amb.Binaries, want)
+	}
+}
+
+func TestSplitBinary(t *testing.T) {
+	tests := []struct {
+		arg     string
+		wantArg string
+		wantBin string
+	}{
+		{"php_codesniffer:phpcbf", "php_codesniffer", "phpcbf"},
+		{"squizlabs/php_codesniffer:phpcbf@3.7.2", "squizlabs/php_codesniffer@3.7.2", "phpcbf"},
+		{"phpcs:phpcbf:^3.7", "phpcs:^3.7", "phpcbf"},
+		{"phpstan:^1.10", "phpstan:^1.10", ""},
+		{"phpstan:1.10.*@beta", "phpstan:1.10.*@beta", ""},
+		{"phpstan:dev-main", "phpstan:dev-main", ""},
+		{"phpstan:v2", "phpstan:v2", ""},
+		{"phpstan:*", "phpstan:*", ""},
+		{"phpstan@1.10.0", "phpstan@1.10.0", ""},
+		{"git+https://github.com/acme/tool@main", "git+https://github.com/acme/tool@main", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			arg, bin := SplitBinary(tt.arg)
+			if arg != tt.wantArg || bin != tt.wantBin {
+				t.Errorf("got (%q, %q), want (%q, %q)", arg, bin, tt.wantArg, tt.wantBin)
+			}
+		})
+	}
+}
+
 func TestParseToolArg(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -282,7 +517,11 @@ func TestParseToolArg(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			pkg, version := ParseToolArg(tt.arg)
+			pkg, version, src := ParseToolArg(tt.arg)
+
+			if src != nil {
+				t.Fatalf("got source %+v, want package", src)
+			}
 
 			if pkg != tt.wantPkg {
 				t.Errorf("pkg = %q, want %q", pkg, tt.wantPkg)
@@ -295,56 +534,187 @@ func TestParseToolArg(t *testing.T) {
 	}
 }
 
-func TestNormalizeConstraint(t *testing.T) {
-	tests := []struct {
-		name  string
-		input string
-		want  string
-	}{
-		{
-			name: "converts single pipe to double pipe",
-			input: "^7.4|^8.0",
-			want:  "^7.4 || ^8.0",
-		},
-		{
-			name: "preserves existing double pipes",
-			input: "^7.4 || ^8.0",
-			want:  "^7.4  ||  ^8.0",
-		},
-		{
-			name: "returns constraint unchanged when no or",
-			input: "^8.0",
-			want:  "^8.0",
-		},
-		{
-			name: "returns range constraint unchanged",
-			input: ">=8.1",
-			want:  ">=8.1",
-		},
-		{
-			name: "handles complex or with and constraints",
-			input: ">=7.2,<8.0|>=8.0,<9.0",
-			want:  ">=7.2,<8.0 || >=8.0,<9.0",
-		},
-		{
-			name: "handles multiple or branches",
-			input: "^7.2|^7.3|^7.4|^8.0",
-			want:  "^7.2 || ^7.3 || ^7.4 || ^8.0",
-		},
-		{
-			name: "handles spaces around single pipe",
-			input: "^7.4 | ^8.0",
-			want:  "^7.4  ||  ^8.0",
-		},
+const minifiedMetadata = `{
+  "minified": "composer/2.0",
+  "packages": {
+    "acme/tool": [
+      {"name": "acme/tool", "version": "2.0.0", "require": {"php": "^8.1"}, "bin": ["bin/tool"]},
+      {"version": "1.0.0", "require": {"php": "^7.4"}},
+      {"version": "0.9.0", "bin": "__unset"}
+    ]
+  }
+}`
+
+// usePackagist points FetchPackage at a test server and an isolated cache.
+func usePackagist(t *testing.T, handler http.HandlerFunc) *int {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	original := RepoURL
+	RepoURL = server.URL + "/p2/"
+	t.Cleanup(func() { RepoURL = original })
+
+	return &requests
+}
+
+// expireMetadata backdates cached metadata so the next fetch revalidates it.
+func expireMetadata(t *testing.T, pkg string) {
+	t.Helper()
+	path, _ := cache.PackagistPath(pkg)
+	old := time.Now().Add(-2 * MetadataTTL)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := NormalizeConstraint(tt.input)
+func TestFetchPackage(t *testing.T) {
+	t.Run("expands minified versions", func(t *testing.T) {
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(minifiedMetadata))
+		})
 
-			if got != tt.want {
-				t.Errorf("got %q, want %q", got, tt.want)
+		info, err := FetchPackage("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(info.Versions) != 3 {
+			t.Fatalf("got %d versions, want 3", len(info.Versions))
+		}
+		if got := info.Versions[1]; got.Require["php"] != "^7.4" || len(got.Bin) != 1 {
+			t.Errorf("Versions[1] = %+v, want inherited bin and own php requirement", got)
+		}
+		if got := info.Versions[2]; len(got.Bin) != 0 {
+			t.Errorf("Versions[2].Bin = %v, want unset", got.Bin)
+		}
+	})
+
+	t.Run("returns error for unknown package", func(t *testing.T) {
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		_, err := FetchPackage("acme/missing")
+
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("uses fresh cached metadata without a request", func(t *testing.T) {
+		requests := usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(minifiedMetadata))
+		})
+
+		_, _ = FetchPackage("acme/tool")
+		_, err := FetchPackage("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *requests != 1 {
+			t.Errorf("got %d requests, want 1", *requests)
+		}
+	})
+
+	t.Run("revalidates stale metadata with if-modified-since", func(t *testing.T) {
+		var ifModifiedSince string
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			if ifModifiedSince = r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
 			}
+			w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+			_, _ = w.Write([]byte(minifiedMetadata))
 		})
-	}
+
+		_, _ = FetchPackage("acme/tool")
+		expireMetadata(t, "acme/tool")
+		info, err := FetchPackage("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ifModifiedSince != "Mon, 01 Jan 2024 00:00:00 GMT" {
+			t.Errorf("If-Modified-Since = %q, want cached Last-Modified", ifModifiedSince)
+		}
+		if len(info.Versions) != 3 {
+			t.Errorf("got %d versions, want 3 from cache", len(info.Versions))
+		}
+	})
+
+	t.Run("falls back to cached metadata when packagist fails", func(t *testing.T) {
+		fail := false
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			if fail {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(minifiedMetadata))
+		})
+
+		_, _ = FetchPackage("acme/tool")
+		expireMetadata(t, "acme/tool")
+		fail = true
+		info, err := FetchPackage("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(info.Versions) != 3 {
+			t.Errorf("got %d versions, want 3 from cache", len(info.Versions))
+		}
+	})
+
+	t.Run("falls back to installed tool versions when nothing is cached", func(t *testing.T) {
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		toolPath, _ := cache.ToolPath("acme/tool", "1.5.0")
+		installed := filepath.Join(toolPath, "vendor", "composer", "installed.json")
+		_ = os.MkdirAll(filepath.Dir(installed), 0755)
+		data := `{"packages": [{"name": "acme/tool", "version": "1.5.0", "bin": ["bin/tool"]}]}`
+		if err := os.WriteFile(installed, []byte(data), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+
+		info, err := FetchPackage("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(info.Versions) != 1 || info.Versions[0].Version != "1.5.0" {
+			t.Errorf("got %+v, want installed version 1.5.0", info.Versions)
+		}
+	})
+}
+
+func TestFetchDevVersions(t *testing.T) {
+	t.Run("fetches branches from the dev metadata file", func(t *testing.T) {
+		var path string
+		usePackagist(t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			_, _ = w.Write([]byte(`{"packages":{"acme/tool":[{"version":"dev-main","version_normalized":"dev-main","extra":{"branch-alias":{"dev-main":"2.x-dev"}}}]}}`))
+		})
+
+		versions, err := FetchDevVersions("acme/tool")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if path != "/p2/acme/tool~dev.json" {
+			t.Errorf("requested %q, want /p2/acme/tool~dev.json", path)
+		}
+		if len(versions) != 1 || versions[0].Extra.BranchAlias["dev-main"] != "2.x-dev" {
+			t.Errorf("versions = %+v, want dev-main aliased to 2.x-dev", versions)
+		}
+	})
 }
@@ -0,0 +1,375 @@
+package composer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// SourcePhar is a standalone PHAR downloaded from a URL.
+const SourcePhar = "phar"
+
+// Keyserver is where PHAR signing keys are fetched from.
+var Keyserver = "hkps://keys.openpgp.org"
+
+// PharRelease describes where a tool publishes its signed PHAR.
+type PharRelease struct {
+	Latest  string   // URL of the latest release
+	Release string   // URL of a given release, with %s for the release tag
+	Signers []string // Fingerprints of the primary keys its releases are signed with
+}
+
+// Phars maps tool names to their published PHARs. They are run from the
+// PHAR rather than installed with Composer.
+var Phars = map[string]PharRelease{
+	"box": {
+		Latest:  "https://github.com/box-project/box/releases/latest/download/box.phar",
+		Release: "https://github.com/box-project/box/releases/download/%s/box.phar",
+		Signers: []string{"41539BBD4020945DB378F98B2DF45277AEF09A2F"},
+	},
+	"infection": {
+		Latest:  "https://github.com/infection/infection/releases/latest/download/infection.phar",
+		Release: "https://github.com/infection/infection/releases/download/%s/infection.phar",
+		Signers: []string{"C6D76C329EBADE2FB9C458CFC5095986493B4AA0"},
+	},
+}
+
+// PharAlias returns the PHAR source for a tool in Phars, at the given
+// release tag or the latest release. It returns nil for other names.
+func PharAlias(name, tag string) *Source {
+	release, ok := Phars[name]
+	if !ok {
+		return nil
+	}
+	if tag == "" {
+		return &Source{Type: SourcePhar, URL: release.Latest}
+	}
+	return &Source{Type: SourcePhar, URL: fmt.Sprintf(release.Release, tag)}
+}
+
+// parsePharSource parses "phar:<url>[#sha256=<digest>]".
+func parsePharSource(arg string) *Source {
+	rest, ok := strings.CutPrefix(arg, "phar:")
+	if !ok {
+		return nil
+	}
+
+	src := &Source{Type: SourcePhar, URL: rest}
+	if url, digest, found := strings.Cut(rest, "#sha256="); found {
+		src.URL = url
+		src.Digest = strings.ToLower(digest)
+	}
+	return src
+}
+
+// PharName returns the file name a PHAR source is saved and run as.
+func PharName(src *Source) string {
+	name := "tool.phar"
+	if u, err := url.Parse(src.URL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	if !strings.HasSuffix(name, ".phar") {
+		name += ".phar"
+	}
+	return name
+}
+
+// releaseClient resolves latest-release URLs without following the redirect
+// to the download itself.
+var releaseClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ResolvePhar returns src with a GitHub latest-release URL replaced by the
+// tagged release it currently redirects to, so each release is cached and
+// verified separately rather than the first one downloaded being kept.
+//
+// The resolved release is reused for MetadataTTL, and when GitHub cannot be
+// reached the last one resolved is used.
+func ResolvePhar(src *Source) (*Source, error) {
+	u, err := url.Parse(src.URL)
+	if err != nil || src.Digest != "" || !strings.Contains(u.Path, "/releases/latest/download/") {
+		return src, nil
+	}
+
+	cachePath, err := cache.ReleasePath(src.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, fresh := cachedRelease(cachePath)
+	if fresh {
+		return releaseSource(src, cached), nil
+	}
+
+	release, err := resolveLatestRelease(src.URL)
+	if err != nil {
+		if cached != "" {
+			return releaseSource(src, cached), nil
+		}
+		return nil, err
+	}
+
+	// Caching is an optimisation, so a failed write is not fatal
+	if cache.EnsureDir(filepath.Dir(cachePath)) == nil {
+		_ = os.WriteFile(cachePath, []byte(release), 0644)
+	}
+	return releaseSource(src, release), nil
+}
+
+// resolveLatestRelease returns the tagged release URL a latest-release URL
+// redirects to.
+func resolveLatestRelease(latest string) (string, error) {
+	resp, err := releaseClient.Head(latest)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the latest release of %s: %w", latest, err)
+	}
+	_ = resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil || !strings.Contains(location.Path, "/releases/download/") {
+		return "", fmt.Errorf("failed to resolve the latest release of %s: HTTP %d", latest, resp.StatusCode)
+	}
+	return location.String(), nil
+}
+
+// cachedRelease returns the release URL recorded at path, and whether it was
+// resolved within MetadataTTL.
+func cachedRelease(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), time.Since(info.ModTime()) < MetadataTTL
+}
+
+// releaseSource returns a copy of src for a resolved release URL.
+func releaseSource(src *Source, release string) *Source {
+	resolved := *src
+	resolved.URL = release
+	return &resolved
+}
+
+// PharKey identifies a PHAR source in the tool cache.
+func PharKey(src *Source) string {
+	sum := sha256.Sum256([]byte(src.URL + "#" + src.Digest))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// InstallPhar downloads a PHAR into destDir and verifies it before it can be
+// run: against the pinned sha256 digest when given, otherwise against the
+// detached GPG signature published alongside it (<url>.asc).
+func InstallPhar(phpPath string, src *Source, destDir string, verbose bool) error {
+	if err := cache.EnsureDir(destDir); err != nil {
+		return err
+	}
+
+	name := PharName(src)
+	target := filepath.Join(destDir, name)
+
+	tmp, err := os.CreateTemp(destDir, ".download-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[phpx] Downloading %s\n", src.URL)
+	}
+
+	digest, err := download(src.URL, tmp)
+	_ = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", src.URL, err)
+	}
+
+	if src.Digest != "" {
+		if digest != src.Digest {
+			return fmt.Errorf("%s has sha256 %s, want %s", src.URL, digest, src.Digest)
+		}
+		if verbose {
+			fmt.Fprintln(os.Stderr, "[phpx] PHAR matches pinned sha256 digest")
+		}
+	} else {
+		fingerprint, err := verifyPharSignature(src, tmp.Name())
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "[phpx] PHAR signed by %s\n", fingerprint)
+		}
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+
+	// Record digests so 'phpx cache verify' can detect later tampering
+	return cache.WriteManifest(destDir, phpPath, name)
+}
+
+// verifyPharSignature checks a PHAR against its detached signature and
+// returns the signing key's fingerprint. Only the keys listed for the
+// release in Phars are accepted; other PHARs must be pinned by digest.
+func verifyPharSignature(src *Source, pharPath string) (string, error) {
+	signers := pharSigners(src.URL)
+	if len(signers) == 0 {
+		return "", fmt.Errorf("cannot verify %s: its signing keys are not known to phpx; pin its digest with phar:%s#sha256=<digest>", src.URL, src.URL)
+	}
+
+	sigPath := pharPath + ".asc"
+	f, err := os.Create(sigPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(sigPath) }()
+
+	_, err = download(src.URL+".asc", f)
+	_ = f.Close()
+	if err != nil {
+		return "", fmt.Errorf("cannot verify %s: no signature at %s.asc (%v); pin its digest with phar:%s#sha256=<digest>", src.URL, src.URL, err, src.URL)
+	}
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return "", fmt.Errorf("gpg is required to verify %s; install it or pin the digest with phar:%s#sha256=<digest>", src.URL, src.URL)
+	}
+
+	home, err := gpgHome()
+	if err != nil {
+		return "", err
+	}
+
+	// Keys are fetched by their known fingerprints, never by the ID the
+	// signature names
+	fingerprint, missingKey, err := gpgVerify(home, sigPath, pharPath)
+	if err == nil && missingKey != "" {
+		if err := gpgFetchKeys(home, signers...); err != nil {
+			return "", fmt.Errorf("failed to fetch signing keys for %s: %w", src.URL, err)
+		}
+		fingerprint, missingKey, err = gpgVerify(home, sigPath, pharPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	if missingKey != "" {
+		return "", fmt.Errorf("%s is signed by key %s, which is not a known signing key for it", src.URL, missingKey)
+	}
+	if fingerprint == "" {
+		return "", fmt.Errorf("signature of %s is not valid", src.URL)
+	}
+
+	if !knownSigner(signers, fingerprint) {
+		return "", fmt.Errorf("%s is signed by %s, which is not a known signing key for it", src.URL, fingerprint)
+	}
+	return fingerprint, nil
+}
+
+// pharSigners returns the signing key fingerprints listed in Phars for the
+// project a PHAR URL belongs to.
+func pharSigners(rawURL string) []string {
+	scope := releaseScope(rawURL)
+	for _, release := range Phars {
+		if releaseScope(release.Latest) == scope {
+			return release.Signers
+		}
+	}
+	return nil
+}
+
+// knownSigner reports whether fingerprint is one of signers.
+func knownSigner(signers []string, fingerprint string) bool {
+	for _, signer := range signers {
+		if strings.EqualFold(strings.ReplaceAll(signer, " ", ""), fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// gpgHome returns phpx's own GPG home, keeping fetched signing keys out of
+// the user's keyring.
+func gpgHome() (string, error) {
+	base, err := cache.Dir()
+	if err != nil {
+		return "", err
+	}
+	home := filepath.Join(base, "gpg")
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return "", err
+	}
+	return home, nil
+}
+
+// gpgVerify verifies a detached signature. It returns the fingerprint of a
+// valid signature, or the ID of the key needed when it is not in the keyring.
+func gpgVerify(home, sigPath, filePath string) (fingerprint, missingKey string, err error) {
+	cmd := exec.Command("gpg", "--homedir", home, "--batch", "--status-fd", "1", "--verify", sigPath, filePath)
+	out, _ := cmd.Output()
+
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" {
+			continue
+		}
+		switch fields[1] {
+		case "VALIDSIG":
+			// The primary key fingerprint comes last; the signing subkey first
+			fingerprint = fields[len(fields)-1]
+		case "NO_PUBKEY":
+			missingKey = fields[2]
+		case "BADSIG":
+			return "", "", fmt.Errorf("bad signature from key %s", fields[2])
+		case "EXPKEYSIG", "REVKEYSIG":
+			return "", "", fmt.Errorf("signature made by expired or revoked key %s", fields[2])
+		}
+	}
+	return fingerprint, missingKey, nil
+}
+
+// gpgFetchKeys imports keys from the keyserver by fingerprint.
+func gpgFetchKeys(home string, fingerprints ...string) error {
+	args := append([]string{"--homedir", home, "--batch", "--keyserver", Keyserver, "--recv-keys"}, fingerprints...)
+	cmd := exec.Command("gpg", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// releaseScope groups PHAR URLs published by the same project: the
+// repository for GitHub releases, otherwise the URL's directory.
+func releaseScope(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.Host == "github.com" {
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3)
+		if len(parts) >= 2 {
+			return u.Host + "/" + strings.ToLower(parts[0]+"/"+parts[1])
+		}
+	}
+	return u.Host + path.Dir(u.Path)
+}
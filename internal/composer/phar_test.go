@@ -0,0 +1,295 @@
+package composer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+func TestParseToolArg_phar(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want Source
+	}{
+		{
+			name: "parses PHAR URL",
+			arg:  "phar:https://example.com/tool.phar",
+			want: Source{Type: SourcePhar, URL: "https://example.com/tool.phar"},
+		},
+		{
+			name: "parses pinned sha256 digest",
+			arg:  "phar:https://example.com/tool.phar#sha256=ABC123",
+			want: Source{Type: SourcePhar, URL: "https://example.com/tool.phar", Digest: "abc123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, src := ParseToolArg(tt.arg)
+
+			if src == nil {
+				t.Fatal("got nil source")
+			}
+			if *src != tt.want {
+				t.Errorf("got %+v, want %+v", *src, tt.want)
+			}
+		})
+	}
+}
+
+func TestPharAlias(t *testing.T) {
+	t.Run("uses the latest release without a tag", func(t *testing.T) {
+		got := PharAlias("box", "")
+
+		if got == nil || got.URL != Phars["box"].Latest {
+			t.Errorf("got %+v, want latest box release", got)
+		}
+	})
+
+	t.Run("uses the release for a tag", func(t *testing.T) {
+		got := PharAlias("box", "4.6.1")
+
+		if got == nil || got.URL != "https://github.com/box-project/box/releases/download/4.6.1/box.phar" {
+			t.Errorf("got %+v, want box 4.6.1 release", got)
+		}
+	})
+
+	t.Run("returns nil for other names", func(t *testing.T) {
+		if got := PharAlias("phpstan", ""); got != nil {
+			t.Errorf("got %+v, want nil", got)
+		}
+	})
+}
+
+func TestPharName(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/download/box.phar", "box.phar"},
+		{"https://example.com/download/tool", "tool.phar"},
+		{"https://example.com/", "tool.phar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := PharName(&Source{Type: SourcePhar, URL: tt.url}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePhar(t *testing.T) {
+	tag := "1.2.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/acme/tool/releases/latest/download/tool.phar" {
+			http.Redirect(w, r, "/acme/tool/releases/download/"+tag+"/tool.phar", http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	latest := &Source{Type: SourcePhar, URL: server.URL + "/acme/tool/releases/latest/download/tool.phar"}
+	release := func(tag string) string {
+		return server.URL + "/acme/tool/releases/download/" + tag + "/tool.phar"
+	}
+
+	t.Run("resolves a latest-release URL to its tagged release", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		got, err := ResolvePhar(latest)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.URL != release("1.2.0") {
+			t.Errorf("got %s, want %s", got.URL, release("1.2.0"))
+		}
+	})
+
+	t.Run("reuses a recently resolved release", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		_, _ = ResolvePhar(latest)
+		tag = "1.3.0"
+		defer func() { tag = "1.2.0" }()
+
+		got, err := ResolvePhar(latest)
+
+		if err != nil || got.URL != release("1.2.0") {
+			t.Errorf("got %+v, %v, want cached 1.2.0 release", got, err)
+		}
+	})
+
+	t.Run("re-resolves once the cached release is stale", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		_, _ = ResolvePhar(latest)
+		path, _ := cache.ReleasePath(latest.URL)
+		old := time.Now().Add(-2 * MetadataTTL)
+		_ = os.Chtimes(path, old, old)
+		tag = "1.3.0"
+		defer func() { tag = "1.2.0" }()
+
+		got, err := ResolvePhar(latest)
+
+		if err != nil || got.URL != release("1.3.0") {
+			t.Errorf("got %+v, %v, want 1.3.0 release", got, err)
+		}
+	})
+
+	t.Run("falls back to the last resolved release when GitHub is unreachable", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		path, _ := cache.ReleasePath(server.URL + "/acme/gone/releases/latest/download/tool.phar")
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		_ = os.WriteFile(path, []byte(release("1.1.0")), 0644)
+		old := time.Now().Add(-2 * MetadataTTL)
+		_ = os.Chtimes(path, old, old)
+
+		got, err := ResolvePhar(&Source{Type: SourcePhar, URL: server.URL + "/acme/gone/releases/latest/download/tool.phar"})
+
+		if err != nil || got.URL != release("1.1.0") {
+			t.Errorf("got %+v, %v, want cached 1.1.0 release", got, err)
+		}
+	})
+
+	t.Run("leaves other URLs as they are", func(t *testing.T) {
+		src := &Source{Type: SourcePhar, URL: release("1.0.0")}
+
+		got, err := ResolvePhar(src)
+
+		if err != nil || got.URL != src.URL {
+			t.Errorf("got %+v, %v, want URL unchanged", got, err)
+		}
+	})
+
+	t.Run("fails when the latest release cannot be found", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		src := &Source{Type: SourcePhar, URL: server.URL + "/acme/missing/releases/latest/download/tool.phar"}
+
+		if _, err := ResolvePhar(src); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
+
+func TestReleaseScope(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/box-project/box/releases/latest/download/box.phar", "github.com/box-project/box"},
+		{"https://github.com/box-project/box/releases/download/4.6.1/box.phar", "github.com/box-project/box"},
+		{"https://example.com/downloads/tool.phar", "example.com/downloads"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := releaseScope(tt.url); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPharSigners(t *testing.T) {
+	t.Run("returns the signers of a known release", func(t *testing.T) {
+		got := pharSigners("https://github.com/box-project/box/releases/download/4.6.1/box.phar")
+
+		if len(got) == 0 || !knownSigner(got, Phars["box"].Signers[0]) {
+			t.Errorf("got %v, want box signers", got)
+		}
+	})
+
+	t.Run("returns none for other PHARs", func(t *testing.T) {
+		if got := pharSigners("https://example.com/downloads/tool.phar"); got != nil {
+			t.Errorf("got %v, want nil", got)
+		}
+	})
+}
+
+func TestKnownSigner(t *testing.T) {
+	signers := []string{"AAAA BBBB"}
+
+	if !knownSigner(signers, "aaaabbbb") {
+		t.Error("listed signer: got false, want true")
+	}
+	if knownSigner(signers, "CCCCDDDD") {
+		t.Error("other signer: got true, want false")
+	}
+}
+
+func TestInstallPhar(t *testing.T) {
+	phar := []byte("<?php echo 'tool';")
+	sum := sha256.Sum256(phar)
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tool.phar" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(phar)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		digest  string
+		wantErr string
+	}{
+		{
+			name:   "installs a PHAR matching its pinned digest",
+			digest: digest,
+		},
+		{
+			name:    "rejects a PHAR not matching its pinned digest",
+			digest:  strings.Repeat("0", 64),
+			wantErr: "want " + strings.Repeat("0", 64),
+		},
+		{
+			name:    "refuses an unpinned PHAR without known signers",
+			digest:  "",
+			wantErr: "signing keys are not known",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			dest := filepath.Join(t.TempDir(), "tool")
+			src := &Source{Type: SourcePhar, URL: server.URL + "/tool.phar", Digest: tt.digest}
+
+			err := InstallPhar("/usr/bin/php", src, dest, false)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(filepath.Join(dest, "tool.phar")); err == nil {
+					t.Error("unverified PHAR was installed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			info, err := os.Stat(filepath.Join(dest, "tool.phar"))
+			if err != nil {
+				t.Fatalf("PHAR not installed: %v", err)
+			}
+			if info.Mode().Perm()&0100 == 0 {
+				t.Errorf("mode = %v, want executable", info.Mode())
+			}
+		})
+	}
+}
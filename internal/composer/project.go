@@ -0,0 +1,99 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// Project is the Composer project in a directory. Tools run from it use its
+// PHP requirements and can load its classes through its autoloader.
+type Project struct {
+	Dir         string
+	PHP         string   // require.php constraint
+	PlatformPHP string   // config.platform.php, the PHP version dependencies were resolved for
+	Extensions  []string // Required ext-* names
+	VendorDir   string   // Absolute vendor directory
+	Autoload    string   // Path of vendor/autoload.php; empty until dependencies are installed
+}
+
+type projectJSON struct {
+	Require map[string]string `json:"require"`
+	Config  struct {
+		VendorDir string            `json:"vendor-dir"`
+		Platform  map[string]string `json:"platform"`
+	} `json:"config"`
+}
+
+// FindProject reads the Composer project in dir. It returns nil when dir
+// has no composer.json.
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var pj projectJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Join(dir, "composer.json"), err)
+	}
+
+	p := &Project{
+		Dir:         dir,
+		PHP:         pj.Require["php"],
+		PlatformPHP: pj.Config.Platform["php"],
+		VendorDir:   filepath.Join(dir, "vendor"),
+	}
+
+	for name := range pj.Require {
+		if ext, ok := strings.CutPrefix(name, "ext-"); ok {
+			p.Extensions = append(p.Extensions, strings.ToLower(ext))
+		}
+	}
+	sort.Strings(p.Extensions)
+
+	if pj.Config.VendorDir != "" {
+		p.VendorDir = pj.Config.VendorDir
+		if !filepath.IsAbs(p.VendorDir) {
+			p.VendorDir = filepath.Join(dir, p.VendorDir)
+		}
+	}
+
+	if autoload := filepath.Join(p.VendorDir, "autoload.php"); cache.Exists(autoload) {
+		p.Autoload = autoload
+	}
+
+	return p, nil
+}
+
+// PHPConstraints returns the constraints the PHP running the project's
+// tools must satisfy: require.php, and the minor release of
+// config.platform.php so the installed dependencies match.
+func (p *Project) PHPConstraints() []string {
+	var constraints []string
+	if p.PHP != "" {
+		constraints = append(constraints, p.PHP)
+	}
+	if p.PlatformPHP != "" {
+		parts := strings.SplitN(strings.TrimPrefix(p.PlatformPHP, "v"), ".", 3)
+		if len(parts) >= 2 {
+			constraints = append(constraints, "~"+parts[0]+"."+parts[1]+".0")
+		} else {
+			constraints = append(constraints, p.PlatformPHP)
+		}
+	}
+	return constraints
+}
@@ -0,0 +1,100 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeProject(t *testing.T, composerJSON string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "composer.json"), []byte(composerJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFindProject(t *testing.T) {
+	t.Run("returns nil without composer.json", func(t *testing.T) {
+		p, err := FindProject(t.TempDir())
+
+		if err != nil || p != nil {
+			t.Errorf("got (%+v, %v), want nil", p, err)
+		}
+	})
+
+	t.Run("reads platform requirements", func(t *testing.T) {
+		dir := writeProject(t, `{
+			"require": {"php": "^8.2", "ext-intl": "*", "ext-PDO": "*", "symfony/console": "^7.0"},
+			"config": {"platform": {"php": "8.2.10"}}
+		}`)
+
+		p, err := FindProject(dir)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.PHP != "^8.2" || p.PlatformPHP != "8.2.10" {
+			t.Errorf("PHP = %q, PlatformPHP = %q", p.PHP, p.PlatformPHP)
+		}
+		if want := []string{"intl", "pdo"}; !reflect.DeepEqual(p.Extensions, want) {
+			t.Errorf("Extensions = %v, want %v", p.Extensions, want)
+		}
+		if p.VendorDir != filepath.Join(dir, "vendor") || p.Autoload != "" {
+			t.Errorf("VendorDir = %q, Autoload = %q, want vendor without autoloader", p.VendorDir, p.Autoload)
+		}
+	})
+
+	t.Run("finds the autoloader in a custom vendor-dir", func(t *testing.T) {
+		dir := writeProject(t, `{"config": {"vendor-dir": "lib/vendor"}}`)
+		autoload := filepath.Join(dir, "lib", "vendor", "autoload.php")
+		if err := os.MkdirAll(filepath.Dir(autoload), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(autoload, []byte("<?php"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := FindProject(dir)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Autoload != autoload {
+			t.Errorf("Autoload = %q, want %q", p.Autoload, autoload)
+		}
+	})
+
+	t.Run("reports invalid composer.json", func(t *testing.T) {
+		if _, err := FindProject(writeProject(t, "{")); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
+
+func TestProject_PHPConstraints(t *testing.T) {
+	tests := []struct {
+		name    string
+		project Project
+		want    []string
+	}{
+		{name: "no requirements", project: Project{}, want: nil},
+		{name: "require.php", project: Project{PHP: "^8.1"}, want: []string{"^8.1"}},
+		{
+			name:    "platform pins the minor release",
+			project: Project{PHP: "^8.1", PlatformPHP: "8.2.10"},
+			want:    []string{"^8.1", "~8.2.0"},
+		},
+		{name: "short platform version", project: Project{PlatformPHP: "8.3"}, want: []string{"~8.3.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.PHPConstraints(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package composer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+	"github.com/eddmann/phpx/internal/version"
+)
+
+// Tool source types other than Packagist.
+const (
+	SourceVCS     = "vcs"     // Git repository, optionally at a ref
+	SourcePath    = "path"    // Local directory
+	SourceArchive = "archive" // Zip or tarball URL
+)
+
+// archiveSuffixes are the archive formats accepted as tool sources.
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2"}
+
+// Source is a tool location other than a Packagist package name.
+type Source struct {
+	Type   string // SourceVCS, SourcePath, SourceArchive or SourcePhar
+	URL    string // Repository URL, local directory, archive or PHAR URL
+	Ref    string // Branch, tag or commit for VCS sources
+	Digest string // Pinned sha256 of a PHAR source
+}
+
+// String returns the source as it would be written on the command line.
+func (s *Source) String() string {
+	if s.Type == SourcePhar {
+		if s.Digest != "" {
+			return "phar:" + s.URL + "#sha256=" + s.Digest
+		}
+		return "phar:" + s.URL
+	}
+	if s.Ref != "" {
+		return s.URL + "@" + s.Ref
+	}
+	return s.URL
+}
+
+// ResolvedSource is a Source pinned to the exact content being installed.
+type ResolvedSource struct {
+	Name       string         // Package name from the source's composer.json
+	Constraint string         // Root requirement that selects the source's package
+	Key        string         // Commit or content hash identifying the content
+	Repository Repository     // Composer repository serving the package
+	Package    PackageVersion // Requirements and binaries from composer.json
+}
+
+// parseSource recognizes VCS URLs, local paths and archive URLs. It returns
+// nil for anything else, which is treated as a Packagist package name.
+func parseSource(arg string) *Source {
+	if src := parsePharSource(arg); src != nil {
+		return src
+	}
+
+	lower := strings.ToLower(arg)
+
+	switch {
+	case strings.HasPrefix(arg, "git+"):
+		url, ref := splitRef(strings.TrimPrefix(arg, "git+"))
+		return &Source{Type: SourceVCS, URL: url, Ref: ref}
+
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		for _, suffix := range archiveSuffixes {
+			if strings.HasSuffix(lower, suffix) {
+				return &Source{Type: SourceArchive, URL: arg}
+			}
+		}
+		if url, ref := splitRef(arg); strings.HasSuffix(url, ".git") {
+			return &Source{Type: SourceVCS, URL: url, Ref: ref}
+		}
+
+	case strings.HasPrefix(arg, "git@"):
+		url, ref := splitRef(arg)
+		return &Source{Type: SourceVCS, URL: url, Ref: ref}
+
+	case arg == "." || arg == ".." || arg == "~" ||
+		strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") ||
+		strings.HasPrefix(arg, "~/") || filepath.IsAbs(arg):
+		return &Source{Type: SourcePath, URL: arg}
+	}
+
+	return nil
+}
+
+// splitRef splits a trailing "@ref" from a repository URL. The ref is only
+// looked for in the path, so user info such as "git@host" is left alone.
+func splitRef(url string) (string, string) {
+	start := 0
+	if i := strings.Index(url, "://"); i != -1 {
+		j := strings.Index(url[i+3:], "/")
+		if j == -1 {
+			return url, ""
+		}
+		start = i + 3 + j
+	} else if i := strings.Index(url, ":"); i != -1 {
+		// scp-like syntax: git@host:owner/repo.git
+		start = i
+	}
+
+	if i := strings.Index(url[start:], "@"); i != -1 {
+		return url[:start+i], url[start+i+1:]
+	}
+	return url, ""
+}
+
+// ResolveSource pins a source to its current content and reads the package
+// it provides. VCS sources are pinned to a commit, local paths to a hash of
+// their files and archives to a hash of the download. PHAR sources are not
+// Composer packages and are installed with InstallPhar instead.
+func ResolveSource(src *Source) (*ResolvedSource, error) {
+	switch src.Type {
+	case SourceVCS:
+		return resolveVCS(src)
+	case SourcePath:
+		return resolvePath(src)
+	case SourceArchive:
+		return resolveArchive(src)
+	}
+	return nil, fmt.Errorf("unknown source type %q", src.Type)
+}
+
+// resolveVCS fetches the requested ref to find its commit and composer.json,
+// then requires the matching Composer version pinned to that commit.
+func resolveVCS(src *Source) (*ResolvedSource, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is required to install tools from %s", src.URL)
+	}
+
+	tmp, err := os.MkdirTemp("", "phpx-vcs-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if _, err := git(tmp, "init", "-q"); err != nil {
+		return nil, err
+	}
+	if _, err := git(tmp, "fetch", "-q", "--depth", "1", src.URL, ref); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", src, err)
+	}
+
+	commit, err := git(tmp, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := git(tmp, "show", "FETCH_HEAD:composer.json")
+	if err != nil {
+		return nil, fmt.Errorf("%s has no composer.json", src)
+	}
+
+	name, pkg, err := parseComposerJSON(src.String(), []byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	constraint, err := vcsConstraint(tmp, src, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResolvedSource{
+		Name:       name,
+		Constraint: constraint,
+		Key:        commit,
+		Repository: Repository{Type: "vcs", URL: src.URL},
+		Package:    pkg,
+	}, nil
+}
+
+// vcsConstraint returns the requirement selecting commit in Composer's view
+// of a VCS repository: the tag version for a release tag, otherwise the
+// branch version (the ref's own branch, or the default branch for commits)
+// pinned to the commit.
+func vcsConstraint(dir string, src *Source, commit string) (string, error) {
+	args := []string{"ls-remote", "--symref", src.URL, "HEAD"}
+	if src.Ref != "" {
+		args = append(args, src.Ref)
+	}
+
+	out, err := git(dir, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to list refs of %s: %w", src.URL, err)
+	}
+
+	var defaultBranch, branch string
+	isTag := false
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD":
+			defaultBranch = strings.TrimPrefix(fields[1], "refs/heads/")
+		case len(fields) == 2 && src.Ref != "" && fields[1] == "refs/heads/"+src.Ref:
+			branch = src.Ref
+		case len(fields) == 2 && src.Ref != "" && fields[1] == "refs/tags/"+src.Ref:
+			isTag = true
+		}
+	}
+
+	if isTag {
+		if _, err := version.Normalize(src.Ref); err == nil {
+			return src.Ref, nil
+		}
+	}
+
+	if branch == "" {
+		branch = defaultBranch
+	}
+	if branch == "" {
+		return "", fmt.Errorf("cannot determine the branch of %s", src)
+	}
+
+	// Numeric branches such as "1.x" are exposed as "1.x-dev"
+	if version.IsBranch(version.NormalizeBranch(branch)) {
+		return "dev-" + branch + "#" + commit, nil
+	}
+	return branch + "-dev#" + commit, nil
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never prompt for credentials; fail instead
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolvePath reads the package in a local directory, keyed by a hash of its
+// files so edits lead to a fresh install.
+func resolvePath(src *Source) (*ResolvedSource, error) {
+	dir, err := expandHome(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", src.URL)
+	}
+
+	return resolveDir(src.URL, dir, "")
+}
+
+// resolveDir reads the package in dir and serves it through a path
+// repository. key is computed from the directory's files when empty.
+func resolveDir(label, dir, key string) (*ResolvedSource, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no composer.json", label)
+	}
+
+	name, pkg, err := parseComposerJSON(label, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		if key, err = hashDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ResolvedSource{
+		Name: name,
+		// Path repositories report a dev version unless composer.json sets one
+		Constraint: "*@dev",
+		Key:        key,
+		Repository: Repository{
+			Type: "path",
+			URL:  dir,
+			// Copy rather than symlink, so the install matches the hashed content
+			Options: map[string]any{"symlink": false},
+		},
+		Package: pkg,
+	}, nil
+}
+
+// hashDir computes a digest of a directory's files, skipping VCS metadata
+// and installed dependencies.
+func hashDir(dir string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if name == ".git" || name == ".hg" || name == ".svn" || (name == "vendor" && filepath.Dir(path) == dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		digest, err := cache.FileDigest(path)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), digest)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveArchive downloads an archive, keyed by its content hash, and
+// extracts it into the sources cache for installation through a path
+// repository. Composer's own artifact repository would also need the
+// archive's composer.json to declare a version, which most do not.
+func resolveArchive(src *Source) (*ResolvedSource, error) {
+	tmp, err := os.CreateTemp("", "phpx-archive-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	key, err := download(src.URL, tmp)
+	_ = tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", src.URL, err)
+	}
+
+	dest, err := cache.SourcePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cache.Exists(dest) {
+		staging := dest + ".tmp"
+		_ = os.RemoveAll(staging)
+		if err := extractArchive(tmp.Name(), src.URL, staging); err != nil {
+			_ = os.RemoveAll(staging)
+			return nil, fmt.Errorf("failed to extract %s: %w", src.URL, err)
+		}
+		if err := os.Rename(staging, dest); err != nil {
+			_ = os.RemoveAll(staging)
+			return nil, err
+		}
+	}
+
+	root, err := packageRoot(dest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src.URL, err)
+	}
+
+	return resolveDir(src.URL, root, key)
+}
+
+// downloadClient bounds archive and PHAR downloads, so a stalled server
+// fails the install rather than hanging it. The limit allows for large PHARs.
+var downloadClient = &http.Client{Timeout: 5 * time.Minute}
+
+// download writes url to w and returns the SHA-256 of the content.
+func download(url string, w io.Writer) (string, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packageRoot returns the directory holding composer.json in an extracted
+// archive: the top level, or the single directory archives such as GitHub's
+// wrap their contents in.
+func packageRoot(dir string) (string, error) {
+	if cache.Exists(filepath.Join(dir, "composer.json")) {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root := filepath.Join(dir, entries[0].Name())
+		if cache.Exists(filepath.Join(root, "composer.json")) {
+			return root, nil
+		}
+	}
+
+	return "", fmt.Errorf("no composer.json found in archive")
+}
+
+// parseComposerJSON reads the package name, requirements and binaries from a
+// source's composer.json.
+func parseComposerJSON(label string, data []byte) (string, PackageVersion, error) {
+	var cj struct {
+		Name string `json:"name"`
+		PackageVersion
+	}
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return "", PackageVersion{}, fmt.Errorf("invalid composer.json in %s: %w", label, err)
+	}
+	if cj.Name == "" {
+		return "", PackageVersion{}, fmt.Errorf("composer.json in %s has no package name", label)
+	}
+	return cj.Name, cj.PackageVersion, nil
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,343 @@
+package composer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sourceComposerJSON = `{"name": "acme/tool", "bin": ["bin/tool"], "require": {"php": ">=8.1"}}`
+
+func TestParseToolArg_sources(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want Source
+	}{
+		{
+			name: "parses git+https URL with branch",
+			arg:  "git+https://github.com/acme/tool@my-branch",
+			want: Source{Type: SourceVCS, URL: "https://github.com/acme/tool", Ref: "my-branch"},
+		},
+		{
+			name: "parses git+https URL with slashed branch",
+			arg:  "git+https://github.com/acme/tool.git@feature/fix",
+			want: Source{Type: SourceVCS, URL: "https://github.com/acme/tool.git", Ref: "feature/fix"},
+		},
+		{
+			name: "parses git+https URL without ref",
+			arg:  "git+https://github.com/acme/tool",
+			want: Source{Type: SourceVCS, URL: "https://github.com/acme/tool"},
+		},
+		{
+			name: "keeps user info in git+ssh URL",
+			arg:  "git+ssh://git@github.com/acme/tool.git@v1.2.0",
+			want: Source{Type: SourceVCS, URL: "ssh://git@github.com/acme/tool.git", Ref: "v1.2.0"},
+		},
+		{
+			name: "parses scp-like URL with ref",
+			arg:  "git@github.com:acme/tool.git@main",
+			want: Source{Type: SourceVCS, URL: "git@github.com:acme/tool.git", Ref: "main"},
+		},
+		{
+			name: "parses https URL ending in .git",
+			arg:  "https://github.com/acme/tool.git@main",
+			want: Source{Type: SourceVCS, URL: "https://github.com/acme/tool.git", Ref: "main"},
+		},
+		{
+			name: "parses relative path",
+			arg:  "./path/to/checkout",
+			want: Source{Type: SourcePath, URL: "./path/to/checkout"},
+		},
+		{
+			name: "parses absolute path",
+			arg:  "/src/tool",
+			want: Source{Type: SourcePath, URL: "/src/tool"},
+		},
+		{
+			name: "parses home-relative path",
+			arg:  "~/src/tool",
+			want: Source{Type: SourcePath, URL: "~/src/tool"},
+		},
+		{
+			name: "parses zip URL",
+			arg:  "https://example.com/tool-1.0.zip",
+			want: Source{Type: SourceArchive, URL: "https://example.com/tool-1.0.zip"},
+		},
+		{
+			name: "parses tarball URL",
+			arg:  "https://example.com/tool-1.0.tar.gz",
+			want: Source{Type: SourceArchive, URL: "https://example.com/tool-1.0.tar.gz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, version, src := ParseToolArg(tt.arg)
+
+			if src == nil {
+				t.Fatalf("got package %q (%q), want source", pkg, version)
+			}
+			if *src != tt.want {
+				t.Errorf("got %+v, want %+v", *src, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSource_path(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "composer.json"), sourceComposerJSON)
+	writeFile(t, filepath.Join(dir, "bin", "tool"), "<?php echo 1;")
+
+	t.Run("reads the package and serves it through a path repository", func(t *testing.T) {
+		got, err := ResolveSource(&Source{Type: SourcePath, URL: dir})
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Name != "acme/tool" {
+			t.Errorf("Name = %q, want acme/tool", got.Name)
+		}
+		if got.Repository.Type != "path" || got.Repository.URL != dir {
+			t.Errorf("Repository = %+v, want path repository for %s", got.Repository, dir)
+		}
+		if len(got.Package.Bin) != 1 || got.Package.Bin[0] != "bin/tool" {
+			t.Errorf("Bin = %v, want [bin/tool]", got.Package.Bin)
+		}
+	})
+
+	t.Run("changes key when files change", func(t *testing.T) {
+		before, _ := ResolveSource(&Source{Type: SourcePath, URL: dir})
+		writeFile(t, filepath.Join(dir, "bin", "tool"), "<?php echo 2;")
+		after, _ := ResolveSource(&Source{Type: SourcePath, URL: dir})
+
+		if before.Key == after.Key {
+			t.Error("got same key after edit, want different")
+		}
+	})
+
+	t.Run("ignores the vendor directory", func(t *testing.T) {
+		before, _ := ResolveSource(&Source{Type: SourcePath, URL: dir})
+		writeFile(t, filepath.Join(dir, "vendor", "autoload.php"), "<?php")
+		after, _ := ResolveSource(&Source{Type: SourcePath, URL: dir})
+
+		if before.Key != after.Key {
+			t.Error("got different key after vendor change, want same")
+		}
+	})
+
+	t.Run("rejects a directory without composer.json", func(t *testing.T) {
+		_, err := ResolveSource(&Source{Type: SourcePath, URL: t.TempDir()})
+
+		if err == nil || !strings.Contains(err.Error(), "no composer.json") {
+			t.Errorf("got %v, want missing composer.json error", err)
+		}
+	})
+}
+
+func TestResolveSource_vcs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "composer.json"), sourceComposerJSON)
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+	runGit(t, repo, "tag", "v1.0.0")
+	runGit(t, repo, "checkout", "-q", "-b", "1.x")
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "fix")
+	runGit(t, repo, "checkout", "-q", "-b", "feature/fix")
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "feature")
+	runGit(t, repo, "checkout", "-q", "main")
+
+	url := "file://" + repo
+
+	tests := []struct {
+		name           string
+		ref            string
+		wantConstraint string
+	}{
+		{
+			name:           "pins the default branch when no ref is given",
+			ref:            "",
+			wantConstraint: "dev-main#" + revParse(t, repo, "main"),
+		},
+		{
+			name:           "pins a named branch",
+			ref:            "feature/fix",
+			wantConstraint: "dev-feature/fix#" + revParse(t, repo, "feature/fix"),
+		},
+		{
+			name:           "uses Composer's name for numeric branches",
+			ref:            "1.x",
+			wantConstraint: "1.x-dev#" + revParse(t, repo, "1.x"),
+		},
+		{
+			name:           "requires the version of a release tag",
+			ref:            "v1.0.0",
+			wantConstraint: "v1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSource(&Source{Type: SourceVCS, URL: url, Ref: tt.ref})
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != "acme/tool" {
+				t.Errorf("Name = %q, want acme/tool", got.Name)
+			}
+			if got.Constraint != tt.wantConstraint {
+				t.Errorf("Constraint = %q, want %q", got.Constraint, tt.wantConstraint)
+			}
+			if got.Repository.Type != "vcs" || got.Repository.URL != url {
+				t.Errorf("Repository = %+v, want vcs repository for %s", got.Repository, url)
+			}
+		})
+	}
+}
+
+func TestResolveSource_archive(t *testing.T) {
+	archives := map[string][]byte{
+		"/tool.zip":    zipArchive(t, map[string]string{"tool-main/composer.json": sourceComposerJSON}),
+		"/tool.tar.gz": tarGzArchive(t, map[string]string{"composer.json": sourceComposerJSON}),
+		"/escape.zip":  zipArchive(t, map[string]string{"../composer.json": sourceComposerJSON}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{
+			name: "extracts a zip wrapped in a top-level directory",
+			path: "/tool.zip",
+		},
+		{
+			name: "extracts a gzipped tarball",
+			path: "/tool.tar.gz",
+		},
+		{
+			name:    "rejects entries outside the extraction directory",
+			path:    "/escape.zip",
+			wantErr: "escapes",
+		},
+		{
+			name:    "reports failed downloads",
+			path:    "/missing.zip",
+			wantErr: "HTTP 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+
+			got, err := ResolveSource(&Source{Type: SourceArchive, URL: server.URL + tt.path})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != "acme/tool" {
+				t.Errorf("Name = %q, want acme/tool", got.Name)
+			}
+			if _, err := os.Stat(filepath.Join(got.Repository.URL, "composer.json")); err != nil {
+				t.Errorf("repository %s has no composer.json: %v", got.Repository.URL, err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func revParse(t *testing.T, dir, ref string) string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", dir, "rev-parse", ref).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
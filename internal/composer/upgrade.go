@@ -0,0 +1,216 @@
+package composer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// RefreshedAtFile records when a dependency directory was last resolved.
+const RefreshedAtFile = ".phpx-refreshed-at"
+
+// exactVersionRegex matches constraints that pin a single version.
+var exactVersionRegex = regexp.MustCompile(`^(==?)?v?\d+(\.\d+){0,3}$`)
+
+// VersionChange describes a package whose locked version changed.
+type VersionChange struct {
+	Name string
+	From string // Empty when the package was added
+	To   string // Empty when the package was removed
+}
+
+// String formats the change for display, e.g. "symfony/console 7.0.1 -> 7.0.4".
+func (c VersionChange) String() string {
+	switch {
+	case c.From == "":
+		return fmt.Sprintf("%s (new) %s", c.Name, c.To)
+	case c.To == "":
+		return fmt.Sprintf("%s %s (removed)", c.Name, c.From)
+	default:
+		return fmt.Sprintf("%s %s -> %s", c.Name, c.From, c.To)
+	}
+}
+
+// HasFloatingConstraint reports whether any package constraint admits more
+// than one version, meaning a later resolution could pick a newer release.
+func HasFloatingConstraint(packages []string) bool {
+	for _, pkg := range packages {
+		_, constraint := parsePackage(pkg)
+		if !exactVersionRegex.MatchString(strings.TrimSpace(constraint)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LockedVersions reads package versions from a directory's composer.lock.
+func LockedVersions(dir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "composer.lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Packages []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid composer.lock: %w", err)
+	}
+
+	versions := make(map[string]string, len(lock.Packages))
+	for _, p := range lock.Packages {
+		versions[p.Name] = p.Version
+	}
+	return versions, nil
+}
+
+// DiffLocked returns the packages whose version differs between two locks,
+// sorted by package name.
+func DiffLocked(before, after map[string]string) []VersionChange {
+	var changes []VersionChange
+	for name, from := range before {
+		if to := after[name]; to != from {
+			changes = append(changes, VersionChange{Name: name, From: from, To: to})
+		}
+	}
+	for name, to := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, VersionChange{Name: name, To: to})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// RefreshedAt returns when a dependency directory was last resolved.
+func RefreshedAt(dir string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RefreshedAtFile))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+}
+
+// MarkRefreshed records the current time as the last resolution of dir.
+func MarkRefreshed(dir string) error {
+	return os.WriteFile(filepath.Join(dir, RefreshedAtFile), []byte(time.Now().Format(time.RFC3339)), 0644)
+}
+
+// upgradeLockTTL is how long an upgrade lock is honoured before it is
+// treated as left behind by a process that died.
+const upgradeLockTTL = 10 * time.Minute
+
+// LockUpgrade takes the lock held while destDir is upgraded, reporting false
+// when another upgrade holds it.
+func LockUpgrade(destDir string) bool {
+	lockPath := destDir + ".upgrading"
+	if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) >= upgradeLockTTL {
+		_ = os.Remove(lockPath)
+	}
+
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
+
+// UnlockUpgrade releases the lock taken by LockUpgrade.
+func UnlockUpgrade(destDir string) {
+	_ = os.Remove(destDir + ".upgrading")
+}
+
+// WaitForUpgrade waits while destDir is being upgraded, so a run that finds
+// no vendor tree in the moment it is swapped does not install over it.
+func WaitForUpgrade(destDir string) {
+	lockPath := destDir + ".upgrading"
+	for {
+		info, err := os.Stat(lockPath)
+		if err != nil || time.Since(info.ModTime()) >= upgradeLockTTL {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Upgrade re-resolves an installed dependency directory against its
+// composer.json and returns the packages whose versions changed. The caller
+// holds the lock from LockUpgrade.
+//
+// The update runs in a staging directory whose vendor tree replaces
+// destDir's only once it has succeeded. The old tree is removed straight
+// away, so a script already running from it may go on to autoload classes
+// from the new one.
+func Upgrade(phpPath, composerPath, destDir string, verbose bool) ([]VersionChange, error) {
+	before, err := LockedVersions(destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Staged inside destDir so no other entries appear in the deps cache
+	staging := filepath.Join(destDir, ".phpx-upgrade")
+	_ = os.RemoveAll(staging)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	for _, name := range []string{"composer.json", "composer.lock"} {
+		data, err := os.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(staging, name), data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := runComposer(phpPath, composerPath, staging, "update", verbose); err != nil {
+		return nil, fmt.Errorf("failed to upgrade dependencies: %w", err)
+	}
+
+	after, err := LockedVersions(staging)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := DiffLocked(before, after)
+	if len(changes) == 0 {
+		return nil, MarkRefreshed(destDir)
+	}
+
+	// Swap the upgraded vendor tree into place, then the files describing it
+	vendor := filepath.Join(destDir, "vendor")
+	old := filepath.Join(destDir, ".phpx-replaced")
+	_ = os.RemoveAll(old)
+	if err := os.Rename(vendor, old); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(staging, "vendor"), vendor); err != nil {
+		_ = os.Rename(old, vendor)
+		return nil, err
+	}
+	_ = os.RemoveAll(old)
+
+	for _, name := range []string{"composer.lock", cache.ManifestFile} {
+		if err := os.Rename(filepath.Join(staging, name), filepath.Join(destDir, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, MarkRefreshed(destDir)
+}
@@ -0,0 +1,110 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHasFloatingConstraint(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []string
+		want     bool
+	}{
+		{
+			name:     "treats exact versions as pinned",
+			packages: []string{"vendor/a:1.2.3", "vendor/b:v2.0", "vendor/c:==1.0.0"},
+			want:     false,
+		},
+		{
+			name:     "treats caret constraint as floating",
+			packages: []string{"vendor/a:1.2.3", "vendor/b:^7.0"},
+			want:     true,
+		},
+		{
+			name:     "treats wildcard as floating",
+			packages: []string{"symfony/console:*"},
+			want:     true,
+		},
+		{
+			name:     "treats missing constraint as floating",
+			packages: []string{"nesbot/carbon"},
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HasFloatingConstraint(tt.packages)
+
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLocked(t *testing.T) {
+	t.Run("reports upgraded, added and removed packages by name", func(t *testing.T) {
+		before := map[string]string{"a/a": "1.0.0", "b/b": "2.0.0", "c/c": "3.0.0"}
+		after := map[string]string{"a/a": "1.0.1", "b/b": "2.0.0", "d/d": "4.0.0"}
+
+		changes := DiffLocked(before, after)
+
+		want := []string{"a/a 1.0.0 -> 1.0.1", "c/c 3.0.0 (removed)", "d/d (new) 4.0.0"}
+		if len(changes) != len(want) {
+			t.Fatalf("got %d changes, want %d", len(changes), len(want))
+		}
+		for i, c := range changes {
+			if c.String() != want[i] {
+				t.Errorf("changes[%d] = %q, want %q", i, c.String(), want[i])
+			}
+		}
+	})
+}
+
+func TestLockedVersions(t *testing.T) {
+	t.Run("reads package versions from composer.lock", func(t *testing.T) {
+		dir := t.TempDir()
+		lock := `{"packages": [{"name": "symfony/console", "version": "v7.0.4"}]}`
+		if err := os.WriteFile(filepath.Join(dir, "composer.lock"), []byte(lock), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+
+		versions, err := LockedVersions(dir)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if versions["symfony/console"] != "v7.0.4" {
+			t.Errorf("got %q, want v7.0.4", versions["symfony/console"])
+		}
+	})
+}
+
+func TestLockUpgrade(t *testing.T) {
+	destDir := filepath.Join(t.TempDir(), "deps")
+
+	if !LockUpgrade(destDir) {
+		t.Fatal("first lock: got false, want true")
+	}
+	if LockUpgrade(destDir) {
+		t.Error("held lock: got true, want false")
+	}
+
+	UnlockUpgrade(destDir)
+	if !LockUpgrade(destDir) {
+		t.Error("released lock: got false, want true")
+	}
+
+	// A lock left behind by a process that died is taken over
+	old := time.Now().Add(-2 * upgradeLockTTL)
+	if err := os.Chtimes(destDir+".upgrading", old, old); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+	if !LockUpgrade(destDir) {
+		t.Error("stale lock: got false, want true")
+	}
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/proxy"
+	"github.com/eddmann/phpx/internal/sandbox"
+)
+
+// RunComposer runs a Composer install inside the sandbox, so that allowed
+// plugins and package scripts cannot touch anything beyond the install
+// directory and the shared Composer cache. Network access goes through the
+// proxy with no host restrictions, as packages may come from any mirror.
+func RunComposer(ctx context.Context, sb sandbox.Sandbox, cmd *composer.Command) error {
+	var proxyMgr *proxy.Manager
+	var proxyEnv []string
+	var proxySocketPath string
+	var proxyPort int
+	var proxySOCKS5Port int
+
+	if sb.IsSandboxed() {
+		var err error
+		proxyMgr, err = proxy.NewManager(proxy.ManagerConfig{
+			Verbose: cmd.Verbose,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start proxy: %w", err)
+		}
+		defer proxyMgr.Stop()
+
+		proxyEnv = proxyMgr.EnvVars()
+		proxySocketPath = proxyMgr.SocketPath()
+		proxyPort = proxyMgr.Port()
+		proxySOCKS5Port = proxyMgr.SOCKS5Port()
+	}
+
+	sandboxCfg := &sandbox.Config{
+		Network:         true,
+		ProxySocketPath: proxySocketPath,
+		ProxyPort:       proxyPort,
+		ProxySOCKS5Port: proxySOCKS5Port,
+		ReadablePaths:   append([]string{cmd.Composer}, cmd.ReadPaths...),
+		WritablePaths:   cmd.WritePaths,
+		PHPBinary:       cmd.PHPBinary,
+		ScriptPath:      cmd.Composer,
+		ScriptArgs:      cmd.Args,
+		WorkDir:         cmd.Dir,
+		Env:             append(proxyEnv, cmd.Env...),
+		Verbose:         cmd.Verbose,
+	}
+
+	// Composer output is only shown in verbose mode, matching host installs
+	if cmd.Verbose {
+		sandboxCfg.Stdout = os.Stdout
+		sandboxCfg.Stderr = os.Stderr
+		fmt.Fprintf(os.Stderr, "[phpx] Running Composer in sandbox: %s\n", sb.Name())
+	}
+
+	result, err := sb.Execute(ctx, sandboxCfg)
+	if err != nil {
+		return fmt.Errorf("composer failed: %w", err)
+	}
+	if result.ExitCode != 0 {
+		if result.Stderr != "" {
+			return fmt.Errorf("composer exited with code %d: %s", result.ExitCode, result.Stderr)
+		}
+		return fmt.Errorf("composer exited with code %d", result.ExitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// EntrypointKind describes how a tool binary is run.
+type EntrypointKind string
+
+const (
+	EntrypointPHP    EntrypointKind = "PHP script"     // Run with the managed PHP
+	EntrypointPhar   EntrypointKind = "PHAR"           // Run with the managed PHP, whatever its extension
+	EntrypointScript EntrypointKind = "shebang script" // Run with the interpreter on its #! line
+)
+
+// Entrypoint is a tool binary and how to run it.
+type Entrypoint struct {
+	Kind        EntrypointKind
+	Path        string   // File to run
+	Interpreter []string // Interpreter and arguments for EntrypointScript
+	Proxy       string   // Composer bin proxy that Path was resolved from, if any
+}
+
+// headerSize is how much of a binary is read to detect its kind. Composer
+// bin proxies and PHAR stubs fit well within it.
+const headerSize = 8192
+
+var (
+	phpInterpreter = regexp.MustCompile(`^php(-cli)?[0-9.]*$`)
+
+	// Composer's shell proxy for non-PHP binaries changes into the package's
+	// bin directory and execs the target from there.
+	proxyDir    = regexp.MustCompile(`cd '([^']+)' && pwd\)`)
+	proxyTarget = regexp.MustCompile(`exec "\$\{?dir\}?/([^"]+)" "\$@"`)
+)
+
+// DetectEntrypoint works out how to run a tool binary. PHP sources and PHARs
+// run with the managed PHP; scripts with a non-PHP shebang run with their
+// interpreter. Composer shell proxies are followed to the binary they exec,
+// so a PHP target still runs with the managed PHP.
+func DetectEntrypoint(path string) (*Entrypoint, error) {
+	header, err := readHeader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if target, ok := proxiedBinary(path, header); ok {
+		ep, err := DetectEntrypoint(target)
+		if err != nil {
+			return nil, fmt.Errorf("composer proxy %s: %w", path, err)
+		}
+		ep.Proxy = path
+		return ep, nil
+	}
+
+	kind := EntrypointPHP
+	if bytes.Contains(header, []byte("__HALT_COMPILER")) || strings.EqualFold(filepath.Ext(path), ".phar") {
+		kind = EntrypointPhar
+	}
+
+	line, ok := shebang(header)
+	if !ok {
+		return &Entrypoint{Kind: kind, Path: path}, nil
+	}
+
+	interpreter, err := shebangInterpreter(line)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if interpreter == nil {
+		return &Entrypoint{Kind: kind, Path: path}, nil
+	}
+
+	return &Entrypoint{Kind: EntrypointScript, Path: path, Interpreter: interpreter}, nil
+}
+
+// readHeader returns the start of a file.
+func readHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	buf := make([]byte, headerSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// shebang returns the #! line of a file, without the #!.
+func shebang(header []byte) (string, bool) {
+	if !bytes.HasPrefix(header, []byte("#!")) {
+		return "", false
+	}
+	line, _, _ := bytes.Cut(header[2:], []byte("\n"))
+	return strings.TrimSpace(string(line)), true
+}
+
+// shebangInterpreter resolves a #! line to the interpreter command, with an
+// absolute path so sandboxes can mount it. It returns nil for PHP, which is
+// always the managed PHP rather than the one the line names.
+func shebangInterpreter(line string) ([]string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	program, args := fields[0], fields[1:]
+	lookup := !filepath.IsAbs(program)
+
+	if filepath.Base(program) == "env" {
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:] // e.g. env -S
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no interpreter in #!%s", line)
+		}
+		program, args = args[0], args[1:]
+		lookup = true
+	}
+
+	if phpInterpreter.MatchString(filepath.Base(program)) {
+		return nil, nil
+	}
+
+	if lookup {
+		resolved, err := exec.LookPath(program)
+		if err != nil {
+			return nil, fmt.Errorf("interpreter %s not found", program)
+		}
+		program = resolved
+	}
+
+	return append([]string{program}, args...), nil
+}
+
+// proxiedBinary returns the binary a Composer shell proxy execs.
+func proxiedBinary(path string, header []byte) (string, bool) {
+	dir := proxyDir.FindSubmatch(header)
+	target := proxyTarget.FindSubmatch(header)
+	if dir == nil || target == nil {
+		return "", false
+	}
+	return filepath.Join(filepath.Dir(path), string(dir[1]), string(target[1])), true
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// composerProxy is the shell proxy Composer writes for non-PHP binaries.
+const composerProxy = `#!/usr/bin/env sh
+
+selfArg="$BASH_SOURCE"
+if [ -z "$selfArg" ]; then
+    selfArg="$0"
+fi
+
+self=$(realpath "$selfArg" 2> /dev/null)
+if [ -z "$self" ]; then
+    self="$selfArg"
+fi
+
+dir=$(cd "${self%[/\\]*}" > /dev/null; cd '../acme/tool/bin' && pwd)
+
+export COMPOSER_RUNTIME_BIN_DIR="$(cd "${self%[/\\]*}" > /dev/null; pwd)"
+
+exec "${dir}/%s" "$@"
+`
+
+func TestDetectEntrypoint(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantKind    EntrypointKind
+		interpreter []string
+	}{
+		{"PHP source", "tool", "<?php\necho 1;\n", EntrypointPHP, nil},
+		{"PHP shebang", "tool", "#!/usr/bin/env php\n<?php\n", EntrypointPHP, nil},
+		{"versioned PHP shebang", "tool", "#!/usr/bin/php8.3\n<?php\n", EntrypointPHP, nil},
+		{"PHAR without extension", "tool", "#!/usr/bin/env php\n<?php Phar::mapPhar(); __HALT_COMPILER(); ?>\x00", EntrypointPhar, nil},
+		{"PHAR by extension", "tool.phar", "\x00\x01", EntrypointPhar, nil},
+		{"absolute interpreter", "tool", "#!/bin/bash -e\necho 1\n", EntrypointScript, []string{"/bin/bash", "-e"}},
+		{"interpreter from PATH", "tool", "#!/usr/bin/env sh\necho 1\n", EntrypointScript, []string{sh}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			ep, err := DetectEntrypoint(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ep.Kind != tt.wantKind || ep.Path != path || !reflect.DeepEqual(ep.Interpreter, tt.interpreter) {
+				t.Errorf("got %+v, want %s at %s with interpreter %q", ep, tt.wantKind, path, tt.interpreter)
+			}
+		})
+	}
+}
+
+func TestDetectEntrypoint_follows_composer_proxies(t *testing.T) {
+	vendor := t.TempDir()
+	binDir := filepath.Join(vendor, "bin")
+	pkgBin := filepath.Join(vendor, "acme", "tool", "bin")
+	for _, dir := range []string{binDir, pkgBin} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(pkgBin, "tool")
+	if err := os.WriteFile(target, []byte("#!/usr/bin/env php\n<?php\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	proxy := filepath.Join(binDir, "tool")
+	if err := os.WriteFile(proxy, []byte(strings.Replace(composerProxy, "%s", "tool", 1)), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := DetectEntrypoint(proxy)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ep.Kind != EntrypointPHP || ep.Path != target || ep.Proxy != proxy {
+		t.Errorf("got %+v, want PHP target %s via proxy %s", ep, target, proxy)
+	}
+}
+
+func TestDetectEntrypoint_rejects_missing_interpreter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env phpx-no-such-interpreter\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DetectEntrypoint(path); err == nil {
+		t.Error("expected error for an interpreter not on PATH")
+	}
+}
@@ -20,6 +20,7 @@ type ScriptOptions struct {
 	// PHP settings
 	PHPBinary    string
 	AutoloadFile string
+	ComposerPhar string // Composer put on PATH for the script; optional
 
 	// Sandbox options
 	Sandbox        sandbox.Sandbox
@@ -35,6 +36,9 @@ type ScriptOptions struct {
 	// Script arguments
 	Args []string
 
+	// Working directory; defaults to the script's directory
+	WorkDir string
+
 	// I/O streams - if set, streams directly instead of buffering
 	Stdin  io.Reader
 	Stdout io.Writer
@@ -84,6 +88,11 @@ func (r *ScriptRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		proxySOCKS5Port = proxyMgr.SOCKS5Port()
 	}
 
+	workDir := r.opts.WorkDir
+	if workDir == "" {
+		workDir = filepath.Dir(r.opts.ScriptPath)
+	}
+
 	// Prepare sandbox config
 	sandboxCfg := &sandbox.Config{
 		Network:         r.opts.Network,
@@ -100,7 +109,7 @@ func (r *ScriptRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		AutoloadFile:    r.opts.AutoloadFile,
 		ScriptPath:      r.opts.ScriptPath,
 		ScriptArgs:      r.opts.Args,
-		WorkDir:         filepath.Dir(r.opts.ScriptPath),
+		WorkDir:         workDir,
 		Env:             proxyEnv,
 		AllowedEnvVars:  r.opts.AllowedEnvVars,
 		Stdin:           r.opts.Stdin,
@@ -109,6 +118,21 @@ func (r *ScriptRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		Verbose:         r.opts.Verbose,
 	}
 
+	// Child processes find the managed php, composer and dependency binaries on PATH
+	var binDirs []string
+	if r.opts.AutoloadFile != "" {
+		binDirs = append(binDirs, filepath.Join(filepath.Dir(r.opts.AutoloadFile), "bin"))
+	}
+	binDir, err := sandbox.PrepareBinDir(sandboxCfg, r.opts.ComposerPhar, binDirs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare bin directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(binDir) }()
+	sandboxCfg.BinDir = binDir
+	if r.opts.ComposerPhar != "" {
+		sandboxCfg.ReadablePaths = append(sandboxCfg.ReadablePaths, r.opts.ComposerPhar)
+	}
+
 	if r.opts.Verbose && sb.IsSandboxed() {
 		fmt.Fprintf(os.Stderr, "[phpx] Using sandbox: %s\n", sb.Name())
 	}
@@ -54,6 +54,34 @@ touch marker.txt
 	}
 }
 
+func TestScriptRunner_runs_in_given_working_directory(t *testing.T) {
+	scriptDir := t.TempDir()
+	workDir := t.TempDir()
+
+	scriptPath := filepath.Join(scriptDir, "script.sh")
+	if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\ntouch marker.txt\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	runner := NewScriptRunner(&ScriptOptions{
+		ScriptPath: scriptPath,
+		PHPBinary:  "/bin/sh",
+		Sandbox:    &sandbox.None{},
+		WorkDir:    workDir,
+		Timeout:    5 * time.Second,
+	})
+	if _, err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !fileExists(filepath.Join(workDir, "marker.txt")) {
+		t.Error("marker.txt not created in working directory")
+	}
+	if fileExists(filepath.Join(scriptDir, "marker.txt")) {
+		t.Error("marker.txt created in script directory")
+	}
+}
+
 func TestScriptRunner_returns_exit_code(t *testing.T) {
 	tests := []struct {
 		name     string
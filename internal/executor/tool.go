@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/eddmann/phpx/internal/proxy"
@@ -18,6 +20,10 @@ type ToolOptions struct {
 	PHPBinary  string
 	ToolDir    string // Directory where tool is installed
 	BinaryName string // Name of the binary to run
+	Entrypoint string // Path relative to ToolDir; defaults to vendor/bin/BinaryName
+
+	// Composer put on PATH for the tool; optional
+	ComposerPhar string
 
 	// Sandbox options
 	Sandbox        sandbox.Sandbox
@@ -26,6 +32,7 @@ type ToolOptions struct {
 	AllowedEnvVars []string
 	ReadPaths      []string
 	WritePaths     []string
+	ReadOnlyPaths  []string // Readable but never writable, even inside WorkDir
 	MemoryMB       int
 	Timeout        time.Duration
 	CPUSeconds     int
@@ -61,6 +68,15 @@ func (r *ToolRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 
 	// Construct path to tool binary
 	binaryPath := filepath.Join(r.opts.ToolDir, "vendor", "bin", r.opts.BinaryName)
+	if r.opts.Entrypoint != "" {
+		binaryPath = filepath.Join(r.opts.ToolDir, r.opts.Entrypoint)
+	}
+
+	// Binaries may be PHP, PHARs, shebang scripts or Composer proxies
+	entrypoint, err := DetectEntrypoint(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect tool binary: %w", err)
+	}
 
 	// Start proxy if network is needed and we're sandboxing
 	var proxyMgr *proxy.Manager
@@ -72,7 +88,6 @@ func (r *ToolRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 	needsProxy := sb.IsSandboxed() && r.opts.Network
 
 	if needsProxy {
-		var err error
 		proxyMgr, err = proxy.NewManager(proxy.ManagerConfig{
 			AllowedHosts: r.opts.AllowedHosts,
 			Verbose:      r.opts.Verbose,
@@ -88,22 +103,27 @@ func (r *ToolRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		proxySOCKS5Port = proxyMgr.SOCKS5Port()
 	}
 
+	// Proxied binaries expect the variable Composer's proxy would have set
+	env := proxyEnv
+	if entrypoint.Proxy != "" {
+		env = append(env, "COMPOSER_RUNTIME_BIN_DIR="+filepath.Dir(entrypoint.Proxy))
+	}
+
 	// Determine working directory
 	workDir := r.opts.WorkDir
 	if workDir == "" {
-		var err error
 		workDir, err = os.Getwd()
 		if err != nil {
 			workDir = "/"
 		}
 	}
 
-	// Add tool directory and current working directory to readable paths
-	readPaths := append(r.opts.ReadPaths, r.opts.ToolDir)
-	readPaths = append(readPaths, workDir)
+	// Add tool directory and current working directory to readable paths.
+	// The options are copied, as the runner is reused across --watch runs.
+	readPaths := append(slices.Clone(r.opts.ReadPaths), r.opts.ToolDir, workDir)
 
 	// Tools often need to write to current directory
-	writePaths := append(r.opts.WritePaths, workDir)
+	writePaths := append(slices.Clone(r.opts.WritePaths), workDir)
 
 	// Prepare sandbox config
 	sandboxCfg := &sandbox.Config{
@@ -114,15 +134,17 @@ func (r *ToolRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		ProxySOCKS5Port: proxySOCKS5Port,
 		ReadablePaths:   readPaths,
 		WritablePaths:   writePaths,
+		ReadOnlyPaths:   r.opts.ReadOnlyPaths,
 		MemoryMB:        r.opts.MemoryMB,
 		Timeout:         r.opts.Timeout,
 		CPUSeconds:      r.opts.CPUSeconds,
 		PHPBinary:       r.opts.PHPBinary,
 		AutoloadFile:    "", // Tools use their own autoloading
-		ScriptPath:      binaryPath,
+		ScriptPath:      entrypoint.Path,
 		ScriptArgs:      r.opts.Args,
+		Interpreter:     entrypoint.Interpreter,
 		WorkDir:         workDir,
-		Env:             proxyEnv,
+		Env:             env,
 		AllowedEnvVars:  r.opts.AllowedEnvVars,
 		Stdin:           r.opts.Stdin,
 		Stdout:          r.opts.Stdout,
@@ -130,12 +152,26 @@ func (r *ToolRunner) Run(ctx context.Context) (*sandbox.Result, error) {
 		Verbose:         r.opts.Verbose,
 	}
 
+	// Child processes find the managed php, composer and tool binaries on PATH
+	binDir, err := sandbox.PrepareBinDir(sandboxCfg, r.opts.ComposerPhar, filepath.Join(r.opts.ToolDir, "vendor", "bin"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare bin directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(binDir) }()
+	sandboxCfg.BinDir = binDir
+	if r.opts.ComposerPhar != "" {
+		sandboxCfg.ReadablePaths = append(sandboxCfg.ReadablePaths, r.opts.ComposerPhar)
+	}
+
 	if r.opts.Verbose && sb.IsSandboxed() {
 		fmt.Fprintf(os.Stderr, "[phpx] Using sandbox: %s\n", sb.Name())
 	}
 
 	if r.opts.Verbose {
-		fmt.Fprintf(os.Stderr, "[phpx] Running tool: %s\n", binaryPath)
+		fmt.Fprintf(os.Stderr, "[phpx] Running tool: %s (%s)\n", entrypoint.Path, entrypoint.Kind)
+		if entrypoint.Interpreter != nil {
+			fmt.Fprintf(os.Stderr, "[phpx] Interpreter: %s\n", strings.Join(entrypoint.Interpreter, " "))
+		}
 	}
 
 	// Create execution context with timeout
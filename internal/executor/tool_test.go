@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -232,6 +233,35 @@ func TestToolRunner_constructs_binary_path(t *testing.T) {
 	}
 }
 
+func TestToolRunner_runs_entrypoint(t *testing.T) {
+	toolDir := t.TempDir()
+
+	pharPath := filepath.Join(toolDir, "box.phar")
+	if err := os.WriteFile(pharPath, []byte("#!/bin/sh\necho phar\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	opts := &ToolOptions{
+		PHPBinary:  "/bin/sh",
+		ToolDir:    toolDir,
+		BinaryName: "box.phar",
+		Entrypoint: "box.phar",
+		Sandbox:    &sandbox.None{},
+		Network:    true,
+		Timeout:    5 * time.Second,
+	}
+
+	runner := NewToolRunner(opts)
+	result, err := runner.Run(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "phar\n" {
+		t.Errorf("stdout = %q, want %q", result.Stdout, "phar\n")
+	}
+}
+
 func TestToolRunner_defaults_to_current_working_directory(t *testing.T) {
 	toolDir, err := os.MkdirTemp("", "tooldir")
 	if err != nil {
@@ -289,3 +319,126 @@ pwd
 		t.Errorf("working directory = %q, want %q", got, cwd)
 	}
 }
+
+func TestToolRunner_puts_managed_binaries_on_path(t *testing.T) {
+	workDir := t.TempDir()
+	toolDir := t.TempDir()
+	binDir := filepath.Join(toolDir, "vendor", "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records where php and a sibling tool binary resolve from
+	script := `#!/bin/sh
+{
+  command -v php
+  command -v worker
+  echo "$PHPRC"
+} > paths.txt
+`
+	if err := os.WriteFile(filepath.Join(binDir, "tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "worker"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewToolRunner(&ToolOptions{
+		PHPBinary:  "/bin/sh",
+		ToolDir:    toolDir,
+		BinaryName: "tool",
+		Sandbox:    &sandbox.None{},
+		WorkDir:    workDir,
+		Timeout:    5 * time.Second,
+	})
+	if _, err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, "paths.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %q, want php, worker and PHPRC", lines)
+	}
+	shimDir := lines[2]
+	if lines[0] != filepath.Join(shimDir, "php") || lines[1] != filepath.Join(shimDir, "worker") {
+		t.Errorf("got php=%s worker=%s, want both in %s", lines[0], lines[1], shimDir)
+	}
+	if fileExists(shimDir) {
+		t.Error("bin directory not removed after the run")
+	}
+}
+
+func TestToolRunner_runs_shebang_scripts_with_their_interpreter(t *testing.T) {
+	workDir := t.TempDir()
+	toolDir := t.TempDir()
+	binDir := filepath.Join(toolDir, "vendor", "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "tool"), []byte("#!/bin/sh\necho ran > ran.txt\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// PHP would fail to run; the script must go through /bin/sh
+	runner := NewToolRunner(&ToolOptions{
+		PHPBinary:  "/bin/false",
+		ToolDir:    toolDir,
+		BinaryName: "tool",
+		Sandbox:    &sandbox.None{},
+		WorkDir:    workDir,
+		Timeout:    5 * time.Second,
+	})
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 || !fileExists(filepath.Join(workDir, "ran.txt")) {
+		t.Errorf("script not run by its interpreter (exit code %d)", result.ExitCode)
+	}
+}
+
+func TestToolRunner_leaves_path_options_unchanged(t *testing.T) {
+	toolDir := t.TempDir()
+	binDir := filepath.Join(toolDir, "vendor", "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spare capacity would let an append write into the caller's array
+	readPaths := make([]string, 1, 8)
+	readPaths[0] = "/data"
+	writePaths := make([]string, 1, 8)
+	writePaths[0] = "/out"
+
+	runner := NewToolRunner(&ToolOptions{
+		PHPBinary:  "/bin/sh",
+		ToolDir:    toolDir,
+		BinaryName: "tool",
+		Sandbox:    &sandbox.None{},
+		ReadPaths:  readPaths,
+		WritePaths: writePaths,
+		WorkDir:    t.TempDir(),
+		Timeout:    5 * time.Second,
+	})
+
+	// The same runner is reused across --watch runs
+	for range 2 {
+		if _, err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := readPaths[:2][1]; got != "" {
+		t.Errorf("run wrote %q into the caller's read paths", got)
+	}
+	if got := writePaths[:2][1]; got != "" {
+		t.Errorf("run wrote %q into the caller's write paths", got)
+	}
+}
@@ -15,7 +15,7 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/eddmann/phpx/internal/cache"
-	"github.com/eddmann/phpx/internal/composer"
+	"github.com/eddmann/phpx/internal/version"
 )
 
 const (
@@ -361,21 +361,46 @@ func LatestVersion(versions []*semver.Version) *semver.Version {
 	return versions[0] // Already sorted descending
 }
 
-// MatchingVersion returns the highest version satisfying a constraint.
-func MatchingVersion(versions []*semver.Version, constraint string) (*semver.Version, error) {
-	normalized := composer.NormalizeConstraint(constraint)
-	c, err := semver.NewConstraint(normalized)
-	if err != nil {
-		return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+// MatchingVersion returns the highest version satisfying every constraint.
+// Empty constraints are ignored.
+func MatchingVersion(versions []*semver.Version, constraints ...string) (*semver.Version, error) {
+	var parsed []version.Constraint
+	var given []string
+	for _, constraint := range constraints {
+		if constraint == "" {
+			continue
+		}
+		c, err := version.ParseConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, c)
+		given = append(given, constraint)
 	}
 
 	for _, v := range versions {
-		if c.Check(v) {
+		normalized, err := version.Normalize(v.String())
+		if err != nil {
+			continue
+		}
+		if matchesAll(parsed, normalized) {
 			return v, nil
 		}
 	}
 
-	return nil, fmt.Errorf("no PHP version satisfies '%s'", constraint)
+	if len(given) == 0 {
+		return nil, fmt.Errorf("no PHP versions available")
+	}
+	return nil, fmt.Errorf("no PHP version satisfies '%s'", strings.Join(given, "' and '"))
+}
+
+func matchesAll(constraints []version.Constraint, normalized string) bool {
+	for _, c := range constraints {
+		if !c.Matches(normalized) {
+			return false
+		}
+	}
+	return true
 }
 
 // SelectComposer returns the highest Composer version compatible with the given PHP version.
@@ -397,8 +422,26 @@ func (idx *Index) SelectComposer(phpVersion string) (*ComposerVersion, error) {
 	return nil, fmt.Errorf("no Composer version compatible with PHP %s", phpVersion)
 }
 
+// coreExtensions are compiled into every PHP build and so never listed in
+// the static build extension lists.
+var coreExtensions = map[string]bool{
+	"core":       true,
+	"date":       true,
+	"hash":       true,
+	"json":       true,
+	"pcre":       true,
+	"random":     true,
+	"reflection": true,
+	"spl":        true,
+	"standard":   true,
+}
+
 // HasExtension checks if an extension is available in the given tier.
 func (idx *Index) HasExtension(ext, tier string) bool {
+	if coreExtensions[strings.ToLower(ext)] {
+		return true
+	}
+
 	var extensions []string
 	if tier == "common" {
 		extensions = idx.CommonExtensions
@@ -96,6 +96,44 @@ func TestMatchingVersion(t *testing.T) {
 	}
 }
 
+func TestMatchingVersion_multiple_constraints(t *testing.T) {
+	versions := []*semver.Version{
+		semver.MustParse("8.4.17"),
+		semver.MustParse("8.3.17"),
+		semver.MustParse("7.4.33"),
+	}
+
+	t.Run("returns highest version satisfying all constraints", func(t *testing.T) {
+		got, err := MatchingVersion(versions, "^7.4|^8.0", "<8.4")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.String() != "8.3.17" {
+			t.Errorf("got %s, want 8.3.17", got)
+		}
+	})
+
+	t.Run("ignores empty constraints", func(t *testing.T) {
+		got, err := MatchingVersion(versions, "", "7.4.*")
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.String() != "7.4.33" {
+			t.Errorf("got %s, want 7.4.33", got)
+		}
+	})
+
+	t.Run("returns error when constraints conflict", func(t *testing.T) {
+		_, err := MatchingVersion(versions, "7.4.*", "^8.2")
+
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
 func TestLatestVersion(t *testing.T) {
 	t.Run("returns highest version from list", func(t *testing.T) {
 		versions := []*semver.Version{
@@ -152,6 +190,11 @@ func TestRequiredTier(t *testing.T) {
 			extensions: []string{"mongodb"},
 			wantErr:    true,
 		},
+		{
+			name: "treats core extensions as always available",
+			extensions: []string{"json", "pcre", "SPL"},
+			want:       "common",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,267 @@
+// Package manifest reads project tool manifests (phpx.toml), which pin the
+// tools a project uses so every checkout runs the same versions.
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/eddmann/phpx/internal/alias"
+	"github.com/eddmann/phpx/internal/version"
+)
+
+// FileName is the name of the project manifest.
+const FileName = "phpx.toml"
+
+// lockMarker introduces the lock section phpx writes at the end of the file.
+const lockMarker = "# Locked tool versions, maintained by phpx. Do not edit below this line."
+
+// Manifest is a parsed phpx.toml.
+//
+// The format is:
+//
+//	php = ">=8.2"
+//
+//	[tools.phpstan]
+//	version = "^1.11"
+//	with = ["phpstan/phpstan-symfony:^1.4"]
+//	args = ["analyse", "src"]
+//
+//	[aliases.deploy]
+//	package = "acme/deploy-cli"
+//
+//	[lock.phpstan]
+//	package = "phpstan/phpstan"
+//	version = "1.11.5"
+type Manifest struct {
+	PHP   string          `toml:"php"`   // Default PHP constraint for all tools
+	Tools map[string]Tool `toml:"tools"` // Tools keyed by name
+	Lock  map[string]Lock `toml:"lock"`  // Resolved versions keyed by tool name
+
+	Aliases alias.Registry `toml:"aliases"` // Project tool aliases
+
+	path     string
+	resolver alias.Registry // Expands tool names; see UseAliases
+}
+
+// Tool declares a project tool.
+type Tool struct {
+	Package      string          `toml:"package"` // Package, source or phar: URL; defaults to the tool name
+	Version      string          `toml:"version"` // Version constraint
+	PHP          string          `toml:"php"`
+	Extensions   []string        `toml:"extensions"`
+	With         []string        `toml:"with"`
+	AllowPlugins map[string]bool `toml:"allow-plugins"`
+	AllowScripts bool            `toml:"allow-scripts"`
+	Args         []string        `toml:"args"` // Used when no arguments are given
+}
+
+// Lock records the version a tool resolved to.
+type Lock struct {
+	Package string `toml:"package"`
+	Version string `toml:"version"`
+}
+
+// Find looks for phpx.toml in dir and its parents. It returns nil when no
+// manifest is found.
+func Find(dir string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads a manifest file.
+func Load(path string) (*Manifest, error) {
+	var m Manifest
+	if _, err := toml.DecodeFile(path, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	m.path = path
+	m.Aliases = m.Aliases.From(path)
+
+	if m.Tools == nil {
+		m.Tools = make(map[string]Tool)
+	}
+	if m.Lock == nil {
+		m.Lock = make(map[string]Lock)
+	}
+
+	return &m, nil
+}
+
+// Path returns the file the manifest was read from.
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// UseAliases sets the alias registry tool names are expanded with, so tools
+// named after user and project aliases resolve to their packages.
+func (m *Manifest) UseAliases(r alias.Registry) {
+	m.resolver = r
+}
+
+// PackageName returns the package a tool installs: its package setting, or
+// its name expanded as a tool alias.
+func (m *Manifest) PackageName(name string, tool *Tool) string {
+	if tool.Package != "" {
+		return tool.Package
+	}
+	return m.resolver.Resolve(name)
+}
+
+// Confine limits the declared tools, which come from whatever repository is
+// checked out, as alias.Registry.Confine limits project aliases: a tool named
+// after an alias in base cannot point it at another package, and its
+// allow-plugins and allow-scripts settings are dropped unless the project is
+// trusted. It returns a description of each setting it ignored.
+func (m *Manifest) Confine(base alias.Registry, trusted bool) []string {
+	var ignored []string
+
+	for _, name := range m.Names() {
+		tool := m.Tools[name]
+
+		if existing, ok := base[name]; ok && tool.Package != "" && tool.Package != existing.Package {
+			ignored = append(ignored, fmt.Sprintf("%s: package for the %s alias", name, existing.Origin))
+			tool.Package = ""
+		}
+
+		if !trusted && (len(tool.AllowPlugins) > 0 || tool.AllowScripts) {
+			ignored = append(ignored, fmt.Sprintf("%s: allow-plugins and allow-scripts (use --trust-project to apply them)", name))
+			tool.AllowPlugins, tool.AllowScripts = nil, false
+		}
+
+		m.Tools[name] = tool
+	}
+	return ignored
+}
+
+// Lookup finds a tool by its name or package name.
+func (m *Manifest) Lookup(arg string) (string, *Tool, bool) {
+	if tool, ok := m.Tools[arg]; ok {
+		return arg, &tool, true
+	}
+
+	pkg := m.resolver.Resolve(arg)
+	for _, name := range m.Names() {
+		tool := m.Tools[name]
+		if m.PackageName(name, &tool) == pkg {
+			return name, &tool, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// Names returns the declared tool names in sorted order.
+func (m *Manifest) Names() []string {
+	names := make([]string, 0, len(m.Tools))
+	for name := range m.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PHPConstraint returns the PHP constraint for a tool, falling back to the
+// manifest-wide default.
+func (m *Manifest) PHPConstraint(tool *Tool) string {
+	if tool.PHP != "" {
+		return tool.PHP
+	}
+	return m.PHP
+}
+
+// LockedVersion returns the locked version of a tool, provided the lock is
+// for the tool's current package and satisfies its version constraint.
+func (m *Manifest) LockedVersion(name string) (string, bool) {
+	tool, ok := m.Tools[name]
+	lock, locked := m.Lock[name]
+	if !ok || !locked || lock.Version == "" || lock.Package != m.PackageName(name, &tool) {
+		return "", false
+	}
+
+	if tool.Version != "" {
+		c, err := version.ParseConstraint(tool.Version)
+		if err != nil {
+			return "", false
+		}
+		normalized, err := version.Normalize(lock.Version)
+		if err != nil || !c.Matches(normalized) {
+			return "", false
+		}
+	}
+
+	return lock.Version, true
+}
+
+// SaveLock rewrites the lock section of the manifest file, keeping the rest
+// of the file, including comments, untouched. Entries for tools no longer
+// declared are dropped.
+func (m *Manifest) SaveLock() error {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+
+	content := strings.TrimRight(stripLock(string(data)), "\n")
+
+	locks := make(map[string]Lock)
+	for name, lock := range m.Lock {
+		if _, ok := m.Tools[name]; ok {
+			locks[name] = lock
+		}
+	}
+	m.Lock = locks
+
+	if len(locks) > 0 {
+		var buf bytes.Buffer
+		enc := toml.NewEncoder(&buf)
+		enc.Indent = ""
+		if err := enc.Encode(map[string]map[string]Lock{"lock": locks}); err != nil {
+			return err
+		}
+		content += "\n\n" + lockMarker + "\n" + strings.TrimPrefix(buf.String(), "[lock]\n")
+	}
+
+	return os.WriteFile(m.path, []byte(strings.TrimRight(content, "\n")+"\n"), 0644)
+}
+
+// stripLock removes the lock marker and all [lock] tables.
+func stripLock(content string) string {
+	var kept []string
+	inLock := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == lockMarker {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			inLock = trimmed == "[lock]" || strings.HasPrefix(trimmed, "[lock.")
+		}
+		if !inLock {
+			kept = append(kept, line)
+		}
+	}
+
+	return strings.Join(kept, "")
+}
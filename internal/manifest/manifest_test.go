@@ -0,0 +1,327 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eddmann/phpx/internal/alias"
+)
+
+const sample = `# Project tools
+php = ">=8.2"
+
+[tools.phpstan]
+version = "^1.11"
+with = ["phpstan/phpstan-symfony:^1.4"]
+args = ["analyse", "src"]
+
+[tools.cs]
+package = "friendsofphp/php-cs-fixer"
+php = "8.3.*"
+`
+
+func writeManifest(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFind(t *testing.T) {
+	t.Run("finds the manifest in a parent directory", func(t *testing.T) {
+		root := t.TempDir()
+		path := writeManifest(t, root, sample)
+		nested := filepath.Join(root, "src", "Domain")
+		if err := os.MkdirAll(nested, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := Find(nested)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m == nil || m.Path() != path {
+			t.Fatalf("got %v, want manifest at %s", m, path)
+		}
+	})
+
+	t.Run("returns nil without a manifest", func(t *testing.T) {
+		m, err := Find(t.TempDir())
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m != nil {
+			t.Errorf("got %+v, want nil", m)
+		}
+	})
+
+	t.Run("reports invalid TOML", func(t *testing.T) {
+		dir := t.TempDir()
+		writeManifest(t, dir, "[tools.phpstan\n")
+
+		if _, err := Find(dir); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
+
+func TestLoad_aliases(t *testing.T) {
+	path := writeManifest(t, t.TempDir(), sample+"\n[aliases.deploy]\npackage = \"acme/deploy-cli\"\n")
+
+	m, err := Load(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := m.Aliases["deploy"]
+	if got.Package != "acme/deploy-cli" || got.Origin != path {
+		t.Errorf("got %+v, want acme/deploy-cli from %s", got, path)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(writeManifest(t, dir, sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		arg      string
+		wantName string
+		wantOK   bool
+	}{
+		{
+			name:     "finds a tool by name",
+			arg:      "phpstan",
+			wantName: "phpstan",
+			wantOK:   true,
+		},
+		{
+			name:     "finds an aliased tool by package",
+			arg:      "phpstan/phpstan",
+			wantName: "phpstan",
+			wantOK:   true,
+		},
+		{
+			name:     "finds a tool by its package setting",
+			arg:      "php-cs-fixer",
+			wantName: "cs",
+			wantOK:   true,
+		},
+		{
+			name:   "misses undeclared tools",
+			arg:    "psalm",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, _, ok := m.Lookup(tt.arg)
+
+			if ok != tt.wantOK || name != tt.wantName {
+				t.Errorf("got (%q, %v), want (%q, %v)", name, ok, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestUseAliases(t *testing.T) {
+	m := &Manifest{Tools: map[string]Tool{"deploy": {Version: "^1.0"}}}
+	m.UseAliases(alias.Registry{"deploy": {Package: "acme/deploy-cli", Origin: "user"}})
+
+	deploy := m.Tools["deploy"]
+	if got := m.PackageName("deploy", &deploy); got != "acme/deploy-cli" {
+		t.Errorf("PackageName(deploy) = %q, want the alias's package", got)
+	}
+	if name, _, ok := m.Lookup("acme/deploy-cli"); !ok || name != "deploy" {
+		t.Errorf("Lookup(acme/deploy-cli) = (%q, %v), want deploy", name, ok)
+	}
+}
+
+func TestConfine(t *testing.T) {
+	base := alias.Builtins().Merge(alias.Registry{
+		"deploy": {Package: "acme/deploy-cli", Origin: "user"},
+	})
+	load := func() *Manifest {
+		return &Manifest{Tools: map[string]Tool{
+			"phpstan": {Package: "evil/phpstan", Version: "^1.11"},
+			"deploy":  {Package: "acme/deploy-cli"},
+			"pest": {
+				AllowPlugins: map[string]bool{"pestphp/pest-plugin": true},
+				AllowScripts: true,
+			},
+			"cs": {Package: "friendsofphp/php-cs-fixer"},
+		}}
+	}
+
+	t.Run("keeps aliases pointing at their packages", func(t *testing.T) {
+		m := load()
+
+		m.Confine(base, false)
+
+		if got := m.Tools["phpstan"]; got.Package != "" || got.Version != "^1.11" {
+			t.Errorf("phpstan = %+v, want the package dropped and the version kept", got)
+		}
+		if got := m.Tools["deploy"].Package; got != "acme/deploy-cli" {
+			t.Errorf("deploy package = %q, want the user alias's own package kept", got)
+		}
+		if got := m.Tools["cs"].Package; got != "friendsofphp/php-cs-fixer" {
+			t.Errorf("cs package = %q, want new tool names kept", got)
+		}
+	})
+
+	t.Run("drops plugins and scripts unless trusted", func(t *testing.T) {
+		m := load()
+
+		ignored := m.Confine(base, false)
+
+		if got := m.Tools["pest"]; len(got.AllowPlugins) != 0 || got.AllowScripts {
+			t.Errorf("pest = %+v, want plugins and scripts dropped", got)
+		}
+		if len(ignored) != 2 {
+			t.Errorf("got ignored %v, want the phpstan package and pest plugins", ignored)
+		}
+	})
+
+	t.Run("keeps plugins and scripts when trusted", func(t *testing.T) {
+		m := load()
+
+		ignored := m.Confine(base, true)
+
+		if got := m.Tools["pest"]; len(got.AllowPlugins) != 1 || !got.AllowScripts {
+			t.Errorf("pest = %+v, want plugins and scripts kept", got)
+		}
+		if len(ignored) != 1 {
+			t.Errorf("got ignored %v, want only the phpstan package", ignored)
+		}
+	})
+}
+
+func TestPHPConstraint(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(writeManifest(t, dir, sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	phpstan := m.Tools["phpstan"]
+	if got := m.PHPConstraint(&phpstan); got != ">=8.2" {
+		t.Errorf("phpstan: got %q, want manifest default >=8.2", got)
+	}
+
+	cs := m.Tools["cs"]
+	if got := m.PHPConstraint(&cs); got != "8.3.*" {
+		t.Errorf("cs: got %q, want 8.3.*", got)
+	}
+}
+
+func TestLockedVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		lock   Lock
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "uses a lock satisfying the constraint",
+			lock:   Lock{Package: "phpstan/phpstan", Version: "1.11.5"},
+			want:   "1.11.5",
+			wantOK: true,
+		},
+		{
+			name:   "ignores a lock outside the constraint",
+			lock:   Lock{Package: "phpstan/phpstan", Version: "2.0.0"},
+			wantOK: false,
+		},
+		{
+			name:   "ignores a lock for another package",
+			lock:   Lock{Package: "acme/phpstan-fork", Version: "1.11.5"},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Load(writeManifest(t, t.TempDir(), sample))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Lock["phpstan"] = tt.lock
+
+			got, ok := m.LockedVersion("phpstan")
+
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("got (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSaveLock(t *testing.T) {
+	t.Run("appends the lock and keeps the rest of the file", func(t *testing.T) {
+		path := writeManifest(t, t.TempDir(), sample)
+		m, _ := Load(path)
+
+		m.Lock["phpstan"] = Lock{Package: "phpstan/phpstan", Version: "1.11.5"}
+		if err := m.SaveLock(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if !strings.HasPrefix(string(data), sample) {
+			t.Errorf("manifest content changed:\n%s", data)
+		}
+
+		reloaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(reloaded.Lock, m.Lock) {
+			t.Errorf("Lock = %v, want %v", reloaded.Lock, m.Lock)
+		}
+	})
+
+	t.Run("replaces an existing lock", func(t *testing.T) {
+		path := writeManifest(t, t.TempDir(), sample)
+		m, _ := Load(path)
+
+		m.Lock["phpstan"] = Lock{Package: "phpstan/phpstan", Version: "1.11.5"}
+		_ = m.SaveLock()
+		m.Lock["phpstan"] = Lock{Package: "phpstan/phpstan", Version: "1.12.0"}
+		_ = m.SaveLock()
+
+		data, _ := os.ReadFile(path)
+		if strings.Count(string(data), "[lock.phpstan]") != 1 {
+			t.Errorf("want a single lock entry, got:\n%s", data)
+		}
+
+		reloaded, _ := Load(path)
+		if got := reloaded.Lock["phpstan"].Version; got != "1.12.0" {
+			t.Errorf("locked version = %q, want 1.12.0", got)
+		}
+	})
+
+	t.Run("drops entries for undeclared tools", func(t *testing.T) {
+		path := writeManifest(t, t.TempDir(), sample+"\n[lock.psalm]\npackage = \"vimeo/psalm\"\nversion = \"5.0.0\"\n")
+		m, _ := Load(path)
+
+		if err := m.SaveLock(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		reloaded, _ := Load(path)
+		if len(reloaded.Lock) != 0 {
+			t.Errorf("Lock = %v, want empty", reloaded.Lock)
+		}
+	})
+}
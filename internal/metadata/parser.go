@@ -3,16 +3,26 @@ package metadata
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/BurntSushi/toml"
+	"github.com/eddmann/phpx/internal/composer"
 )
 
 // Metadata represents the parsed // phpx block from a PHP script.
 type Metadata struct {
-	PHP        string   `toml:"php"`
-	Packages   []string `toml:"packages"`
-	Extensions []string `toml:"extensions"`
+	PHP              string                `toml:"php"`
+	Packages         []string              `toml:"packages"`
+	Extensions       []string              `toml:"extensions"`
+	Repositories     []composer.Repository `toml:"repositories"`
+	MinimumStability string                `toml:"minimum-stability"`
+	AllowPlugins     map[string]bool       `toml:"allow-plugins"`
+	AllowScripts     bool                  `toml:"allow-scripts"`
 }
 
 // Parse extracts metadata from a PHP script's // phpx comment block.
@@ -23,6 +33,10 @@ type Metadata struct {
 //	// php = ">=8.2"
 //	// packages = ["vendor/package:^1.0"]
 //	// extensions = ["redis"]
+//	// repositories = [{ type = "vcs", url = "https://github.com/acme/lib" }]
+//	// minimum-stability = "dev"
+//	// allow-plugins = { "php-http/discovery" = true }
+//	// allow-scripts = true
 func Parse(content []byte) (*Metadata, error) {
 	scanner := bufio.NewScanner(bytes.NewReader(content))
 
@@ -71,3 +85,156 @@ func Parse(content []byte) (*Metadata, error) {
 
 	return &meta, nil
 }
+
+// Strip removes the // phpx block from a script, returning the script
+// unchanged when it has none.
+func Strip(content []byte) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+
+	start := -1
+	for i, line := range lines {
+		if string(bytes.TrimSpace(line)) == "// phpx" {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return content
+	}
+
+	end := start + 1
+	for end < len(lines) && bytes.HasPrefix(bytes.TrimSpace(lines[end]), []byte("//")) {
+		end++
+	}
+	// Drop a blank line left between the block and the code
+	if start > 0 && end < len(lines) && len(bytes.TrimSpace(lines[end])) == 0 {
+		end++
+	}
+
+	var out []byte
+	for i, line := range lines {
+		if i < start || i >= end {
+			out = append(out, line...)
+		}
+	}
+	return out
+}
+
+// Format returns metadata as a // phpx block, one line per set field, in
+// the format Parse reads.
+func Format(meta *Metadata) string {
+	var b strings.Builder
+	b.WriteString("// phpx\n")
+
+	if meta.PHP != "" {
+		fmt.Fprintf(&b, "// php = %s\n", tomlValue(meta.PHP))
+	}
+	if len(meta.Packages) > 0 {
+		fmt.Fprintf(&b, "// packages = %s\n", tomlValue(meta.Packages))
+	}
+	if len(meta.Extensions) > 0 {
+		fmt.Fprintf(&b, "// extensions = %s\n", tomlValue(meta.Extensions))
+	}
+	if len(meta.Repositories) > 0 {
+		repos := make([]string, len(meta.Repositories))
+		for i, repo := range meta.Repositories {
+			fields := map[string]any{"type": repo.Type, "url": repo.URL}
+			if len(repo.Options) > 0 {
+				fields["options"] = repo.Options
+			}
+			repos[i] = tomlValue(fields)
+		}
+		fmt.Fprintf(&b, "// repositories = [%s]\n", strings.Join(repos, ", "))
+	}
+	if meta.MinimumStability != "" {
+		fmt.Fprintf(&b, "// minimum-stability = %s\n", tomlValue(meta.MinimumStability))
+	}
+	if len(meta.AllowPlugins) > 0 {
+		plugins := make(map[string]any, len(meta.AllowPlugins))
+		for name, allow := range meta.AllowPlugins {
+			plugins[name] = allow
+		}
+		fmt.Fprintf(&b, "// allow-plugins = %s\n", tomlValue(plugins))
+	}
+	if meta.AllowScripts {
+		b.WriteString("// allow-scripts = true\n")
+	}
+
+	return b.String()
+}
+
+// bareKey matches TOML keys that need no quotes.
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlValue formats a value as an inline TOML value, with tables' keys in
+// sorted order.
+func tomlValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		// JSON string escapes are valid in TOML basic strings
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n")
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		items := make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return tomlValue(items)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fields := make([]string, len(keys))
+		for i, key := range keys {
+			name := key
+			if !bareKey.MatchString(key) {
+				name = tomlValue(key)
+			}
+			fields[i] = name + " = " + tomlValue(v[key])
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Insert puts a // phpx block into a script, after the line opening PHP,
+// replacing any block the script already has.
+func Insert(content []byte, block string) []byte {
+	content = Strip(content)
+
+	tag := bytes.Index(content, []byte("<?php"))
+	if tag == -1 {
+		return append([]byte("<?php\n"+block+"?>\n"), content...)
+	}
+
+	at := len(content)
+	if end := bytes.IndexByte(content[tag:], '\n'); end != -1 {
+		at = tag + end + 1
+	}
+
+	var out []byte
+	out = append(out, content[:at]...)
+	if at == len(content) && !bytes.HasSuffix(out, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	out = append(out, block...)
+	if at < len(content) && len(bytes.TrimSpace(content[at:])) > 0 {
+		out = append(out, '\n')
+	}
+	return append(out, content[at:]...)
+}
@@ -1,7 +1,10 @@
 package metadata
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/eddmann/phpx/internal/composer"
 )
 
 func TestParse(t *testing.T) {
@@ -146,6 +149,163 @@ use Carbon\Carbon;
 	}
 }
 
+func TestParse_composer_settings(t *testing.T) {
+	t.Run("parses repositories and minimum stability", func(t *testing.T) {
+		content := `<?php
+// phpx
+// packages = ["acme/lib:dev-main"]
+// repositories = [{ type = "vcs", url = "https://github.com/acme/lib" }]
+// minimum-stability = "dev"
+`
+
+		meta, err := Parse([]byte(content))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(meta.Repositories) != 1 {
+			t.Fatalf("got %d repositories, want 1", len(meta.Repositories))
+		}
+
+		if got := meta.Repositories[0].String(); got != "vcs:https://github.com/acme/lib" {
+			t.Errorf("Repositories[0] = %q, want vcs:https://github.com/acme/lib", got)
+		}
+
+		if meta.MinimumStability != "dev" {
+			t.Errorf("MinimumStability = %q, want dev", meta.MinimumStability)
+		}
+	})
+
+	t.Run("parses plugin rules and script opt-in", func(t *testing.T) {
+		content := `<?php
+// phpx
+// packages = ["php-http/discovery:^1.19"]
+// allow-plugins = { "php-http/discovery" = true, "acme/*" = false }
+// allow-scripts = true
+`
+
+		meta, err := Parse([]byte(content))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]bool{"php-http/discovery": true, "acme/*": false}
+		if !reflect.DeepEqual(meta.AllowPlugins, want) {
+			t.Errorf("AllowPlugins = %v, want %v", meta.AllowPlugins, want)
+		}
+
+		if !meta.AllowScripts {
+			t.Error("AllowScripts = false, want true")
+		}
+	})
+}
+
+func TestFormat(t *testing.T) {
+	meta := &Metadata{
+		PHP:        ">=8.2",
+		Packages:   []string{"symfony/console:^7.0", "acme/lib:dev-main"},
+		Extensions: []string{"redis"},
+		Repositories: []composer.Repository{
+			{Type: "vcs", URL: "https://github.com/acme/lib"},
+			{Type: "path", URL: "../tools", Options: map[string]any{"symlink": false}},
+		},
+		MinimumStability: "dev",
+		AllowPlugins:     map[string]bool{"php-http/discovery": true},
+		AllowScripts:     true,
+	}
+
+	got := Format(meta)
+
+	want := `// phpx
+// php = ">=8.2"
+// packages = ["symfony/console:^7.0", "acme/lib:dev-main"]
+// extensions = ["redis"]
+// repositories = [{ type = "vcs", url = "https://github.com/acme/lib" }, { options = { symlink = false }, type = "path", url = "../tools" }]
+// minimum-stability = "dev"
+// allow-plugins = { "php-http/discovery" = true }
+// allow-scripts = true
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	parsed, err := Parse([]byte("<?php\n" + got))
+	if err != nil {
+		t.Fatalf("formatted block does not parse: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, meta) {
+		t.Errorf("round trip got %+v, want %+v", parsed, meta)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "removes the block and the blank line after it",
+			content: "<?php\n// phpx\n// packages = [\"acme/lib\"]\n\necho 1;\n",
+			want:    "<?php\necho 1;\n",
+		},
+		{
+			name:    "keeps other comments",
+			content: "<?php\n// Greets\n// phpx\n// php = \">=8.2\"\necho 1;\n",
+			want:    "<?php\n// Greets\necho 1;\n",
+		},
+		{
+			name:    "leaves scripts without a block",
+			content: "<?php\necho 1;\n",
+			want:    "<?php\necho 1;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Strip([]byte(tt.content))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	block := "// phpx\n// php = \">=8.2\"\n"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "adds the block after the opening tag",
+			content: "#!/usr/bin/env phpx\n<?php\necho 1;\n",
+			want:    "#!/usr/bin/env phpx\n<?php\n// phpx\n// php = \">=8.2\"\n\necho 1;\n",
+		},
+		{
+			name:    "replaces an existing block",
+			content: "<?php\n// phpx\n// packages = [\"acme/lib\"]\n\necho 1;\n",
+			want:    "<?php\n// phpx\n// php = \">=8.2\"\n\necho 1;\n",
+		},
+		{
+			name:    "completes a lone opening tag",
+			content: "<?php",
+			want:    "<?php\n// phpx\n// php = \">=8.2\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Insert([]byte(tt.content), block)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func sliceEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
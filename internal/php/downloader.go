@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/eddmann/phpx/internal/cache"
 	"github.com/schollz/progressbar/v3"
 )
 
@@ -47,44 +48,59 @@ func Download(version, tier, destPath string, showProgress bool) error {
 	}
 
 	filename := fmt.Sprintf("php-%s-cli-%s-%s.tar.gz", version, osName(), archName())
-	url := baseURL + filename
+	label := fmt.Sprintf("Downloading PHP %s", version)
+	if err := fetchArchive(baseURL+filename, filepath.Dir(destPath), label, showProgress); err != nil {
+		return fmt.Errorf("failed to download PHP: %w", err)
+	}
+
+	// Verify the binary exists and is executable
+	if _, err := os.Stat(destPath); err != nil {
+		return fmt.Errorf("PHP binary not found after extraction: %w", err)
+	}
 
+	// Record digests so 'phpx cache verify' can detect a corrupted binary
+	return cache.WriteManifest(installDir(destPath), "", "bin")
+}
+
+// fetchArchive downloads a tar.gz and extracts it into destDir.
+func fetchArchive(url, destDir, label string, showProgress bool) error {
 	resp, err := http.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download PHP: %w", err)
+		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download PHP: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return err
 	}
 
 	// Set up reader with optional progress bar
 	var reader io.Reader = resp.Body
 	if showProgress {
-		bar := progressbar.DefaultBytes(
-			resp.ContentLength,
-			fmt.Sprintf("Downloading PHP %s", version),
-		)
+		bar := progressbar.DefaultBytes(resp.ContentLength, label)
 		reader = io.TeeReader(resp.Body, bar)
 	}
 
 	// Extract tar.gz
-	if err := extractTarGz(reader, filepath.Dir(destPath)); err != nil {
-		return fmt.Errorf("failed to extract PHP: %w", err)
+	if err := extractTarGz(reader, destDir); err != nil {
+		return fmt.Errorf("failed to extract: %w", err)
 	}
+	return nil
+}
 
-	// Verify the binary exists and is executable
-	if _, err := os.Stat(destPath); err != nil {
-		return fmt.Errorf("PHP binary not found after extraction: %w", err)
-	}
+// installDir returns the version directory containing bin/php.
+func installDir(phpPath string) string {
+	return filepath.Dir(filepath.Dir(phpPath))
+}
 
-	return nil
+// Verify checks a cached PHP binary against the digest recorded at download time.
+func Verify(res *Resolution) error {
+	return cache.VerifyFiles(installDir(res.Path), "bin/php")
 }
 
 // isPathWithinDir checks if target path is safely within the base directory.
@@ -0,0 +1,76 @@
+package php
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// Platform is an OS and architecture static PHP builds are published for,
+// named as in static-php.dev URLs.
+type Platform struct {
+	OS   string // linux or macos
+	Arch string // x86_64 or aarch64
+}
+
+func (p Platform) String() string {
+	return p.OS + "-" + p.Arch
+}
+
+// HostPlatform returns the platform phpx is running on.
+func HostPlatform() Platform {
+	return Platform{OS: osName(), Arch: archName()}
+}
+
+// platformOS and platformArch map accepted names to static-php.dev's.
+var (
+	platformOS   = map[string]string{"linux": "linux", "macos": "macos", "darwin": "macos"}
+	platformArch = map[string]string{"x86_64": "x86_64", "amd64": "x86_64", "aarch64": "aarch64", "arm64": "aarch64"}
+)
+
+// ParsePlatform parses a platform such as "linux-aarch64". Go's names
+// ("darwin", "amd64", "arm64") are accepted too.
+func ParsePlatform(s string) (Platform, error) {
+	osPart, archPart, ok := strings.Cut(strings.ToLower(s), "-")
+	system, osOK := platformOS[osPart]
+	arch, archOK := platformArch[archPart]
+	if !ok || !osOK || !archOK {
+		return Platform{}, fmt.Errorf("unsupported platform %q; use linux-x86_64, linux-aarch64, macos-x86_64 or macos-aarch64", s)
+	}
+	return Platform{OS: system, Arch: arch}, nil
+}
+
+// EnsureMicro returns the path of the static PHP micro SAPI (micro.sfx) for
+// a version, tier and platform, downloading it if it is not cached. A PHAR
+// appended to micro.sfx runs as a self-contained executable.
+func EnsureMicro(version, tier string, platform Platform, showProgress bool) (string, error) {
+	path, err := cache.MicroPath(version, tier, platform.String())
+	if err != nil {
+		return "", err
+	}
+	if cache.Exists(path) {
+		return path, nil
+	}
+
+	baseURL := CommonBaseURL
+	if tier == "bulk" {
+		baseURL = BulkBaseURL
+	}
+
+	filename := fmt.Sprintf("php-%s-micro-%s.tar.gz", version, platform)
+	label := fmt.Sprintf("Downloading PHP %s micro (%s)", version, platform)
+	if err := fetchArchive(baseURL+filename, filepath.Dir(path), label, showProgress); err != nil {
+		_ = os.RemoveAll(filepath.Dir(path))
+		return "", fmt.Errorf("failed to download PHP %s micro for %s: %w", version, platform, err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		_ = os.RemoveAll(filepath.Dir(path))
+		return "", fmt.Errorf("micro.sfx not found after extraction: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,65 @@
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Platform
+		wantErr bool
+	}{
+		{input: "linux-aarch64", want: Platform{OS: "linux", Arch: "aarch64"}},
+		{input: "linux-x86_64", want: Platform{OS: "linux", Arch: "x86_64"}},
+		{input: "macos-aarch64", want: Platform{OS: "macos", Arch: "aarch64"}},
+		{input: "darwin-arm64", want: Platform{OS: "macos", Arch: "aarch64"}},
+		{input: "Linux-AMD64", want: Platform{OS: "linux", Arch: "x86_64"}},
+		{input: "windows-x86_64", wantErr: true},
+		{input: "linux", wantErr: true},
+		{input: "linux-riscv64", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePlatform(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureMicro_uses_cached_download(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	want := filepath.Join(home, ".phpx", "micro", "8.3.17-common-linux-aarch64", "micro.sfx")
+	if err := os.MkdirAll(filepath.Dir(want), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(want, []byte("sfx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := EnsureMicro("8.3.17", "common", Platform{OS: "linux", Arch: "aarch64"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,8 +1,6 @@
 package php
 
 import (
-	"fmt"
-
 	"github.com/Masterminds/semver/v3"
 	"github.com/eddmann/phpx/internal/cache"
 	"github.com/eddmann/phpx/internal/index"
@@ -18,6 +16,12 @@ type Resolution struct {
 
 // Resolve determines the PHP version and tier needed for the given constraint and extensions.
 func Resolve(idx *index.Index, constraint string, extensions []string) (*Resolution, error) {
+	return ResolveAll(idx, []string{constraint}, extensions)
+}
+
+// ResolveAll determines the PHP version and tier satisfying every constraint,
+// such as a --php flag together with a package's own PHP requirement.
+func ResolveAll(idx *index.Index, constraints []string, extensions []string) (*Resolution, error) {
 	// Determine required tier
 	tier, err := idx.RequiredTier(extensions)
 	if err != nil {
@@ -31,17 +35,9 @@ func Resolve(idx *index.Index, constraint string, extensions []string) (*Resolut
 	}
 
 	// Resolve version
-	var version *semver.Version
-	if constraint == "" {
-		version = index.LatestVersion(versions)
-		if version == nil {
-			return nil, fmt.Errorf("no PHP versions available")
-		}
-	} else {
-		version, err = index.MatchingVersion(versions, constraint)
-		if err != nil {
-			return nil, err
-		}
+	version, err := index.MatchingVersion(versions, constraints...)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check cache
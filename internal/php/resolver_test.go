@@ -97,3 +97,33 @@ func TestResolve(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveAll(t *testing.T) {
+	idx := &index.Index{
+		CommonVersions: []*semver.Version{
+			semver.MustParse("8.4.17"),
+			semver.MustParse("8.3.17"),
+			semver.MustParse("7.4.33"),
+		},
+		CommonExtensions: []string{"curl"},
+	}
+
+	t.Run("satisfies every constraint", func(t *testing.T) {
+		res, err := ResolveAll(idx, []string{"<8.4", "^7.4|^8.0"}, []string{"json"})
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.Version.String() != "8.3.17" {
+			t.Errorf("Version = %s, want 8.3.17", res.Version)
+		}
+	})
+
+	t.Run("returns error when constraints conflict", func(t *testing.T) {
+		_, err := ResolveAll(idx, []string{"7.4.*", "^8.1"}, nil)
+
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package remote fetches scripts run from URLs into the script cache.
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// httpClient bounds how long a fetch may take.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+var digestPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Script is a script fetched from a URL.
+type Script struct {
+	URL    string
+	Digest string // sha256 of the content
+	Path   string // Location in the script cache
+	Cached bool   // Whether the pinned script was already cached
+}
+
+// IsRemote reports whether a script argument is a URL or gh: shorthand.
+func IsRemote(arg string) bool {
+	return strings.HasPrefix(arg, "https://") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "gh:")
+}
+
+// ResolveURL expands the GitHub shorthand "gh:owner/repo/path/to/script.php"
+// to the file's raw URL, on the default branch or at the ref given with
+// "@ref". Other URLs are returned unchanged.
+func ResolveURL(arg string) (string, error) {
+	rest, ok := strings.CutPrefix(arg, "gh:")
+	if !ok {
+		if _, err := url.ParseRequestURI(arg); err != nil {
+			return "", fmt.Errorf("invalid script URL %q: %w", arg, err)
+		}
+		return arg, nil
+	}
+
+	ref := "HEAD"
+	if i := strings.LastIndex(rest, "@"); i != -1 {
+		rest, ref = rest[:i], rest[i+1:]
+	}
+
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" || ref == "" {
+		return "", fmt.Errorf("invalid GitHub script %q; use gh:owner/repo/path/to/script.php[@ref]", arg)
+	}
+
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", parts[0], parts[1], ref, parts[2]), nil
+}
+
+// ValidDigest reports whether digest is a lowercase hex sha256 digest.
+func ValidDigest(digest string) bool {
+	return digestPattern.MatchString(digest)
+}
+
+// Fetch downloads the script at rawURL into the script cache. A pinned
+// digest is verified, and a script already cached under its pin is used
+// without fetching it again; unpinned scripts are always fetched.
+func Fetch(rawURL, digest string) (*Script, error) {
+	if digest != "" {
+		if !ValidDigest(digest) {
+			return nil, fmt.Errorf("invalid sha256 digest %q", digest)
+		}
+		dir, err := cache.ScriptPath(rawURL, digest)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, scriptName(rawURL))
+		if cache.Exists(path) {
+			return &Script{URL: rawURL, Digest: digest, Path: path, Cached: true}, nil
+		}
+	}
+
+	scripts, err := cache.ScriptsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.EnsureDir(scripts); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(scripts, ".download-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	got, err := download(rawURL, tmp)
+	_ = tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	if digest != "" && got != digest {
+		return nil, fmt.Errorf("%s has sha256 %s, want %s", rawURL, got, digest)
+	}
+
+	dir, err := cache.ScriptPath(rawURL, got)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, scriptName(rawURL))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	return &Script{URL: rawURL, Digest: got, Path: path}, nil
+}
+
+// scriptName is the file name a fetched script is stored under: the last
+// element of its URL path, so errors and __FILE__ name it recognisably.
+func scriptName(rawURL string) string {
+	name := "script.php"
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	return name
+}
+
+// download writes the body at rawURL to w and returns its sha256 digest.
+func download(rawURL string, w io.Writer) (string, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsRemote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{"https://example.com/script.php", true},
+		{"http://example.com/script.php", true},
+		{"gh:owner/repo/script.php", true},
+		{"script.php", false},
+		{"./https/script.php", false},
+		{"-", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			if got := IsRemote(tt.arg); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "leaves URLs unchanged",
+			arg:  "https://example.com/script.php",
+			want: "https://example.com/script.php",
+		},
+		{
+			name: "expands GitHub shorthand on the default branch",
+			arg:  "gh:owner/repo/bin/script.php",
+			want: "https://raw.githubusercontent.com/owner/repo/HEAD/bin/script.php",
+		},
+		{
+			name: "expands GitHub shorthand at a ref",
+			arg:  "gh:owner/repo/script.php@v1.2.0",
+			want: "https://raw.githubusercontent.com/owner/repo/v1.2.0/script.php",
+		},
+		{
+			name:    "rejects shorthand without a path",
+			arg:     "gh:owner/repo",
+			wantErr: true,
+		},
+		{
+			name:    "rejects shorthand with an empty ref",
+			arg:     "gh:owner/repo/script.php@",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveURL(tt.arg)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	const content = "<?php echo 'hello';\n"
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/scripts/hello.php" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+	url := server.URL + "/scripts/hello.php"
+
+	t.Run("fetches an unpinned script and reports its digest", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		got, err := Fetch(url, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.Digest != digest {
+			t.Errorf("got digest %s, want %s", got.Digest, digest)
+		}
+		if filepath.Base(got.Path) != "hello.php" {
+			t.Errorf("got path %s, want hello.php", got.Path)
+		}
+		data, _ := os.ReadFile(got.Path)
+		if string(data) != content {
+			t.Errorf("got content %q, want %q", data, content)
+		}
+	})
+
+	t.Run("reuses a cached pinned script", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		if _, err := Fetch(url, digest); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		before := requests
+
+		got, err := Fetch(url, digest)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !got.Cached || requests != before {
+			t.Errorf("got cached %v after %d requests, want cached without fetching", got.Cached, requests-before)
+		}
+	})
+
+	t.Run("rejects a digest mismatch", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		_, err := Fetch(url, strings.Repeat("0", 64))
+
+		if err == nil || !strings.Contains(err.Error(), digest) {
+			t.Errorf("got %v, want mismatch error naming %s", err, digest)
+		}
+	})
+
+	t.Run("rejects an invalid digest", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		if _, err := Fetch(url, "abc123"); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+
+	t.Run("reports HTTP errors", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+
+		if _, err := Fetch(server.URL+"/missing.php", ""); err == nil {
+			t.Error("got nil, want error")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrepareBinDir creates the per-run directory put first on PATH as
+// cfg.BinDir, so child processes started through proc_open or exec find the
+// managed executables rather than whatever the host provides:
+//
+//   - php: cfg.PHPBinary, with the main process's resource limits written
+//     to a php.ini that PHP finds through PHPRC
+//   - composer: a wrapper running composerPhar with that php and no php.ini,
+//     when set
+//   - every executable in binDirs, such as a tool's vendor/bin
+//
+// Other entries are symlinks, so the sandbox only needs read access to their
+// targets. The caller removes the directory after the run.
+func PrepareBinDir(cfg *Config, composerPhar string, binDirs ...string) (string, error) {
+	dir, err := os.MkdirTemp("", "phpx-bin-")
+	if err != nil {
+		return "", err
+	}
+	// Readable by sandboxes that run as another user
+	if err := os.Chmod(dir, 0755); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	link := func(name, target string) error {
+		path := filepath.Join(dir, name)
+		if _, err := os.Lstat(path); err == nil {
+			return nil // Earlier entries take precedence
+		}
+		return os.Symlink(target, path)
+	}
+
+	ini := strings.Join(phpIniSettings(cfg), "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "php.ini"), []byte(ini), 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	if err := link("php", cfg.PHPBinary); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	if composerPhar != "" {
+		// The cached phar is not executable, so run it through php
+		wrapper := fmt.Sprintf("#!/bin/sh\nexec %s -n %s \"$@\"\n", ShellEscape(filepath.Join(dir, "php")), ShellEscape(composerPhar))
+		if err := os.WriteFile(filepath.Join(dir, "composer"), []byte(wrapper), 0755); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	for _, binDir := range binDirs {
+		entries, err := os.ReadDir(binDir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || e.Name() == "php.ini" {
+				continue
+			}
+			if err := link(e.Name(), filepath.Join(binDir, e.Name())); err != nil {
+				_ = os.RemoveAll(dir)
+				return "", err
+			}
+		}
+	}
+
+	return dir, nil
+}
+
+// withBinDir puts cfg.BinDir first on PATH in env and points PHPRC at its
+// php.ini. env is returned unchanged when there is no bin directory.
+func withBinDir(env []string, cfg *Config) []string {
+	if cfg.BinDir == "" {
+		return env
+	}
+
+	path := cfg.BinDir
+	out := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "PATH="):
+			if existing := strings.TrimPrefix(kv, "PATH="); existing != "" {
+				path += string(os.PathListSeparator) + existing
+			}
+		case strings.HasPrefix(kv, "PHPRC="):
+		default:
+			out = append(out, kv)
+		}
+	}
+
+	return append(out, "PATH="+path, "PHPRC="+cfg.BinDir)
+}
@@ -0,0 +1,88 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrepareBinDir(t *testing.T) {
+	toolBin := t.TempDir()
+	for _, name := range []string{"phpstan", "php"} {
+		if err := os.WriteFile(filepath.Join(toolBin, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &Config{PHPBinary: "/opt/php/bin/php", MemoryMB: 128, AutoloadFile: "/deps/vendor/autoload.php"}
+
+	dir, err := PrepareBinDir(cfg, "/opt/composer.phar", toolBin)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	links := map[string]string{
+		"php":     "/opt/php/bin/php",
+		"phpstan": filepath.Join(toolBin, "phpstan"),
+	}
+	for name, want := range links {
+		if got, err := os.Readlink(filepath.Join(dir, name)); err != nil || got != want {
+			t.Errorf("%s -> %q (%v), want %q", name, got, err, want)
+		}
+	}
+
+	ini, err := os.ReadFile(filepath.Join(dir, "php.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "memory_limit=128M\n"
+	if string(ini) != want {
+		t.Errorf("php.ini = %q, want %q", ini, want)
+	}
+}
+
+func TestPrepareBinDir_runs_composer(t *testing.T) {
+	tmp := t.TempDir()
+	// Stands in for PHP, printing the arguments it was run with
+	php := filepath.Join(tmp, "php")
+	if err := os.WriteFile(php, []byte("#!/bin/sh\nprintf '%s\\n' \"$*\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Cached phars are not executable
+	phar := filepath.Join(tmp, "composer's.phar")
+	if err := os.WriteFile(phar, []byte("<?php"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := PrepareBinDir(&Config{PHPBinary: php}, phar)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	out, err := exec.Command(filepath.Join(dir, "composer"), "install", "--no-dev").Output()
+	if err != nil {
+		t.Fatalf("running composer: %v", err)
+	}
+	if want := "-n " + phar + " install --no-dev\n"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestWithBinDir(t *testing.T) {
+	cfg := &Config{BinDir: "/tmp/phpx-bin-1"}
+
+	env := withBinDir([]string{"HOME=/home/me", "PATH=/usr/bin:/bin", "PHPRC=/etc/php"}, cfg)
+
+	want := []string{"HOME=/home/me", "PATH=/tmp/phpx-bin-1" + string(os.PathListSeparator) + "/usr/bin:/bin", "PHPRC=/tmp/phpx-bin-1"}
+	if !slices.Equal(env, want) {
+		t.Errorf("got %v, want %v", env, want)
+	}
+
+	if got := withBinDir([]string{"PATH=/bin"}, &Config{}); strings.Join(got, ",") != "PATH=/bin" {
+		t.Errorf("without a bin directory: got %v, want env unchanged", got)
+	}
+}
@@ -108,6 +108,20 @@ func (b *Bubblewrap) buildArgs(cfg *Config) []string {
 		args = append(args, "--ro-bind", cfg.ScriptPath, cfg.ScriptPath)
 	}
 
+	// ============================================================
+	// SHEBANG INTERPRETER (with system libraries and utilities)
+	// ============================================================
+	for _, p := range interpreterPaths(cfg) {
+		args = append(args, "--ro-bind", p, p)
+	}
+
+	// ============================================================
+	// BIN DIRECTORY (php, composer and tool binaries on PATH)
+	// ============================================================
+	if cfg.BinDir != "" {
+		args = append(args, "--ro-bind", cfg.BinDir, cfg.BinDir)
+	}
+
 	// ============================================================
 	// VENDOR DIRECTORY (for dependencies)
 	// ============================================================
@@ -134,6 +148,16 @@ func (b *Bubblewrap) buildArgs(cfg *Config) []string {
 		}
 	}
 
+	// ============================================================
+	// READ-ONLY PATHS (e.g. a project's vendor directory)
+	// Mounted last, so they stay read-only inside writable paths
+	// ============================================================
+	for _, p := range cfg.ReadOnlyPaths {
+		if _, err := os.Stat(p); err == nil {
+			args = append(args, "--ro-bind", p, p)
+		}
+	}
+
 	// ============================================================
 	// PROXY SOCKET (for network access)
 	// ============================================================
@@ -156,6 +180,9 @@ func (b *Bubblewrap) buildArgs(cfg *Config) []string {
 
 	// Working directory (if specified, just set chdir - no mount means no access)
 	if cfg.WorkDir != "" {
+		if !workDirExists(cfg) {
+			args = append(args, "--dir", cfg.WorkDir) // Empty, so chdir succeeds
+		}
 		args = append(args, "--chdir", cfg.WorkDir)
 	}
 
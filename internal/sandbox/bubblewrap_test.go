@@ -0,0 +1,76 @@
+package sandbox
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBubblewrap_mounts_read_only_paths_after_writable_paths(t *testing.T) {
+	dir := t.TempDir()
+	vendor := t.TempDir()
+	cfg := &Config{
+		PHPBinary:     "/usr/bin/php",
+		WritablePaths: []string{dir},
+		ReadOnlyPaths: []string{vendor},
+	}
+
+	args := (&Bubblewrap{}).buildArgs(cfg)
+
+	writable := slices.Index(args, "--bind")
+	readOnly := slices.Index(args, vendor)
+	if writable == -1 || readOnly == -1 || args[readOnly-1] != "--ro-bind" {
+		t.Fatalf("missing mounts in %v", args)
+	}
+	if readOnly < writable {
+		t.Errorf("read-only mount precedes writable mount: %v", args)
+	}
+}
+
+func TestBubblewrap_runs_scripts_with_their_interpreter(t *testing.T) {
+	cfg := &Config{
+		PHPBinary:   "/usr/bin/php",
+		ScriptPath:  "/tools/vendor/bin/tool",
+		ScriptArgs:  []string{"--check"},
+		Interpreter: []string{"/bin/sh", "-e"},
+	}
+
+	args := (&Bubblewrap{}).buildArgs(cfg)
+
+	mount := slices.Index(args, "/bin/sh")
+	if mount == -1 || args[mount-1] != "--ro-bind" {
+		t.Errorf("interpreter not mounted: %v", args)
+	}
+	sep := slices.Index(args, "--")
+	want := []string{"/bin/sh", "-e", "/tools/vendor/bin/tool", "--check"}
+	if sep == -1 || !slices.Equal(args[sep+1:], want) {
+		t.Errorf("got command %v, want %v", args[sep+1:], want)
+	}
+}
+
+func TestBubblewrap_creates_unmounted_working_directory(t *testing.T) {
+	cwd := t.TempDir()
+	script := filepath.Join(t.TempDir(), "eval.php")
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantDir bool
+	}{
+		{"script directory", &Config{ScriptPath: script, WorkDir: filepath.Dir(script)}, false},
+		{"writable directory", &Config{ScriptPath: script, WorkDir: cwd, WritablePaths: []string{cwd}}, false},
+		{"inside a readable directory", &Config{ScriptPath: script, WorkDir: filepath.Join(cwd, "sub"), ReadablePaths: []string{cwd}}, false},
+		{"unmounted directory", &Config{ScriptPath: script, WorkDir: cwd}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := (&Bubblewrap{}).buildArgs(tt.cfg)
+
+			i := slices.Index(args, "--dir")
+			if got := i != -1 && args[i+1] == tt.cfg.WorkDir; got != tt.wantDir {
+				t.Errorf("--dir %s = %v, want %v: %v", tt.cfg.WorkDir, got, tt.wantDir, args)
+			}
+		})
+	}
+}
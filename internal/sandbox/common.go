@@ -3,7 +3,10 @@ package sandbox
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,18 +20,22 @@ func ShellEscape(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
 }
 
-// BuildPHPArgs constructs PHP command arguments from config.
+// BuildPHPArgs constructs PHP command arguments from config. Scripts with
+// an Interpreter run with it instead, and find PHP's ini settings through
+// the bin directory's php.ini.
 func BuildPHPArgs(cfg *Config) []string {
+	if len(cfg.Interpreter) > 0 {
+		args := append(slices.Clone(cfg.Interpreter), cfg.ScriptPath)
+		return append(args, cfg.ScriptArgs...)
+	}
+
 	args := []string{cfg.PHPBinary}
 
-	if cfg.MemoryMB > 0 {
-		args = append(args, "-d", fmt.Sprintf("memory_limit=%dM", cfg.MemoryMB))
-	}
-	if cfg.CPUSeconds > 0 {
-		args = append(args, "-d", fmt.Sprintf("max_execution_time=%d", cfg.CPUSeconds))
+	for _, setting := range phpIniSettings(cfg) {
+		args = append(args, "-d", setting)
 	}
 	if cfg.AutoloadFile != "" {
-		args = append(args, "-d", fmt.Sprintf("auto_prepend_file=%s", cfg.AutoloadFile))
+		args = append(args, "-d", "auto_prepend_file="+cfg.AutoloadFile)
 	}
 
 	args = append(args, cfg.ScriptPath)
@@ -37,6 +44,70 @@ func BuildPHPArgs(cfg *Config) []string {
 	return args
 }
 
+// phpIniSettings returns the resource limits PHP runs with, as name=value.
+// Child processes get them too, through the bin directory's php.ini; the
+// script's autoloader is only prepended to the main process.
+func phpIniSettings(cfg *Config) []string {
+	var settings []string
+	if cfg.MemoryMB > 0 {
+		settings = append(settings, fmt.Sprintf("memory_limit=%dM", cfg.MemoryMB))
+	}
+	if cfg.CPUSeconds > 0 {
+		settings = append(settings, fmt.Sprintf("max_execution_time=%d", cfg.CPUSeconds))
+	}
+	return settings
+}
+
+// workDirExists reports whether cfg.WorkDir exists inside a sandbox that
+// only mounts the paths in cfg: it is one of them, inside a mounted
+// directory, or a parent directory created for a mount.
+func workDirExists(cfg *Config) bool {
+	mounts := []string{cfg.PHPBinary, cfg.ScriptPath, cfg.BinDir}
+	if cfg.AutoloadFile != "" {
+		mounts = append(mounts, filepath.Dir(cfg.AutoloadFile))
+	}
+	mounts = append(mounts, cfg.ReadablePaths...)
+	mounts = append(mounts, cfg.WritablePaths...)
+	mounts = append(mounts, cfg.ReadOnlyPaths...)
+	mounts = append(mounts, interpreterPaths(cfg)...)
+
+	sep := string(filepath.Separator)
+	for _, m := range mounts {
+		if m == "" {
+			continue
+		}
+		if m == cfg.WorkDir || strings.HasPrefix(m, strings.TrimSuffix(cfg.WorkDir, sep)+sep) {
+			return true
+		}
+		if strings.HasPrefix(cfg.WorkDir, m+sep) {
+			if info, err := os.Stat(m); err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// systemPaths are the host directories a shebang interpreter may need
+// inside a sandbox: its shared libraries and the utilities scripts call.
+var systemPaths = []string{"/bin", "/usr/bin", "/lib", "/lib64", "/usr/lib", "/usr/lib64", "/etc/ld.so.cache"}
+
+// interpreterPaths returns the paths to mount read-only for cfg.Interpreter:
+// the interpreter itself and the system paths present on this host.
+func interpreterPaths(cfg *Config) []string {
+	if len(cfg.Interpreter) == 0 {
+		return nil
+	}
+
+	paths := []string{cfg.Interpreter[0]}
+	for _, p := range systemPaths {
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
 // BuildSocatBridgeCommand creates a shell command that starts socat to bridge
 // localhost traffic to a Unix socket, then runs the given command.
 // Uses a retry loop instead of sleep to avoid race conditions.
@@ -62,7 +133,7 @@ exit $EXIT_CODE`,
 // BuildPHPCommand constructs an escaped PHP command string from config.
 func BuildPHPCommand(cfg *Config) string {
 	phpArgs := BuildPHPArgs(cfg)
-	phpCmd := ShellEscape(cfg.PHPBinary)
+	phpCmd := ShellEscape(phpArgs[0])
 	for _, arg := range phpArgs[1:] {
 		phpCmd += " " + ShellEscape(arg)
 	}
@@ -110,7 +181,7 @@ func SetupCommand(cmd *exec.Cmd, cfg *Config) (*bytes.Buffer, *bytes.Buffer) {
 	// Build environment - use filtered safelist plus explicitly allowed vars
 	env := util.FilterEnv(cfg.AllowedEnvVars)
 	env = append(env, cfg.Env...)
-	cmd.Env = env
+	cmd.Env = withBinDir(env, cfg)
 
 	return &stdout, &stderr
 }
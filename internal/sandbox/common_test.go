@@ -25,6 +25,23 @@ func TestBuildPHPArgs_basic(t *testing.T) {
 	}
 }
 
+func TestBuildPHPArgs_with_interpreter(t *testing.T) {
+	cfg := &Config{
+		PHPBinary:   "/usr/bin/php",
+		MemoryMB:    128,
+		ScriptPath:  "/tools/vendor/bin/tool",
+		ScriptArgs:  []string{"arg1"},
+		Interpreter: []string{"/bin/bash", "-e"},
+	}
+
+	args := BuildPHPArgs(cfg)
+
+	want := []string{"/bin/bash", "-e", "/tools/vendor/bin/tool", "arg1"}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
 func TestBuildPHPArgs_with_memory_limit(t *testing.T) {
 	cfg := &Config{
 		PHPBinary:  "/usr/bin/php",
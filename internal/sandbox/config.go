@@ -17,6 +17,7 @@ type Config struct {
 	// Filesystem settings
 	ReadablePaths []string // Additional paths to allow reading
 	WritablePaths []string // Additional paths to allow writing
+	ReadOnlyPaths []string // Paths to allow reading but never writing, even inside a writable path
 	WorkDir       string   // Working directory
 
 	// Resource limits
@@ -29,6 +30,8 @@ type Config struct {
 	AutoloadFile string   // Path to autoload.php
 	ScriptPath   string   // Path to script to execute
 	ScriptArgs   []string // Arguments to pass to script
+	Interpreter  []string // Runs ScriptPath instead of PHP, e.g. a shebang script's interpreter
+	BinDir       string   // Directory put first on PATH (see PrepareBinDir)
 
 	// Environment
 	Env            []string // Environment variables to pass (proxy vars, etc.)
@@ -136,6 +136,16 @@ func (m *MacOS) generateProfile(cfg *Config) string {
 		profile.WriteString(fmt.Sprintf("(allow file-read* (literal \"%s\"))\n\n", seatbeltEscape(resolvePath(cfg.PHPBinary))))
 	}
 
+	// Shebang interpreter, with the system libraries and utilities it needs
+	if len(cfg.Interpreter) > 0 {
+		profile.WriteString(";; Shebang interpreter\n")
+		profile.WriteString(fmt.Sprintf("(allow file-read* (literal \"%s\"))\n", seatbeltEscape(resolvePath(cfg.Interpreter[0]))))
+		for _, p := range []string{"/bin", "/usr/bin", "/usr/lib", "/System/Library", "/System/Cryptexes"} {
+			profile.WriteString(fmt.Sprintf("(allow file-read* (subpath \"%s\"))\n", p))
+		}
+		profile.WriteString("\n")
+	}
+
 	// Script file (exact path only)
 	if cfg.ScriptPath != "" {
 		profile.WriteString(";; Script file\n")
@@ -149,6 +159,12 @@ func (m *MacOS) generateProfile(cfg *Config) string {
 		profile.WriteString(fmt.Sprintf("(allow file-read* (subpath \"%s\"))\n\n", seatbeltEscape(resolvePath(vendorDir))))
 	}
 
+	// Bin directory put first on PATH (php, composer and tool binaries)
+	if cfg.BinDir != "" {
+		profile.WriteString(";; Bin directory on PATH\n")
+		profile.WriteString(fmt.Sprintf("(allow file-read* (subpath \"%s\"))\n\n", seatbeltEscape(resolvePath(cfg.BinDir))))
+	}
+
 	// Additional readable paths from --allow-read flag
 	if len(cfg.ReadablePaths) > 0 {
 		profile.WriteString(";; Additional readable paths (--allow-read)\n")
@@ -186,6 +202,21 @@ func (m *MacOS) generateProfile(cfg *Config) string {
 		profile.WriteString("\n")
 	}
 
+	// Read-only paths, e.g. a project's vendor directory. Later rules take
+	// precedence, so these stay read-only inside writable paths.
+	if len(cfg.ReadOnlyPaths) > 0 {
+		profile.WriteString(";; Read-only paths\n")
+		for _, p := range cfg.ReadOnlyPaths {
+			resolved := resolvePath(p)
+			if p != resolved {
+				profile.WriteString(fmt.Sprintf("(allow file-read* (literal \"%s\"))\n", seatbeltEscape(p)))
+			}
+			profile.WriteString(fmt.Sprintf("(allow file-read* (subpath \"%s\"))\n", seatbeltEscape(resolved)))
+			profile.WriteString(fmt.Sprintf("(deny file-write* (subpath \"%s\"))\n", seatbeltEscape(resolved)))
+		}
+		profile.WriteString("\n")
+	}
+
 	// ============================================================
 	// NETWORK ACCESS
 	// ============================================================
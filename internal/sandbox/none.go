@@ -49,8 +49,8 @@ func (n *None) Execute(ctx context.Context, cfg *Config) (*Result, error) {
 		cmd.Stderr = &stderr
 	}
 
-	// Inherit full environment from parent
-	cmd.Env = os.Environ()
+	// Inherit full environment from parent, plus any variables set for the run
+	cmd.Env = withBinDir(append(os.Environ(), cfg.Env...), cfg)
 
 	err := cmd.Run()
 	return BuildResult(err, cfg, &stdout, &stderr)
@@ -112,6 +112,13 @@ func (n *Nsjail) buildArgs(cfg *Config) []string {
 		args = append(args, "--bindmount_ro", cfg.ScriptPath+":"+cfg.ScriptPath)
 	}
 
+	// ============================================================
+	// SHEBANG INTERPRETER (with system libraries and utilities)
+	// ============================================================
+	for _, p := range interpreterPaths(cfg) {
+		args = append(args, "--bindmount_ro", p+":"+p)
+	}
+
 	// ============================================================
 	// VENDOR DIRECTORY (for dependencies)
 	// ============================================================
@@ -120,6 +127,13 @@ func (n *Nsjail) buildArgs(cfg *Config) []string {
 		args = append(args, "--bindmount_ro", vendorDir+":"+vendorDir)
 	}
 
+	// ============================================================
+	// BIN DIRECTORY (php, composer and tool binaries on PATH)
+	// ============================================================
+	if cfg.BinDir != "" {
+		args = append(args, "--bindmount_ro", cfg.BinDir+":"+cfg.BinDir)
+	}
+
 	// ============================================================
 	// ADDITIONAL READABLE PATHS (--allow-read)
 	// ============================================================
@@ -134,27 +148,25 @@ func (n *Nsjail) buildArgs(cfg *Config) []string {
 		args = append(args, "--bindmount", p+":"+p)
 	}
 
+	// ============================================================
+	// READ-ONLY PATHS (e.g. a project's vendor directory)
+	// Mounted last, so they stay read-only inside writable paths
+	// ============================================================
+	for _, p := range cfg.ReadOnlyPaths {
+		args = append(args, "--bindmount_ro", p+":"+p)
+	}
+
 	// Working directory (just set cwd, no mount = no access by default)
 	if cfg.WorkDir != "" {
+		if !workDirExists(cfg) {
+			args = append(args, "--tmpfsmount", cfg.WorkDir) // Empty, so chdir succeeds
+		}
 		args = append(args, "--cwd", cfg.WorkDir)
 	}
 
-	// PHP command
-	args = append(args, "--", cfg.PHPBinary)
-
-	// PHP options
-	if cfg.MemoryMB > 0 {
-		args = append(args, "-d", fmt.Sprintf("memory_limit=%dM", cfg.MemoryMB))
-	}
-	if cfg.CPUSeconds > 0 {
-		args = append(args, "-d", fmt.Sprintf("max_execution_time=%d", cfg.CPUSeconds))
-	}
-	if cfg.AutoloadFile != "" {
-		args = append(args, "-d", fmt.Sprintf("auto_prepend_file=%s", cfg.AutoloadFile))
-	}
-
-	args = append(args, cfg.ScriptPath)
-	args = append(args, cfg.ScriptArgs...)
+	// PHP command, or the script's interpreter
+	args = append(args, "--")
+	args = append(args, BuildPHPArgs(cfg)...)
 
 	return args
 }
@@ -0,0 +1,268 @@
+// Package shim manages persistently installed tools: a receipt recording how
+// each tool was installed, and a shim script on PATH that runs it through
+// phpx with the versions pinned at install time.
+package shim
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eddmann/phpx/internal/cache"
+)
+
+// EnvBinDir overrides the directory shims are written to.
+const EnvBinDir = "PHPX_BIN_DIR"
+
+// marker identifies shim scripts written by phpx, so other files are never
+// overwritten or removed.
+const marker = "# phpx tool shim"
+
+// ErrNotInstalled is returned by Load for tools that are not installed.
+var ErrNotInstalled = errors.New("not installed")
+
+// Receipt records an installed tool.
+type Receipt struct {
+	Name         string          `json:"name"`              // Shim name, the tool's binary
+	Spec         string          `json:"spec"`              // Tool argument as given, e.g. phpstan:^1.11
+	Package      string          `json:"package"`           // Resolved package name
+	Version      string          `json:"version,omitempty"` // Resolved version; empty for sources and PHARs
+	Binary       string          `json:"binary"`
+	PHP          string          `json:"php,omitempty"` // PHP constraint as given
+	PHPVersion   string          `json:"php_version"`   // PHP version the tool resolved to
+	Extensions   []string        `json:"extensions,omitempty"`
+	With         []string        `json:"with,omitempty"`
+	AllowPlugins map[string]bool `json:"allow_plugins,omitempty"`
+	AllowScripts bool            `json:"allow_scripts,omitempty"`
+	Shim         string          `json:"shim"` // Path of the shim script
+	InstalledAt  time.Time       `json:"installed_at"`
+}
+
+// BinDir returns the directory shims are written to: $PHPX_BIN_DIR, or
+// ~/.phpx/bin.
+func BinDir() (string, error) {
+	if dir := os.Getenv(EnvBinDir); dir != "" {
+		return filepath.Abs(dir)
+	}
+	return cache.BinDir()
+}
+
+// receiptPath returns the path of a tool's receipt.
+func receiptPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid tool name: %q", name)
+	}
+
+	dir, err := cache.InstalledDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Load reads the receipt of an installed tool.
+func Load(name string) (*Receipt, error) {
+	path, err := receiptPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s is %w", name, ErrNotInstalled)
+		}
+		return nil, err
+	}
+
+	var r Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid receipt for %s: %w", name, err)
+	}
+	return &r, nil
+}
+
+// List returns the receipts of all installed tools, sorted by name.
+func List() ([]*Receipt, error) {
+	dir, err := cache.InstalledDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var receipts []*Receipt
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		r, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].Name < receipts[j].Name
+	})
+	return receipts, nil
+}
+
+// Save writes the receipt of an installed tool.
+func Save(r *Receipt) error {
+	path, err := receiptPath(r.Name)
+	if err != nil {
+		return err
+	}
+	if err := cache.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Remove deletes an installed tool's shim and receipt. The cached tool
+// installation itself is left for 'phpx cache clean'.
+func Remove(r *Receipt) error {
+	if IsShim(r.Shim) {
+		if err := os.Remove(r.Shim); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	path, err := receiptPath(r.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Args returns the phpx arguments a shim runs the tool with: the pinned
+// package version, binary and PHP version.
+func Args(r *Receipt) []string {
+	args := []string{"tool", "--quiet"}
+	if r.PHPVersion != "" {
+		args = append(args, "--php", r.PHPVersion)
+	}
+	if len(r.Extensions) > 0 {
+		args = append(args, "--extensions", strings.Join(r.Extensions, ","))
+	}
+	args = append(args, "--from", r.Binary)
+	for _, pkg := range r.With {
+		args = append(args, "--with", pkg)
+	}
+
+	plugins := make([]string, 0, len(r.AllowPlugins))
+	for name := range r.AllowPlugins {
+		plugins = append(plugins, name)
+	}
+	sort.Strings(plugins)
+	for _, name := range plugins {
+		if r.AllowPlugins[name] {
+			args = append(args, "--allow-plugin", name)
+		} else {
+			args = append(args, "--allow-plugin", name+"=false")
+		}
+	}
+	if r.AllowScripts {
+		args = append(args, "--allow-scripts")
+	}
+
+	// Sources and PHARs are run as given; their cache keys pin the content
+	if r.Version != "" {
+		args = append(args, r.Package+"@"+r.Version)
+	} else {
+		args = append(args, r.Spec)
+	}
+
+	return append(args, "--")
+}
+
+// Script returns the shim script running the tool through the phpx binary.
+func Script(phpx string, r *Receipt) string {
+	label := r.Package
+	if r.Version != "" {
+		label += " " + r.Version
+	}
+
+	quoted := make([]string, 0, len(Args(r))+1)
+	quoted = append(quoted, shellQuote(phpx))
+	for _, arg := range Args(r) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return fmt.Sprintf("#!/bin/sh\n%s for %s, managed by 'phpx tool install'.\nexec %s \"$@\"\n",
+		marker, label, strings.Join(quoted, " "))
+}
+
+// Write writes the shim script for an installed tool to r.Shim.
+func Write(phpx string, r *Receipt) error {
+	if err := cache.EnsureDir(filepath.Dir(r.Shim)); err != nil {
+		return err
+	}
+
+	// Write beside the shim and rename, so a running shim is never truncated
+	tmp := r.Shim + ".tmp"
+	if err := os.WriteFile(tmp, []byte(Script(phpx, r)), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.Shim)
+}
+
+// IsShim reports whether path is a shim script written by phpx.
+func IsShim(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 2 && scanner.Scan(); i++ {
+		if strings.HasPrefix(scanner.Text(), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// InPath reports whether dir is listed in $PATH.
+func InPath(dir string) bool {
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,155 @@
+package shim
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		receipt Receipt
+		want    []string
+	}{
+		{
+			name: "pins the resolved package and PHP versions",
+			receipt: Receipt{
+				Spec: "phpstan@^1.11", Package: "phpstan/phpstan", Version: "1.11.5",
+				Binary: "phpstan", PHPVersion: "8.3.10",
+			},
+			want: []string{"tool", "--quiet", "--php", "8.3.10", "--from", "phpstan", "phpstan/phpstan@1.11.5", "--"},
+		},
+		{
+			name: "passes install options",
+			receipt: Receipt{
+				Spec: "phpstan", Package: "phpstan/phpstan", Version: "1.11.5", Binary: "phpstan",
+				PHPVersion: "8.3.10", Extensions: []string{"intl"},
+				With:         []string{"phpstan/extension-installer"},
+				AllowPlugins: map[string]bool{"phpstan/extension-installer": true, "acme/*": false},
+				AllowScripts: true,
+			},
+			want: []string{
+				"tool", "--quiet", "--php", "8.3.10", "--extensions", "intl", "--from", "phpstan",
+				"--with", "phpstan/extension-installer",
+				"--allow-plugin", "acme/*=false", "--allow-plugin", "phpstan/extension-installer",
+				"--allow-scripts", "phpstan/phpstan@1.11.5", "--",
+			},
+		},
+		{
+			name: "runs sources as given",
+			receipt: Receipt{
+				Spec: "git+https://github.com/acme/tool@main", Package: "acme/tool",
+				Binary: "tool", PHPVersion: "8.3.10",
+			},
+			want: []string{"tool", "--quiet", "--php", "8.3.10", "--from", "tool", "git+https://github.com/acme/tool@main", "--"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Args(&tt.receipt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScript(t *testing.T) {
+	r := &Receipt{Package: "phpstan/phpstan", Version: "1.11.5", Binary: "phpstan", PHPVersion: "8.3.10"}
+
+	got := Script("/opt/my tools/phpx", r)
+
+	want := "exec '/opt/my tools/phpx' tool --quiet --php 8.3.10 --from phpstan phpstan/phpstan@1.11.5 -- \"$@\"\n"
+	if !strings.HasPrefix(got, "#!/bin/sh\n") || !strings.HasSuffix(got, want) {
+		t.Errorf("got:\n%s\nwant script ending in:\n%s", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"phpstan/phpstan@1.11.5", "phpstan/phpstan@1.11.5"},
+		{"acme/*", "'acme/*'"},
+		{"~/bin", "'~/bin'"},
+		{"it's", `'it'\''s'`},
+		{"", "''"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := shellQuote(tt.in); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceipts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bin := t.TempDir()
+
+	phpstan := &Receipt{Name: "phpstan", Spec: "phpstan", Package: "phpstan/phpstan", Version: "1.11.5", Binary: "phpstan", PHPVersion: "8.3.10", Shim: filepath.Join(bin, "phpstan")}
+	pint := &Receipt{Name: "pint", Spec: "pint", Package: "laravel/pint", Version: "1.17.0", Binary: "pint", PHPVersion: "8.3.10", Shim: filepath.Join(bin, "pint")}
+
+	for _, r := range []*Receipt{pint, phpstan} {
+		if err := Write("/usr/local/bin/phpx", r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := Save(r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	list, err := List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "phpstan" || list[1].Name != "pint" {
+		t.Fatalf("List = %+v, want phpstan and pint", list)
+	}
+	if !IsShim(phpstan.Shim) {
+		t.Error("IsShim = false for a written shim")
+	}
+
+	if err := Remove(phpstan); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(phpstan.Shim); !os.IsNotExist(err) {
+		t.Error("shim not removed")
+	}
+	if _, err := Load("phpstan"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Load after Remove: got %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestRemove_keeps_foreign_files(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "phpstan")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho mine\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Remove(&Receipt{Name: "phpstan", Shim: path}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Error("removed a file not written by phpx")
+	}
+}
+
+func TestLoad_rejects_invalid_names(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, name := range []string{"", "../phpstan", ".hidden"} {
+		if _, err := Load(name); err == nil || errors.Is(err, ErrNotInstalled) {
+			t.Errorf("Load(%q): got %v, want invalid name error", name, err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package util
+
+import "strings"
+
+// PHPString quotes s as a single-quoted PHP string literal.
+func PHPString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,23 @@
+package util
+
+import "testing"
+
+func TestPHPString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "phar://app.phar/index.php", want: `'phar://app.phar/index.php'`},
+		{name: "quotes", in: "it's", want: `'it\'s'`},
+		{name: "backslashes", in: `C:\app\`, want: `'C:\\app\\'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PHPString(tt.in); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
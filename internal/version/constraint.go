@@ -0,0 +1,464 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/eddmann/phpx/internal/util"
+)
+
+// Constraint matches normalized versions.
+type Constraint interface {
+	// Matches reports whether the normalized version satisfies the constraint.
+	Matches(normalized string) bool
+	String() string
+	// Compile returns a PHP expression that is true when the normalized
+	// version held in the named variable satisfies the constraint, like
+	// Composer's compiled constraints.
+	Compile(variable string) string
+}
+
+// single compares against one version with an operator.
+type single struct {
+	op      string
+	version string
+}
+
+// multi combines constraints with AND (conjunctive) or OR.
+type multi struct {
+	constraints []Constraint
+	conjunctive bool
+}
+
+// matchAll matches any version, as produced by "*".
+type matchAll struct{}
+
+// versionPattern captures major, minor, patch and build numbers followed by
+// the stability modifier groups (5: stability, 6: its number, 7: dev).
+const versionPattern = `v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.(\d+))?` + modifierRegex + `(?:\+[^\s]+)?`
+
+var (
+	orSplitRegex      = regexp.MustCompile(`\s*\|\|?\s*`)
+	operatorOnlyRegex = regexp.MustCompile(`^(<>|!=|>=?|<=?|==?)$`)
+	constraintFlag    = regexp.MustCompile(`(?i)^([^,\s]*?)@(stable|RC|beta|alpha|dev)$`)
+	refSuffixRegex    = regexp.MustCompile(`(?i)^(dev-[^,\s@]+?|[^,\s@]+?\.x-dev)#.+$`)
+	wildcardRegex     = regexp.MustCompile(`(?i)^(v)?[xX*](\.[xX*])*$`)
+	fullVersionRegex  = regexp.MustCompile(`(?i)^` + versionPattern + `$`)
+	xRangeRegex       = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.[xX*])+$`)
+	hyphenRegex       = regexp.MustCompile(`^(\S+) +- +(\S+)$`)
+	basicRegex        = regexp.MustCompile(`^(<>|!=|>=?|<=?|==?)?\s*(.*)`)
+	modifierEndRegex  = regexp.MustCompile(`-` + modifierRegex + `$`)
+	branchNameRegex   = regexp.MustCompile(`^[0-9a-zA-Z-./]+$`)
+	explicitFlagRegex = regexp.MustCompile(`(?i)^[^@]*?@(stable|RC|beta|alpha|dev)$`)
+	aliasedRegex      = regexp.MustCompile(`^([^,\s@]+) as .+$`)
+	bareVersionRegex  = regexp.MustCompile(`^[^,\s@]+$`)
+)
+
+// ParseConstraint parses a Composer constraint such as "^1.2 || dev-main",
+// ">=2.0-stable <3" or "1.0.*@beta".
+func ParseConstraint(constraint string) (Constraint, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" {
+		return nil, fmt.Errorf("invalid constraint: empty string")
+	}
+
+	var orGroups []Constraint
+	for _, orPart := range orSplitRegex.Split(constraint, -1) {
+		tokens := splitAnd(orPart)
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q", constraint)
+		}
+
+		var and []Constraint
+		for _, token := range tokens {
+			parsed, err := parseSingle(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+			}
+			and = append(and, parsed...)
+		}
+
+		if len(and) == 1 {
+			orGroups = append(orGroups, and[0])
+		} else {
+			orGroups = append(orGroups, &multi{constraints: and, conjunctive: true})
+		}
+	}
+
+	if len(orGroups) == 1 {
+		return orGroups[0], nil
+	}
+	return &multi{constraints: orGroups}, nil
+}
+
+// splitAnd splits an OR branch into its AND-ed parts. Hyphen ranges, aliases
+// and operators separated from their version by a space stay together.
+func splitAnd(constraint string) []string {
+	fields := strings.FieldsFunc(constraint, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case (field == "-" || field == "as") && len(tokens) > 0 && i+1 < len(fields):
+			tokens[len(tokens)-1] += " " + field + " " + fields[i+1]
+			i++
+		case operatorOnlyRegex.MatchString(field) && i+1 < len(fields):
+			tokens = append(tokens, field+fields[i+1])
+			i++
+		default:
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+// parseSingle parses one AND-ed part into the constraints it expands to,
+// following Composer's VersionParser::parseConstraint.
+func parseSingle(constraint string) ([]Constraint, error) {
+	// Strip off aliasing
+	if m := aliasRegex.FindStringSubmatch(constraint); m != nil {
+		constraint = m[1]
+	}
+
+	// Strip off the stability flag, which lowers the bound of comparisons
+	stabilityModifier := ""
+	if m := constraintFlag.FindStringSubmatch(constraint); m != nil {
+		constraint = m[1]
+		if constraint == "" {
+			constraint = "*"
+		}
+		if m[2] != "stable" {
+			stabilityModifier = m[2]
+		}
+	}
+
+	// Strip off a pinned source reference
+	if m := refSuffixRegex.FindStringSubmatch(constraint); m != nil {
+		constraint = m[1]
+	}
+
+	if m := wildcardRegex.FindStringSubmatch(constraint); m != nil {
+		if m[1] != "" {
+			return []Constraint{&single{op: ">=", version: "0.0.0.0-dev"}}, nil
+		}
+		return []Constraint{matchAll{}}, nil
+	}
+
+	// Tilde: ~1.2 means >=1.2 <2.0, ~1.2.3 means >=1.2.3 <1.3
+	if strings.HasPrefix(constraint, "~") {
+		rest := strings.TrimPrefix(constraint, "~")
+		if m := fullVersionRegex.FindStringSubmatch(strings.TrimPrefix(rest, ">")); m != nil {
+			if strings.HasPrefix(rest, ">") {
+				return nil, fmt.Errorf("%q is not valid, use ~ instead of ~>", constraint)
+			}
+
+			position := 1
+			for i := 4; i > 1; i-- {
+				if m[i] != "" {
+					position = i
+					break
+				}
+			}
+
+			low, err := Normalize(rest + devSuffix(m))
+			if err != nil {
+				return nil, err
+			}
+			high := manipulateVersion(m, max(1, position-1), 1) + "-dev"
+
+			return []Constraint{
+				&single{op: ">=", version: low},
+				&single{op: "<", version: high},
+			}, nil
+		}
+	}
+
+	// Caret: ^1.2.3 means >=1.2.3 <2.0, ^0.3 means >=0.3 <0.4
+	if strings.HasPrefix(constraint, "^") {
+		rest := constraint[1:]
+		if m := fullVersionRegex.FindStringSubmatch(rest); m != nil {
+			position := 3
+			switch {
+			case m[1] != "0" || m[2] == "":
+				position = 1
+			case m[2] != "0" || m[3] == "":
+				position = 2
+			}
+
+			low, err := Normalize(rest + devSuffix(m))
+			if err != nil {
+				return nil, err
+			}
+			high := manipulateVersion(m, position, 1) + "-dev"
+
+			return []Constraint{
+				&single{op: ">=", version: low},
+				&single{op: "<", version: high},
+			}, nil
+		}
+	}
+
+	// X-range: 1.2.* means >=1.2 <1.3
+	if m := xRangeRegex.FindStringSubmatch(constraint); m != nil {
+		position := 1
+		switch {
+		case m[3] != "":
+			position = 3
+		case m[2] != "":
+			position = 2
+		}
+
+		low := manipulateVersion(m, position, 0) + "-dev"
+		high := manipulateVersion(m, position, 1) + "-dev"
+
+		if low == "0.0.0.0-dev" {
+			return []Constraint{&single{op: "<", version: high}}, nil
+		}
+		return []Constraint{
+			&single{op: ">=", version: low},
+			&single{op: "<", version: high},
+		}, nil
+	}
+
+	// Hyphen range: 1.0 - 2.0 means >=1.0 <2.1, 1.0.0 - 2.1.0 means >=1.0.0 <=2.1.0
+	if m := hyphenRegex.FindStringSubmatch(constraint); m != nil {
+		from := fullVersionRegex.FindStringSubmatch(m[1])
+		to := fullVersionRegex.FindStringSubmatch(m[2])
+		if from != nil && to != nil {
+			return hyphenRange(m[1], from, m[2], to)
+		}
+	}
+
+	// Basic comparators
+	m := basicRegex.FindStringSubmatch(constraint)
+	op, ver := m[1], m[2]
+
+	normalized, err := Normalize(ver)
+	if err != nil {
+		// Recover from branch constraints written as name-dev instead of dev-name
+		if !strings.HasSuffix(ver, "-dev") || !branchNameRegex.MatchString(ver) {
+			return nil, err
+		}
+		if normalized, err = Normalize("dev-" + strings.TrimSuffix(ver, "-dev")); err != nil {
+			return nil, err
+		}
+	}
+
+	if op == "" {
+		op = "="
+	}
+
+	if op != "==" && op != "=" && stabilityModifier != "" && ParseStability(normalized) == "stable" {
+		normalized += "-" + stabilityModifier
+	} else if op == "<" || op == ">=" {
+		// <1.0 must exclude 1.0-beta, >=1.0 must include it
+		if !modifierEndRegex.MatchString(strings.ToLower(ver)) && !strings.HasPrefix(ver, "dev-") {
+			normalized += "-dev"
+		}
+	}
+
+	return []Constraint{&single{op: op, version: normalized}}, nil
+}
+
+// hyphenRange expands "from - to". An upper bound missing its patch number
+// acts as a wildcard, so "1.0 - 2.1" admits every 2.1.x release.
+func hyphenRange(fromStr string, from []string, toStr string, to []string) ([]Constraint, error) {
+	low, err := Normalize(fromStr)
+	if err != nil {
+		return nil, err
+	}
+	lower := &single{op: ">=", version: low + devSuffix(from)}
+
+	high, err := Normalize(toStr)
+	if err != nil {
+		return nil, err
+	}
+	if (to[2] != "" && to[3] != "") || to[5] != "" || to[7] != "" {
+		return []Constraint{lower, &single{op: "<=", version: high}}, nil
+	}
+
+	position := 2
+	if to[2] == "" {
+		position = 1
+	}
+	return []Constraint{lower, &single{op: "<", version: manipulateVersion(to, position, 1) + "-dev"}}, nil
+}
+
+// devSuffix returns "-dev" for lower bounds without an explicit stability,
+// so pre-releases of the bound itself are admitted.
+func devSuffix(m []string) string {
+	if m[5] == "" && m[7] == "" {
+		return "-dev"
+	}
+	return ""
+}
+
+// manipulateVersion increments the numeric component at position (1-based)
+// and zeroes the components after it, mirroring Composer's
+// VersionParser::manipulateVersionString.
+func manipulateVersion(m []string, position, increment int) string {
+	parts := make([]string, 4)
+	for i := range parts {
+		switch {
+		case i+1 > position:
+			parts[i] = "0"
+		case i+1 == position:
+			n, _ := strconv.Atoi(m[i+1])
+			parts[i] = strconv.Itoa(n + increment)
+		default:
+			parts[i] = m[i+1]
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Matches reports whether normalized satisfies the operator and version.
+func (c *single) Matches(normalized string) bool {
+	return compareWithBranches(normalized, c.op, c.version)
+}
+
+func (c *single) String() string {
+	op := c.op
+	switch op {
+	case "=":
+		op = "=="
+	case "<>":
+		op = "!="
+	}
+	return op + " " + c.version
+}
+
+func (c *single) Compile(variable string) string {
+	version := util.PHPString(c.version)
+	if IsBranch(c.version) {
+		switch c.op {
+		case "=", "==":
+			return variable + " === " + version
+		case "!=", "<>":
+			return variable + " !== " + version
+		}
+		return "false"
+	}
+
+	op := c.op
+	if op == "=" {
+		op = "=="
+	}
+	return fmt.Sprintf("\\version_compare(%s, %s, '%s')", variable, version, op)
+}
+
+// Matches reports whether normalized satisfies all (AND) or any (OR) of the
+// combined constraints.
+func (c *multi) Matches(normalized string) bool {
+	for _, sub := range c.constraints {
+		if sub.Matches(normalized) != c.conjunctive {
+			return !c.conjunctive
+		}
+	}
+	return c.conjunctive
+}
+
+func (c *multi) String() string {
+	parts := make([]string, len(c.constraints))
+	for i, sub := range c.constraints {
+		parts[i] = sub.String()
+	}
+	sep := " || "
+	if c.conjunctive {
+		sep = " "
+	}
+	return "[" + strings.Join(parts, sep) + "]"
+}
+
+func (c *multi) Compile(variable string) string {
+	parts := make([]string, len(c.constraints))
+	for i, sub := range c.constraints {
+		parts[i] = sub.Compile(variable)
+	}
+	sep := " || "
+	if c.conjunctive {
+		sep = " && "
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// Matches always returns true.
+func (matchAll) Matches(string) bool { return true }
+
+func (matchAll) String() string { return "*" }
+
+func (matchAll) Compile(string) string { return "true" }
+
+// compareWithBranches compares versions like Composer's Constraint: dev
+// branches are only ever equal or unequal, never ordered.
+func compareWithBranches(a, op, b string) bool {
+	if IsBranch(a) || IsBranch(b) {
+		switch op {
+		case "=", "==":
+			return a == b
+		case "!=", "<>":
+			return a != b
+		}
+		return false
+	}
+
+	c := Compare(a, b)
+	switch op {
+	case "=", "==":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// StabilityFlag returns the least stable stability a constraint asks for,
+// either explicitly via @flags or implicitly through unstable versions such
+// as "2.0-beta", following Composer's RootPackageLoader. Constraints that
+// only admit stable releases return "stable".
+func StabilityFlag(constraint string) string {
+	var tokens []string
+	for _, orPart := range orSplitRegex.Split(strings.TrimSpace(constraint), -1) {
+		tokens = append(tokens, splitAnd(orPart)...)
+	}
+
+	flag := "stable"
+	explicit := false
+	for _, token := range tokens {
+		if m := explicitFlagRegex.FindStringSubmatch(token); m != nil {
+			explicit = true
+			if s := NormalizeStability(m[1]); Stabilities[s] > Stabilities[flag] {
+				flag = s
+			}
+		}
+	}
+	if explicit {
+		return flag
+	}
+
+	for _, token := range tokens {
+		if m := aliasedRegex.FindStringSubmatch(token); m != nil {
+			token = m[1]
+		}
+		if !bareVersionRegex.MatchString(token) {
+			continue
+		}
+		if s := ParseStability(token); Stabilities[s] > Stabilities[flag] {
+			flag = s
+		}
+	}
+	return flag
+}
@@ -0,0 +1,269 @@
+package version
+
+import "testing"
+
+// Test vectors are taken from Composer's semver VersionParserTest and
+// SemverTest.
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Basic comparators
+		{"*", "*"},
+		{"*.*", "*"},
+		{"*.x.*", "*"},
+		{"x.X.x.*", "*"},
+		{"v*", ">= 0.0.0.0-dev"},
+		{"<>1.0.0", "!= 1.0.0.0"},
+		{"!=1.0.0", "!= 1.0.0.0"},
+		{">1.0.0", "> 1.0.0.0"},
+		{"<1.2.3.4", "< 1.2.3.4-dev"},
+		{"<=1.2.3", "<= 1.2.3.0"},
+		{">=1.2.3", ">= 1.2.3.0-dev"},
+		{"=1.2.3", "== 1.2.3.0"},
+		{"==1.2.3", "== 1.2.3.0"},
+		{"1.2.3", "== 1.2.3.0"},
+		{"=1.0", "== 1.0.0.0"},
+		{"1.2.3b5", "== 1.2.3.0-beta5"},
+		{"1.2.3a1", "== 1.2.3.0-alpha1"},
+		{"1.2.3p1234", "== 1.2.3.0-patch1234"},
+		{"1.2.3pl1234", "== 1.2.3.0-patch1234"},
+		{">= 1.2.3", ">= 1.2.3.0-dev"},
+		{"< 1.2.3", "< 1.2.3.0-dev"},
+		{"> 1.2.3", "> 1.2.3.0"},
+		{">=dev-master", ">= dev-master"},
+		{"dev-master", "== dev-master"},
+		{"dev-feature-a", "== dev-feature-a"},
+		{"dev-CAPS", "== dev-CAPS"},
+		{"dev-master as 1.0.0", "== dev-master"},
+		{"<1.2.3.4-stable", "< 1.2.3.4"},
+		{">=1.2.3.4-stable", ">= 1.2.3.4"},
+		{">=2.0-stable", ">= 2.0.0.0"},
+		{"v1.2.3", "== 1.2.3.0"},
+		{"foobar-dev", "== dev-foobar"},
+		{"dev-master#abc123", "== dev-master"},
+		{"1.x-dev#abc123", "== 1.9999999.9999999.9999999-dev"},
+
+		// Wildcards
+		{"v2.*", "[>= 2.0.0.0-dev < 3.0.0.0-dev]"},
+		{"2.*.*", "[>= 2.0.0.0-dev < 3.0.0.0-dev]"},
+		{"20.*", "[>= 20.0.0.0-dev < 21.0.0.0-dev]"},
+		{"2.0.*", "[>= 2.0.0.0-dev < 2.1.0.0-dev]"},
+		{"2.x", "[>= 2.0.0.0-dev < 3.0.0.0-dev]"},
+		{"2.x.x", "[>= 2.0.0.0-dev < 3.0.0.0-dev]"},
+		{"2.2.x", "[>= 2.2.0.0-dev < 2.3.0.0-dev]"},
+		{"2.10.X", "[>= 2.10.0.0-dev < 2.11.0.0-dev]"},
+		{"2.1.3.*", "[>= 2.1.3.0-dev < 2.1.4.0-dev]"},
+		{"0.*", "< 1.0.0.0-dev"},
+		{"0.*.*", "< 1.0.0.0-dev"},
+		{"0.x", "< 1.0.0.0-dev"},
+
+		// Tilde
+		{"~1", "[>= 1.0.0.0-dev < 2.0.0.0-dev]"},
+		{"~1.0", "[>= 1.0.0.0-dev < 2.0.0.0-dev]"},
+		{"~1.0.0", "[>= 1.0.0.0-dev < 1.1.0.0-dev]"},
+		{"~1.2", "[>= 1.2.0.0-dev < 2.0.0.0-dev]"},
+		{"~1.2.3", "[>= 1.2.3.0-dev < 1.3.0.0-dev]"},
+		{"~1.2.3.4", "[>= 1.2.3.4-dev < 1.2.4.0-dev]"},
+		{"~1.2-beta", "[>= 1.2.0.0-beta < 2.0.0.0-dev]"},
+		{"~1.2-b2", "[>= 1.2.0.0-beta2 < 2.0.0.0-dev]"},
+		{"~1.2-BETA2", "[>= 1.2.0.0-beta2 < 2.0.0.0-dev]"},
+		{"~1.2.2-dev", "[>= 1.2.2.0-dev < 1.3.0.0-dev]"},
+		{"~1.2.2-stable", "[>= 1.2.2.0 < 1.3.0.0-dev]"},
+		{"~v1.2.3", "[>= 1.2.3.0-dev < 1.3.0.0-dev]"},
+
+		// Caret
+		{"^1", "[>= 1.0.0.0-dev < 2.0.0.0-dev]"},
+		{"^0", "[>= 0.0.0.0-dev < 1.0.0.0-dev]"},
+		{"^0.0", "[>= 0.0.0.0-dev < 0.1.0.0-dev]"},
+		{"^1.2", "[>= 1.2.0.0-dev < 2.0.0.0-dev]"},
+		{"^1.2.3-beta.2", "[>= 1.2.3.0-beta2 < 2.0.0.0-dev]"},
+		{"^1.2.3.4", "[>= 1.2.3.4-dev < 2.0.0.0-dev]"},
+		{"^1.2.3", "[>= 1.2.3.0-dev < 2.0.0.0-dev]"},
+		{"^0.2.3", "[>= 0.2.3.0-dev < 0.3.0.0-dev]"},
+		{"^0.2", "[>= 0.2.0.0-dev < 0.3.0.0-dev]"},
+		{"^0.2.0", "[>= 0.2.0.0-dev < 0.3.0.0-dev]"},
+		{"^0.0.3", "[>= 0.0.3.0-dev < 0.0.4.0-dev]"},
+		{"^0.0.3-alpha", "[>= 0.0.3.0-alpha < 0.0.4.0-dev]"},
+		{"^0.0.3-dev", "[>= 0.0.3.0-dev < 0.0.4.0-dev]"},
+		{"^v1.2", "[>= 1.2.0.0-dev < 2.0.0.0-dev]"},
+
+		// Hyphen ranges
+		{"1 - 2", "[>= 1.0.0.0-dev < 3.0.0.0-dev]"},
+		{"1.2.3 - 2.3.4.5", "[>= 1.2.3.0-dev <= 2.3.4.5]"},
+		{"1.2-beta - 2.3", "[>= 1.2.0.0-beta < 2.4.0.0-dev]"},
+		{"1.2-beta - 2.3-dev", "[>= 1.2.0.0-beta <= 2.3.0.0-dev]"},
+		{"1.2-RC - 2.3.1", "[>= 1.2.0.0-RC <= 2.3.1.0]"},
+		{"1.2.3-alpha - 2.3-RC", "[>= 1.2.3.0-alpha <= 2.3.0.0-RC]"},
+		{"1 - 2.0", "[>= 1.0.0.0-dev < 2.1.0.0-dev]"},
+		{"1 - 2.1", "[>= 1.0.0.0-dev < 2.2.0.0-dev]"},
+		{"1.2 - 2.1.0", "[>= 1.2.0.0-dev <= 2.1.0.0]"},
+		{"1.3 - 2.1.3", "[>= 1.3.0.0-dev <= 2.1.3.0]"},
+
+		// Stability flags
+		{"1.0@dev", "== 1.0.0.0"},
+		{">=1.0@beta", ">= 1.0.0.0-beta"},
+		{"@dev", "*"},
+		{"1.0.*@beta", "[>= 1.0.0.0-dev < 1.1.0.0-dev]"},
+		{"^1.2@stable", "[>= 1.2.0.0-dev < 2.0.0.0-dev]"},
+
+		// Multiple constraints
+		{">2.0,<=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0 <=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0  <=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0, <=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0 ,<=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0 , <=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{">2.0   , <=3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{"> 2.0   <=  3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{"> 2.0  ,  <=  3.0", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{"  > 2.0  ,  <=  3.0 ", "[> 2.0.0.0 <= 3.0.0.0]"},
+		{"^1.0 || ^2.0", "[[>= 1.0.0.0-dev < 2.0.0.0-dev] || [>= 2.0.0.0-dev < 3.0.0.0-dev]]"},
+		{"^1.0 | ^2.0", "[[>= 1.0.0.0-dev < 2.0.0.0-dev] || [>= 2.0.0.0-dev < 3.0.0.0-dev]]"},
+		{"<1.1 || >= 1.2", "[< 1.1.0.0-dev || >= 1.2.0.0-dev]"},
+		{"^1.2 || dev-main", "[[>= 1.2.0.0-dev < 2.0.0.0-dev] || == dev-main]"},
+		{">=8.2, <8.4", "[>= 8.2.0.0-dev < 8.4.0.0-dev]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, err := ParseConstraint(tt.input)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := c.String(); got != tt.want {
+				t.Errorf("ParseConstraint(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		">2.0,,<=3.0x",
+		"1.0.0-meh",
+		">2.0 || invalid",
+		"~>1.2",
+		"foobar",
+		"~1.2.3.4.5",
+		"^1.2.3.4.5",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if _, err := ParseConstraint(input); err == nil {
+				t.Errorf("ParseConstraint(%q) expected error, got nil", input)
+			}
+		})
+	}
+}
+
+func TestConstraintMatches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^1.2", "1.2.3", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^1.2@beta", "1.2.0-beta", true},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"1.0.*", "1.0.99", true},
+		{"1.0.*", "1.1.0", false},
+		{">=1.2.3 <1.3", "1.2.3.4", true},
+		{"<1.0", "1.0.0-beta", false},
+		{">=1.0", "1.0.0-beta", true},
+		{"^1.2 || dev-main", "dev-main", true},
+		{"^1.2 || dev-main", "dev-feature", false},
+		{"dev-main", "dev-main", true},
+		{">=1.0", "dev-main", false},
+		{"!=dev-main", "dev-feature", true},
+		{"1.x-dev", "1.x-dev", true},
+		{"v1.2.3", "1.2.3", true},
+		{"1.2.3.4", "v1.2.3.4", true},
+		{"1.0 - 2.0", "2.0.5", true},
+		{"1.0 - 2.0", "2.1.0", false},
+		{"*", "3.0.0-alpha", true},
+		{"~8.3.0", "8.3.17", true},
+		{">=8.2, <8.4", "8.4.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint() error: %v", err)
+			}
+			normalized, err := Normalize(tt.version)
+			if err != nil {
+				t.Fatalf("Normalize() error: %v", err)
+			}
+
+			if got := c.Matches(normalized); got != tt.want {
+				t.Errorf("%q matches %q = %v, want %v", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraintCompile(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{">=8.2", `\version_compare($v, '8.2.0.0-dev', '>=')`},
+		{"^8.1", `(\version_compare($v, '8.1.0.0-dev', '>=') && \version_compare($v, '9.0.0.0-dev', '<'))`},
+		{"8.3.*", `(\version_compare($v, '8.3.0.0-dev', '>=') && \version_compare($v, '8.4.0.0-dev', '<'))`},
+		{"8.2.1", `\version_compare($v, '8.2.1.0', '==')`},
+		{"~8.2 || ^9.0", `((\version_compare($v, '8.2.0.0-dev', '>=') && \version_compare($v, '9.0.0.0-dev', '<')) || (\version_compare($v, '9.0.0.0-dev', '>=') && \version_compare($v, '10.0.0.0-dev', '<')))`},
+		{"dev-main", `$v === 'dev-main'`},
+		{"*", `true`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint() error: %v", err)
+			}
+
+			if got := c.Compile("$v"); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStabilityFlag(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"^1.2", "stable"},
+		{"", "stable"},
+		{"1.0.*@beta", "beta"},
+		{"^1.0@RC || ^2.0@alpha", "alpha"},
+		{"2.0-beta", "beta"},
+		{">=2.0-beta", "beta"},
+		{"dev-main", "dev"},
+		{"1.x-dev", "dev"},
+		{"dev-main as 1.0.0", "dev"},
+		{">=2.0-stable", "stable"},
+		{"^1.0@stable || 2.0-beta", "stable"},
+		{"~1.2-RC1", "RC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			if got := StabilityFlag(tt.constraint); got != tt.want {
+				t.Errorf("StabilityFlag(%q) = %q, want %q", tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Package version implements Composer's version normalization, stability
+// and comparison rules, so constraints resolve the same way Composer would.
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// modifierRegex matches a stability suffix such as "-beta.2" or "RC1-dev".
+const modifierRegex = `[._-]?(?:(stable|beta|b|RC|alpha|a|patch|pl|p)((?:[.-]?\d+)*)?)?([.-]?dev)?`
+
+var (
+	aliasRegex         = regexp.MustCompile(`^([^,\s]+) +as +([^,\s]+)$`)
+	stabilityFlagRegex = regexp.MustCompile(`(?i)@(?:stable|RC|beta|alpha|dev)$`)
+	buildMetaRegex     = regexp.MustCompile(`^([^,\s+]+)\+[^\s]+$`)
+	classicalRegex     = regexp.MustCompile(`(?i)^v?(\d{1,5})(\.\d+)?(\.\d+)?(\.\d+)?` + modifierRegex + `$`)
+	dateRegex          = regexp.MustCompile(`(?i)^v?(\d{4}(?:[.:-]?\d{2}){1,6}(?:[.:-]?\d{1,3}){0,2})` + modifierRegex + `$`)
+	devSuffixRegex     = regexp.MustCompile(`(?i)^(.*?)[.-]?dev$`)
+	branchRegex        = regexp.MustCompile(`(?i)^v?(\d+)(\.(?:\d+|[xX*]))?(\.(?:\d+|[xX*]))?(\.(?:\d+|[xX*]))?$`)
+	stabilitySuffix    = regexp.MustCompile(`(?i)` + modifierRegex + `(?:\+.*)?$`)
+	nonDigitRegex      = regexp.MustCompile(`\D`)
+)
+
+// Stabilities orders stability names from most to least stable, matching
+// Composer's BasePackage::STABILITIES.
+var Stabilities = map[string]int{
+	"stable": 0,
+	"RC":     5,
+	"beta":   10,
+	"alpha":  15,
+	"dev":    20,
+}
+
+// Normalize converts a version string to Composer's normalized form,
+// e.g. "v1.2" becomes "1.2.0.0" and "1.x-dev" becomes "1.9999999.9999999.9999999-dev".
+func Normalize(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	original := version
+
+	// Strip off aliasing
+	if m := aliasRegex.FindStringSubmatch(version); m != nil {
+		version = m[1]
+	}
+
+	// Strip off stability flag
+	if m := stabilityFlagRegex.FindString(version); m != "" {
+		version = version[:len(version)-len(m)]
+	}
+
+	// Normalize master/trunk/default branches to dev-name
+	switch version {
+	case "master", "trunk", "default":
+		version = "dev-" + version
+	}
+
+	// Branch-like requirements keep their full name
+	if strings.HasPrefix(strings.ToLower(version), "dev-") {
+		return "dev-" + version[4:], nil
+	}
+
+	// Strip off build metadata
+	if m := buildMetaRegex.FindStringSubmatch(version); m != nil {
+		version = m[1]
+	}
+
+	var m []string
+	index := 0
+	if m = classicalRegex.FindStringSubmatch(version); m != nil {
+		version = m[1] + orDefault(m[2], ".0") + orDefault(m[3], ".0") + orDefault(m[4], ".0")
+		index = 5
+	} else if m = dateRegex.FindStringSubmatch(version); m != nil {
+		version = nonDigitRegex.ReplaceAllString(m[1], ".")
+		index = 2
+	}
+
+	if index > 0 {
+		if m[index] != "" {
+			if m[index] == "stable" {
+				return version, nil
+			}
+			version += "-" + expandStability(m[index]) + strings.TrimLeft(m[index+1], ".-")
+		}
+		if m[index+2] != "" {
+			version += "-dev"
+		}
+		return version, nil
+	}
+
+	// Match dev branches such as 1.x-dev
+	if m := devSuffixRegex.FindStringSubmatch(version); m != nil {
+		if normalized := NormalizeBranch(m[1]); !strings.Contains(normalized, "dev-") {
+			return normalized, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid version string %q", original)
+}
+
+// NormalizeBranch normalizes a branch name, mapping numeric branches such as
+// "1.x" to "1.9999999.9999999.9999999-dev" and others to "dev-name".
+func NormalizeBranch(name string) string {
+	name = strings.TrimSpace(name)
+
+	m := branchRegex.FindStringSubmatch(name)
+	if m == nil {
+		return "dev-" + name
+	}
+
+	version := m[1]
+	for _, part := range m[2:] {
+		if part == "" {
+			part = ".x"
+		}
+		version += strings.NewReplacer("*", "x", "X", "x").Replace(part)
+	}
+	return strings.ReplaceAll(version, "x", "9999999") + "-dev"
+}
+
+// ParseStability returns the stability of a version: "stable", "RC",
+// "beta", "alpha" or "dev".
+func ParseStability(version string) string {
+	if idx := strings.Index(version, "#"); idx != -1 {
+		version = version[:idx]
+	}
+
+	lower := strings.ToLower(version)
+	if strings.HasPrefix(lower, "dev-") || strings.HasSuffix(lower, "-dev") {
+		return "dev"
+	}
+
+	m := stabilitySuffix.FindStringSubmatch(lower)
+	if m == nil {
+		return "stable"
+	}
+	if m[3] != "" {
+		return "dev"
+	}
+
+	switch m[1] {
+	case "beta", "b":
+		return "beta"
+	case "alpha", "a":
+		return "alpha"
+	case "rc":
+		return "RC"
+	}
+	return "stable"
+}
+
+// NormalizeStability maps a stability name to its canonical casing.
+func NormalizeStability(stability string) string {
+	stability = strings.ToLower(stability)
+	if stability == "rc" {
+		return "RC"
+	}
+	return stability
+}
+
+// IsBranch reports whether a normalized version names a dev branch.
+func IsBranch(normalized string) bool {
+	return strings.HasPrefix(normalized, "dev-")
+}
+
+// Compare compares two normalized versions using PHP's version_compare
+// rules, returning -1, 0 or 1.
+func Compare(a, b string) int {
+	pa := canonicalize(a)
+	pb := canonicalize(b)
+
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if c := comparePart(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+
+	// A trailing number makes a version newer; a trailing modifier such as
+	// "dev" or "beta" makes it older, while "patch" makes it newer.
+	switch {
+	case len(pa) > len(pb):
+		if isNumeric(pa[len(pb)]) {
+			return 1
+		}
+		return comparePart(pa[len(pb)], "#")
+	case len(pb) > len(pa):
+		if isNumeric(pb[len(pa)]) {
+			return -1
+		}
+		return comparePart("#", pb[len(pa)])
+	}
+	return 0
+}
+
+// canonicalize splits a version into parts the way version_compare does:
+// separators become dots and transitions between digits and letters split parts.
+func canonicalize(v string) []string {
+	var parts []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+	}
+
+	var prev rune
+	for i, r := range v {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case i > 0 && current.Len() > 0 && unicode.IsDigit(r) != unicode.IsDigit(prev):
+			flush()
+			current.WriteRune(r)
+		default:
+			current.WriteRune(r)
+		}
+		prev = r
+	}
+	flush()
+
+	return parts
+}
+
+func comparePart(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+	switch {
+	case aNum && bNum:
+		ai, _ := strconv.ParseInt(a, 10, 64)
+		bi, _ := strconv.ParseInt(b, 10, 64)
+		return compareInts(ai, bi)
+	case aNum:
+		return compareInts(specialOrder("#"), specialOrder(b))
+	case bNum:
+		return compareInts(specialOrder(a), specialOrder("#"))
+	default:
+		return compareInts(specialOrder(a), specialOrder(b))
+	}
+}
+
+// specialOrder ranks version_compare's special forms; unknown strings sort lowest.
+func specialOrder(form string) int64 {
+	forms := []struct {
+		name  string
+		order int64
+	}{
+		{"dev", 0}, {"alpha", 1}, {"a", 1}, {"beta", 2}, {"b", 2},
+		{"RC", 3}, {"rc", 3}, {"#", 4}, {"pl", 5}, {"p", 5},
+	}
+	for _, f := range forms {
+		if strings.HasPrefix(form, f.name) {
+			return f.order
+		}
+	}
+	return -6
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func expandStability(stability string) string {
+	switch stability = strings.ToLower(stability); stability {
+	case "a":
+		return "alpha"
+	case "b":
+		return "beta"
+	case "p", "pl":
+		return "patch"
+	case "rc":
+		return "RC"
+	}
+	return stability
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,203 @@
+package version
+
+import "testing"
+
+// Test vectors are taken from Composer's semver VersionParserTest.
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1.0.0", "1.0.0.0"},
+		{"1.2.3.4", "1.2.3.4"},
+		{"1.0.0RC1dev", "1.0.0.0-RC1-dev"},
+		{"1.0.0-rC15-dev", "1.0.0.0-RC15-dev"},
+		{"1.0.0.RC.15-dev", "1.0.0.0-RC15-dev"},
+		{"1.0.0-rc1", "1.0.0.0-RC1"},
+		{"1.0.0.pl3-dev", "1.0.0.0-patch3-dev"},
+		{"1.0-dev", "1.0.0.0-dev"},
+		{"0", "0.0.0.0"},
+		{"10.4.13-beta", "10.4.13.0-beta"},
+		{"10.4.13beta2", "10.4.13.0-beta2"},
+		{"10.4.13beta.2", "10.4.13.0-beta2"},
+		{"v1.13.11-beta.0", "1.13.11.0-beta0"},
+		{"1.13.11.0-beta0", "1.13.11.0-beta0"},
+		{"10.4.13-b", "10.4.13.0-beta"},
+		{"10.4.13-b5", "10.4.13.0-beta5"},
+		{"v1.0.0", "1.0.0.0"},
+		{"2010.01", "2010.01.0.0"},
+		{"2010.01.02", "2010.01.02.0"},
+		{"2010.1.555", "2010.1.555.0"},
+		{"2010.10.200", "2010.10.200.0"},
+		{"v20100102", "20100102"},
+		{"2010-01-02", "2010.01.02"},
+		{"2010-01-02.5", "2010.01.02.5"},
+		{"20100102-203040", "20100102.203040"},
+		{"20100102203040-10", "20100102203040.10"},
+		{"20100102-203040-p1", "20100102.203040-patch1"},
+		{"201903.0", "201903.0"},
+		{"201903.0-p2", "201903.0-patch2"},
+		{"dev-master", "dev-master"},
+		{"master", "dev-master"},
+		{"dev-trunk", "dev-trunk"},
+		{"1.x-dev", "1.9999999.9999999.9999999-dev"},
+		{"dev-feature-foo", "dev-feature-foo"},
+		{"DEV-FOOBAR", "dev-FOOBAR"},
+		{"dev-feature/foo", "dev-feature/foo"},
+		{"dev-feature+issue-1", "dev-feature+issue-1"},
+		{"dev-master as 1.0.0", "dev-master"},
+		{"dev-load-varnish-only-when-used as ^2.0", "dev-load-varnish-only-when-used"},
+		{"dev-load-varnish-only-when-used@dev as ^2.0@dev", "dev-load-varnish-only-when-used"},
+		{"1.0.0+foo@dev", "1.0.0.0"},
+		{"dev-load-varnish-only-when-used@stable", "dev-load-varnish-only-when-used"},
+		{"1.0.0-beta.5+foo", "1.0.0.0-beta5"},
+		{"1.0.0+foo", "1.0.0.0"},
+		{"1.0.0-alpha.3.1+foo", "1.0.0.0-alpha3.1"},
+		{"1.0.0-alpha2.1+foo", "1.0.0.0-alpha2.1"},
+		{"1.0.0-alpha-2.1-3+foo", "1.0.0.0-alpha2.1-3"},
+		{"1.0.0+foo as 2.0", "1.0.0.0"},
+		{"00.01.03.04", "00.01.03.04"},
+		{"000.001.003.004", "000.001.003.004"},
+		{"0.000.103.204", "0.000.103.204"},
+		{"0700", "0700.0.0.0"},
+		{"041.x-dev", "041.9999999.9999999.9999999-dev"},
+		{"dev-041.003", "dev-041.003"},
+		{"dev-1.0.0-dev<1.0.5-dev", "dev-1.0.0-dev<1.0.5-dev"},
+		{"dev-foo bar", "dev-foo bar"},
+		{" 1.0.0", "1.0.0.0"},
+		{"1.0.0 ", "1.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"a",
+		"1.0.0-meh",
+		"1.0.0.0.0",
+		"feature-foo",
+		"1.0.0 foo",
+		"1.0.0+foo bar",
+		"^1",
+		">=1.0",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if got, err := Normalize(input); err == nil {
+				t.Errorf("Normalize(%q) = %q, want error", input, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeBranch(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"v1.x", "1.9999999.9999999.9999999-dev"},
+		{"v1.*", "1.9999999.9999999.9999999-dev"},
+		{"v1.0", "1.0.9999999.9999999-dev"},
+		{"2.0", "2.0.9999999.9999999-dev"},
+		{"v1.0.x", "1.0.9999999.9999999-dev"},
+		{"v1.0.3.*", "1.0.3.9999999-dev"},
+		{"v2.4.0", "2.4.0.9999999-dev"},
+		{"2.4.4", "2.4.4.9999999-dev"},
+		{"master", "dev-master"},
+		{"trunk", "dev-trunk"},
+		{"feature-a", "dev-feature-a"},
+		{"FOOBAR", "dev-FOOBAR"},
+		{"feature+issue-1", "dev-feature+issue-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeBranch(tt.input); got != tt.want {
+				t.Errorf("NormalizeBranch(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStability(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1", "stable"},
+		{"1.0", "stable"},
+		{"3.2.1", "stable"},
+		{"v3.2.1", "stable"},
+		{"v2.0.x-dev", "dev"},
+		{"v2.0.x-dev#abc123", "dev"},
+		{"v2.0.x-dev#trunk/@123", "dev"},
+		{"3.0-RC2", "RC"},
+		{"dev-master", "dev"},
+		{"3.1.2-dev", "dev"},
+		{"dev-feature+issue-1", "dev"},
+		{"3.1.2-p1", "stable"},
+		{"3.1.2-pl2", "stable"},
+		{"3.1.2-patch", "stable"},
+		{"3.1.2-alpha5", "alpha"},
+		{"3.1.2-beta", "beta"},
+		{"2.0B1", "beta"},
+		{"1.2.0a1", "alpha"},
+		{"1.2_a1", "alpha"},
+		{"2.0.0rc1", "RC"},
+		{"1.0.0-alpha11+cs-1.1.0", "alpha"},
+		{"1-2_dev", "dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ParseStability(tt.input); got != tt.want {
+				t.Errorf("ParseStability(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.25.0.0", "1.24.0.0", 1},
+		{"1.25.0.0", "1.25.0.0", 0},
+		{"1.25.0.0", "1.26.0.0", -1},
+		{"1.10.0.0", "1.9.0.0", 1},
+		{"1.0.0.0-dev", "1.0.0.0-alpha", -1},
+		{"1.0.0.0-alpha", "1.0.0.0-beta", -1},
+		{"1.0.0.0-beta2", "1.0.0.0-beta10", -1},
+		{"1.0.0.0-beta", "1.0.0.0-RC1", -1},
+		{"1.0.0.0-RC1", "1.0.0.0", -1},
+		{"1.0.0.0-patch1", "1.0.0.0", 1},
+		{"1.0.0.0", "1.0.0.0-dev", 1},
+		{"1.2.3.4", "1.2.3.0", 1},
+		{"1.9999999.9999999.9999999-dev", "1.10.0.0", 1},
+		{"20100102.203040", "20100102.203039", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := Compare(tt.a, tt.b); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
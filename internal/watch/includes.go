@@ -0,0 +1,49 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// includePattern matches include and require statements with a literal
+// path, optionally relative to __DIR__ or dirname(__FILE__).
+var includePattern = regexp.MustCompile(`\b(?:require|include)(?:_once)?\s*\(?\s*((?:__DIR__|dirname\(__FILE__\))\s*\.\s*)?['"]([^'"$]+)['"]`)
+
+// Includes returns a script and the local files it includes, following
+// includes in those files too. Paths are resolved against the including
+// file's directory; includes that do not exist, such as those found through
+// include_path, are skipped.
+func Includes(script string) []string {
+	script, err := filepath.Abs(script)
+	if err != nil {
+		return []string{script}
+	}
+
+	files := []string{script}
+	seen := map[string]bool{script: true}
+
+	for i := 0; i < len(files); i++ {
+		content, err := os.ReadFile(files[i])
+		if err != nil {
+			continue
+		}
+
+		for _, m := range includePattern.FindAllSubmatch(content, -1) {
+			path := string(m[2])
+			if len(m[1]) > 0 || !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(files[i]), path)
+			}
+			if seen[path] {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,247 @@
+// Package watch reports changes to scripts and the files around them, so
+// they can be re-run while being edited.
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settle is how long changes must stop for before they are reported, so an
+// editor's save or a formatter rewriting several files triggers one re-run.
+const settle = 150 * time.Millisecond
+
+// skipDirs are not watched inside directory trees: dependencies and VCS
+// metadata change often and are not edited by hand.
+var skipDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, "vendor": true, "node_modules": true}
+
+// Watcher reports changes to a set of files, directory trees and globs.
+type Watcher struct {
+	patterns []string
+	events   chan string // Changed paths from the platform backend
+	changes  chan []string
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	recursive map[string]bool // Watched directories, and whether their subdirectories are too
+
+	backend
+}
+
+// New watches patterns: files, directories (watched recursively) and globs,
+// in which "**" matches any number of directories. Relative patterns are
+// taken from the working directory.
+func New(patterns ...string) (*Watcher, error) {
+	w := &Watcher{
+		events:    make(chan string, 64),
+		changes:   make(chan []string, 1),
+		done:      make(chan struct{}),
+		recursive: make(map[string]bool),
+	}
+
+	for _, p := range patterns {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		w.patterns = append(w.patterns, abs)
+	}
+
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range w.patterns {
+		dir, recursive := root(p)
+		if err := w.watchDir(dir, recursive); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// Changes delivers the paths changed since the last delivery, once changes
+// have settled.
+func (w *Watcher) Changes() <-chan []string {
+	return w.changes
+}
+
+// Close stops watching.
+func (w *Watcher) Close() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.stop()
+	})
+}
+
+// loop batches changed paths and delivers them once they settle.
+func (w *Watcher) loop() {
+	var pending []string
+	seen := make(map[string]bool)
+	timer := time.NewTimer(settle)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case path := <-w.events:
+			if !w.Matches(path) || seen[path] {
+				continue
+			}
+			seen[path] = true
+			pending = append(pending, path)
+			timer.Reset(settle)
+		case <-timer.C:
+			select {
+			case w.changes <- pending:
+			case <-w.done:
+				return
+			}
+			pending = nil
+			seen = make(map[string]bool)
+		}
+	}
+}
+
+// watchDir watches dir, and its subdirectories when recursive.
+func (w *Watcher) watchDir(dir string, recursive bool) error {
+	if !recursive {
+		return w.add(dir, false)
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // Removed or unreadable while walking
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		return w.add(path, true)
+	})
+}
+
+// add records a watched directory and passes it to the backend.
+func (w *Watcher) add(dir string, recursive bool) error {
+	w.mu.Lock()
+	if seen, ok := w.recursive[dir]; ok && (seen || !recursive) {
+		w.mu.Unlock()
+		return nil
+	}
+	w.recursive[dir] = recursive
+	w.mu.Unlock()
+
+	return w.addDir(dir)
+}
+
+// dirCreated starts watching a directory created inside a watched tree.
+func (w *Watcher) dirCreated(path string) {
+	w.mu.Lock()
+	recursive := w.recursive[filepath.Dir(path)]
+	w.mu.Unlock()
+
+	if recursive && !skipDir(filepath.Base(path)) {
+		_ = w.watchDir(path, true)
+	}
+}
+
+// Matches reports whether a changed path is one of the watched files.
+// Hidden files, such as editor swap files and tool caches, only match when
+// named exactly.
+func (w *Watcher) Matches(path string) bool {
+	hidden := strings.HasPrefix(filepath.Base(path), ".")
+
+	for _, p := range w.patterns {
+		switch {
+		case path == p:
+			return true
+		case hidden:
+			continue
+		case hasMeta(p):
+			if matchGlob(p, path) {
+				return true
+			}
+		case strings.HasPrefix(path, p+string(filepath.Separator)):
+			if isDir(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// root returns the directory to watch for a pattern, and whether its
+// subdirectories must be watched too.
+func root(pattern string) (string, bool) {
+	if hasMeta(pattern) {
+		dir := pattern
+		for hasMeta(dir) {
+			dir = filepath.Dir(dir)
+		}
+		return dir, true
+	}
+
+	if isDir(pattern) {
+		return pattern, true
+	}
+
+	// Files are watched through their directory, so replacing them (as
+	// editors do when saving) is seen
+	return filepath.Dir(pattern), false
+}
+
+// matchGlob matches path against a glob in which "**" matches zero or more
+// path elements.
+func matchGlob(pattern, path string) bool {
+	sep := string(filepath.Separator)
+	return matchParts(strings.Split(pattern, sep), strings.Split(path, sep))
+}
+
+func matchParts(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchParts(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func skipDir(name string) bool {
+	return skipDirs[name] || (strings.HasPrefix(name, ".") && name != ".")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,96 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask covers writes, and files created, deleted or renamed in place.
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// backend watches directories with inotify.
+type backend struct {
+	inotify *os.File
+	wmu     sync.Mutex
+	dirs    map[int32]string // Watch descriptor to directory
+}
+
+func (w *Watcher) start() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+
+	// Non-blocking, so reads go through the runtime poller and Close
+	// interrupts them
+	w.inotify = os.NewFile(uintptr(fd), "inotify")
+	w.dirs = make(map[int32]string)
+
+	go w.read()
+	return nil
+}
+
+func (w *Watcher) stop() {
+	_ = w.inotify.Close()
+}
+
+func (w *Watcher) addDir(dir string) error {
+	wd, err := unix.InotifyAddWatch(int(w.inotify.Fd()), dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "watch", Path: dir, Err: err}
+	}
+
+	w.wmu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.wmu.Unlock()
+	return nil
+}
+
+// read turns inotify events into changed paths until the watcher closes.
+func (w *Watcher) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		n, err := w.inotify.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(ev.Len)]), "\x00")
+			off = nameStart + int(ev.Len)
+
+			w.wmu.Lock()
+			dir, ok := w.dirs[ev.Wd]
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, ev.Wd)
+			}
+			w.wmu.Unlock()
+			if !ok || name == "" {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if ev.Mask&unix.IN_ISDIR != 0 {
+				if ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					w.dirCreated(path)
+				}
+				continue
+			}
+
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+//go:build !linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often watched directories are scanned on platforms
+// without inotify.
+const pollInterval = 500 * time.Millisecond
+
+// backend watches directories by polling their files' modification times.
+type backend struct {
+	pmu     sync.Mutex
+	dirs    []string
+	files   map[string]time.Time
+	polling bool // Set once the initial directories are recorded
+}
+
+func (w *Watcher) start() error {
+	w.files = make(map[string]time.Time)
+	go w.poll()
+	return nil
+}
+
+func (w *Watcher) stop() {}
+
+func (w *Watcher) addDir(dir string) error {
+	if !isDir(dir) {
+		return &os.PathError{Op: "watch", Path: dir, Err: os.ErrNotExist}
+	}
+
+	// Files in directories created while polling are changes too
+	var changed []string
+	w.pmu.Lock()
+	w.dirs = append(w.dirs, dir)
+	w.scan(dir, func(path string) {
+		if w.polling {
+			changed = append(changed, path)
+		}
+	})
+	w.pmu.Unlock()
+
+	for _, path := range changed {
+		select {
+		case w.events <- path:
+		case <-w.done:
+		}
+	}
+	return nil
+}
+
+// poll reports files that appeared, changed or disappeared since the last
+// scan, until the watcher closes.
+func (w *Watcher) poll() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		var changed []string
+		w.pmu.Lock()
+		w.polling = true
+		seen := make(map[string]bool)
+		for _, dir := range w.dirs {
+			w.scan(dir, func(path string) { changed = append(changed, path) })
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				seen[filepath.Join(dir, e.Name())] = true
+			}
+		}
+		for path := range w.files {
+			if !seen[path] {
+				delete(w.files, path)
+				changed = append(changed, path)
+			}
+		}
+		w.pmu.Unlock()
+
+		for _, path := range changed {
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// scan records the files in dir, calling changed (when set) for new or
+// modified ones, and starts watching subdirectories of recursively watched
+// directories. Callers hold pmu.
+func (w *Watcher) scan(dir string, changed func(string)) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	recursive := w.recursive[dir]
+	w.mu.Unlock()
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		if e.IsDir() {
+			w.mu.Lock()
+			_, watched := w.recursive[path]
+			w.mu.Unlock()
+			if recursive && !watched {
+				go w.dirCreated(path)
+			}
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if mtime, ok := w.files[path]; !ok || !mtime.Equal(info.ModTime()) {
+			w.files[path] = info.ModTime()
+			if changed != nil {
+				changed(path)
+			}
+		}
+	}
+}
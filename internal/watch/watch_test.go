@@ -0,0 +1,155 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/app/src/*.php", "/app/src/Kernel.php", true},
+		{"/app/src/*.php", "/app/src/Http/Controller.php", false},
+		{"/app/src/**/*.php", "/app/src/Kernel.php", true},
+		{"/app/src/**/*.php", "/app/src/Http/Api/Controller.php", true},
+		{"/app/src/**/*.php", "/app/tests/KernelTest.php", false},
+		{"/app/**", "/app/config/app.yaml", true},
+		{"/app/src/*.php", "/app/src/Kernel.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.php")
+
+	tests := []struct {
+		pattern       string
+		wantDir       string
+		wantRecursive bool
+	}{
+		{file, dir, false},
+		{dir, dir, true},
+		{filepath.Join(dir, "src", "**", "*.php"), filepath.Join(dir, "src"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			gotDir, gotRecursive := root(tt.pattern)
+			if gotDir != tt.wantDir || gotRecursive != tt.wantRecursive {
+				t.Errorf("got (%s, %v), want (%s, %v)", gotDir, gotRecursive, tt.wantDir, tt.wantRecursive)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"script.php":      "<?php\nrequire __DIR__ . '/lib/helpers.php';\ninclude_once 'config.php';\nrequire 'vendor/autoload.php';\n",
+		"lib/helpers.php": "<?php\nrequire_once(dirname(__FILE__) . \"/format.php\");\nrequire __DIR__ . '/../config.php';\n",
+		"lib/format.php":  "<?php\n",
+		"config.php":      "<?php\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := Includes(filepath.Join(dir, "script.php"))
+
+	want := []string{
+		filepath.Join(dir, "script.php"),
+		filepath.Join(dir, "lib", "helpers.php"),
+		filepath.Join(dir, "config.php"),
+		filepath.Join(dir, "lib", "format.php"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWatcher_reports_changes(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.php")
+	other := filepath.Join(dir, "notes.txt")
+	src := filepath.Join(dir, "src")
+	for _, path := range []string{script, other, filepath.Join(src, "Kernel.php")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("<?php\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := New(script, filepath.Join(src, "**", "*.php"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	// Unwatched files and hidden files are ignored
+	if err := os.WriteFile(other, []byte("todo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, ".Kernel.php.swp"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved by replacing the file, as many editors do
+	tmp := filepath.Join(dir, "script.php.tmp")
+	if err := os.WriteFile(tmp, []byte("<?php echo 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond) // Distinct modification time for polling
+	if err := os.Rename(tmp, script); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-w.Changes():
+		if !slices.Equal(got, []string{script}) {
+			t.Errorf("got %v, want [%s]", got, script)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	// Files in directories created after watching started
+	nested := filepath.Join(src, "Http", "Controller.php")
+	if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * settle) // Let the new directory be watched
+	if err := os.WriteFile(nested, []byte("<?php\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-w.Changes():
+		if !slices.Contains(got, nested) {
+			t.Errorf("got %v, want %s", got, nested)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported for new directory")
+	}
+}